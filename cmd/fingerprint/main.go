@@ -0,0 +1,107 @@
+// Command fingerprint rewrites the references to the static assets of the distribution package
+// to content-addressed URLs (/a/<sha256>/<path>), which the game server serves as immutable.
+// The files are rewritten in the given order, each one before its own hash is taken,
+// so that a file may reference the content-addressed URL of a file rewritten before it.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	directory   = flag.String("directory", ".", "directory of the distribution package")
+	files       = flag.String("files", "wasm.js,manifest.json,index.html,service-worker.js", "comma-separated files whose references are rewritten, in order")
+	unversioned = flag.String("unversioned", "index.html,service-worker.js", "comma-separated assets which are always referenced by their own path")
+)
+
+// reference matches a quoted path, optionally absolute.
+var reference = regexp.MustCompile(`"/?([^"\s]+)"`)
+
+// main is the entry point of the command.
+func main() {
+	flag.Parse()
+
+	rewritten, err := fingerprint(*directory, strings.Split(*files, ","), strings.Split(*unversioned, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Rewritten %d references in %s\n", rewritten, *directory)
+}
+
+// fingerprint rewrites the references to the assets in the directory found in the files and returns their number.
+func fingerprint(directory string, files, unversioned []string) (int, error) {
+	assets := make(map[string]bool)
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+
+		assets[filepath.ToSlash(name)] = !slices.Contains(unversioned, filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var rewritten int
+	for _, file := range files {
+		path := filepath.Join(directory, file)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return rewritten, err
+		}
+
+		var errs []error
+		content = reference.ReplaceAllFunc(content, func(match []byte) []byte {
+			name := string(reference.FindSubmatch(match)[1])
+			if !assets[name] {
+				return match
+			}
+
+			hash, err := hashFile(filepath.Join(directory, name))
+			if err != nil {
+				errs = append(errs, err)
+				return match
+			}
+
+			rewritten++
+			return []byte(`"/a/` + hash + "/" + name + `"`)
+		})
+		if len(errs) > 0 {
+			return rewritten, errs[0]
+		}
+
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return rewritten, err
+		}
+	}
+
+	return rewritten, nil
+}
+
+// hashFile returns the hex-encoded SHA-256 hash of the content of the file.
+func hashFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,105 @@
+// Command precompress writes the brotli (.br) and gzip (.gz) variants of the static assets of the distribution package,
+// so that the game server does not need to compress them on every request.
+// A variant is only kept if it is smaller than the original file.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	brotli "github.com/andybalholm/brotli"
+)
+
+// minSize is the size in bytes below which the assets are not compressed, since the gain is negligible.
+const minSize = 1024
+
+var (
+	directory  = flag.String("directory", ".", "directory of the distribution package")
+	extensions = flag.String("extensions", ".css,.html,.js,.json,.wasm", "comma-separated extensions of the assets to compress")
+)
+
+// encoders are the compressors of the variants by their file extension.
+var encoders = map[string]func(io.Writer) io.WriteCloser{
+	".br": func(w io.Writer) io.WriteCloser { return brotli.NewWriterLevel(w, brotli.BestCompression) },
+	".gz": func(w io.Writer) io.WriteCloser {
+		gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return gz
+	},
+}
+
+// main is the entry point of the command.
+func main() {
+	flag.Parse()
+
+	written, err := precompress(*directory, strings.Split(*extensions, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Written %d precompressed variants to %s\n", written, *directory)
+}
+
+// compress returns the content compressed by the encoder.
+func compress(content []byte, encoder func(io.Writer) io.WriteCloser) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := encoder(&buffer)
+	if _, err := writer.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// precompress writes the variants of the assets with the given extensions in the directory and returns their number.
+// Stale variants of assets which are no longer compressed are removed.
+func precompress(directory string, extensions []string) (int, error) {
+	var written int
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !slices.Contains(extensions, filepath.Ext(path)) {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for extension, encoder := range encoders {
+			var compressed []byte
+			if len(content) >= minSize {
+				if compressed, err = compress(content, encoder); err != nil {
+					return fmt.Errorf("failed to compress %s: %w", path, err)
+				}
+			}
+
+			if compressed == nil || len(compressed) >= len(content) {
+				if err := os.Remove(path + extension); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+
+			if err := os.WriteFile(path+extension, compressed, 0o644); err != nil {
+				return err
+			}
+			written++
+		}
+
+		return nil
+	})
+
+	return written, err
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+	config "github.com/sarumaj/edu-space-invaders/src/pkg/config"
+	handler "github.com/sarumaj/edu-space-invaders/src/pkg/handler"
+	server "github.com/sarumaj/edu-space-invaders/src/pkg/server"
+)
+
+// commands are the subcommands of the server binary.
+var commands = map[string]struct {
+	description string
+	run         func(args []string, stdout io.Writer) error
+}{
+	"audit list":     {"list the events of the audit log of a running game server", listAuditEvents},
+	"export metrics": {"export the request counts of a running game server as CSV, JSON or NDJSON", exportTableCommand(client.TableMetrics)},
+	"export scores":  {"export the scores of a running game server as CSV, JSON or NDJSON", exportTableCommand(client.TableScores)},
+	"flags get":      {"print the feature flags effective on a running game server", getFlags},
+	"flags history":  {"list the most recent changes of the feature flags of a running game server", getFlagHistory},
+	"flags set":      {"set (KEY=VALUE) or unset (KEY) feature flags of a running game server", setFlags},
+	"import scores":  {"merge the scores of a CSV, JSON or NDJSON file with the scores of a running game server", importScores},
+	"keys generate":  {"generate the RSA key to sign JWT tokens and the AES key to encrypt session cookies", generateKeys},
+	"migrate down":   {"revert the most recently applied migrations of the database", migrateDown},
+	"migrate status": {"list the migrations of the database and when they were applied", migrateStatus},
+	"migrate up":     {"apply the pending migrations of the database", migrateUp},
+	"replay run":     {"replay a game run headlessly and print the high score as JSON, see server.CommandReplayer", replayRun},
+	"token inspect":  {"decode and verify a JWT token or an encrypted session cookie", inspectToken},
+	"token issue":    {"issue a signed JWT token", issueTokenCommand},
+}
+
+// knownScopes are the scopes which can be granted to a JWT token.
+var knownScopes = []string{server.ScopeAdmin, server.ScopeConfigRead, server.ScopeConfigWrite, server.ScopePlayer}
+
+// bindClientFlags binds the flags addressing a running game server to the flag set.
+// It returns a function creating the API client once the flags have been parsed.
+func bindClientFlags(flags *flag.FlagSet) func() (*client.Client, error) {
+	serverURL := flags.String("url", getenv("SERVER_URL", fmt.Sprintf("http://localhost:%d", serverConfig.Server.Port)), "base URL of the game server (env SERVER_URL)")
+	token := flags.String("token", getenv("TOKEN", ""), "JWT token authenticating the requests (env TOKEN)")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of the requests")
+
+	return func() (*client.Client, error) {
+		return client.New(*serverURL, client.WithToken(*token), client.WithHTTPClient(&http.Client{Timeout: *timeout}))
+	}
+}
+
+// exportTableCommand returns the command exporting the table of the game server to a file or to the standard output.
+func exportTableCommand(table string) func(args []string, stdout io.Writer) error {
+	return func(args []string, stdout io.Writer) error {
+		flags := flag.NewFlagSet("export "+table, flag.ContinueOnError)
+		newClient := bindClientFlags(flags)
+		format := flags.String("format", client.FormatCSV, "format of the export: csv, json or ndjson")
+		output := flags.String("output", "-", "path to write the export to, the standard output if \"-\"")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		if *output == "-" {
+			return c.ExportTable(context.Background(), table, *format, stdout)
+		}
+
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *output, err)
+		}
+
+		if err := c.ExportTable(context.Background(), table, *format, file); err != nil {
+			_ = file.Close()
+			return err
+		}
+
+		return file.Close()
+	}
+}
+
+// generateKeys generates the RSA key and the AES key.
+// The RSA key is written as a PKCS #8 PEM block, the AES key as an AES PRIVATE KEY PEM block.
+// If a key directory is given, the keys are added to it named after the key identifier,
+// so that they supersede the existing keys after the key set has been reloaded.
+// Existing keys are not overwritten unless forced.
+func generateKeys(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	aesKeyPath := flags.String("aes-key", serverConfig.Keys.AESKey, "path to write the AES key to")
+	aesKeySize := flags.Int("aes-size", 32, "size of the AES key in bytes (16, 24 or 32)")
+	force := flags.Bool("force", false, "overwrite existing keys")
+	dir := flags.String("keyset", serverConfig.Keys.KeySet, "path to the key directory to add the keys to, overrides --rsa-key and --aes-key")
+	kid := flags.String("kid", time.Now().UTC().Format("20060102T150405Z"), "identifier of the keys added to the key directory")
+	rsaKeyPath := flags.String("rsa-key", serverConfig.Keys.RSAKey, "path to write the RSA key to")
+	rsaKeyBits := flags.Int("rsa-bits", 2048, "size of the RSA key in bits")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir != "" {
+		var err error
+		if *rsaKeyPath, *aesKeyPath, err = server.KeyPaths(*dir, *kid); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(*dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create key directory: %w", err)
+		}
+	}
+
+	if err := server.GenerateKeys(*rsaKeyPath, *rsaKeyBits, *aesKeyPath, *aesKeySize, *force); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "Written PRIVATE KEY to %s\nWritten AES PRIVATE KEY to %s\n", *rsaKeyPath, *aesKeyPath)
+	return nil
+}
+
+// getFlagHistory prints the most recent changes of the feature flags as JSON, the newest first.
+func getFlagHistory(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("flags history", flag.ContinueOnError)
+	newClient := bindClientFlags(flags)
+	key := flags.String("key", "", "key of the flag to list the changes of, all flags if empty")
+	limit := flags.Int("limit", 100, "maximum number of changes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	changes, err := c.GetFlagHistory(context.Background(), *key, *limit)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(stdout, changes)
+}
+
+// getFlags prints the feature flags effective on the game server as JSON.
+func getFlags(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("flags get", flag.ContinueOnError)
+	newClient := bindClientFlags(flags)
+	player := flags.String("player", "", "name of the player whose overrides apply, requires the config:read scope")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	env, err := c.GetEnv(context.Background(), *player)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(stdout, env)
+}
+
+// importScores imports the scores read from the file given by the first argument or from the standard input,
+// if the argument is "-" or missing, and prints the report of the import as JSON.
+// The format defaults to the extension of the file.
+func importScores(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import scores", flag.ContinueOnError)
+	newClient := bindClientFlags(flags)
+	var options client.ImportOptions
+	flags.BoolVar(&options.DryRun, "dry-run", false, "report the changes without saving them")
+	flags.StringVar(&options.Format, "format", "", "format of the file: csv, json or ndjson, the extension of the file if empty")
+	flags.StringVar(&options.Mode, "mode", client.ImportKeepHigher, "merge mode: keep-higher, overwrite or skip-existing")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var reader io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		reader = file
+		if options.Format == "" {
+			options.Format = strings.TrimPrefix(filepath.Ext(path), ".")
+		}
+	}
+
+	if options.Format == "" {
+		return fmt.Errorf("missing format")
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	report, err := c.ImportScores(context.Background(), reader, options)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(stdout, report)
+}
+
+// inspectToken decodes and verifies a JWT token or an encrypted session cookie.
+// The token is read from the first argument or from the standard input, if the argument is "-" or missing.
+// It prints the header and the claims of the token and fails if the token is invalid.
+func inspectToken(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("token inspect", flag.ContinueOnError)
+	aesKeyPath := flags.String("aes-key", serverConfig.Keys.AESKey, "path to the AES key to decrypt session cookies")
+	dir := flags.String("keyset", serverConfig.Keys.KeySet, "path to the key directory, overrides --rsa-key and --aes-key")
+	rsaKeyPath := flags.String("rsa-key", serverConfig.Keys.RSAKey, "path to the RSA key to verify JWT tokens")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	raw := flags.Arg(0)
+	if raw == "" || raw == "-" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		raw = line
+	}
+
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "Bearer ")
+	if raw == "" {
+		return fmt.Errorf("missing token")
+	}
+
+	keys, err := server.OpenKeySet(*dir, *rsaKeyPath, *aesKeyPath)
+	if err != nil {
+		return err
+	}
+
+	// Session cookies are encrypted tokens.
+	source := "token"
+	if strings.Count(raw, ".") != 2 {
+		decrypted, err := keys.Decrypt(raw)
+		if err != nil {
+			return fmt.Errorf("neither a token nor a session cookie: %w", err)
+		}
+		raw, source = decrypted, "cookie"
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(raw, &server.Claims{})
+	if err != nil {
+		return fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	_, verificationErr := server.TokenParser(keys)(raw)
+	report := map[string]any{
+		"source": source,
+		"header": token.Header,
+		"claims": token.Claims,
+		"valid":  verificationErr == nil,
+	}
+
+	if verificationErr != nil {
+		report["error"] = verificationErr.Error()
+	}
+
+	if err := writeJSON(stdout, report); err != nil {
+		return err
+	}
+
+	if verificationErr != nil {
+		return fmt.Errorf("invalid token: %w", verificationErr)
+	}
+
+	return nil
+}
+
+// issueTokenCommand issues a signed JWT token and prints it.
+// The token carries the same claims as the tokens validated by the AuthenticatorMiddleware.
+func issueTokenCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	aesKeyPath := flags.String("aes-key", serverConfig.Keys.AESKey, "path to the AES key")
+	dir := flags.String("keyset", serverConfig.Keys.KeySet, "path to the key directory, the most recent RSA key signs the token, overrides --rsa-key and --aes-key")
+	rsaKeyPath := flags.String("rsa-key", serverConfig.Keys.RSAKey, "path to the RSA key to sign the JWT token")
+	scope := flags.String("scope", server.ScopePlayer, "space-separated scopes of the token: "+strings.Join(knownScopes, ", "))
+	subject := flags.String("subject", "", "subject of the token")
+	ttl := flags.Duration("ttl", 10*time.Minute, "time to live of the token, the token does not expire if zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*subject) == "" {
+		return fmt.Errorf("missing subject")
+	}
+
+	scopes := strings.Fields(*scope)
+	for _, s := range scopes {
+		if !slices.Contains(knownScopes, s) {
+			return fmt.Errorf("unknown scope: %q", s)
+		}
+	}
+
+	keys, err := server.OpenKeySet(*dir, *rsaKeyPath, *aesKeyPath)
+	if err != nil {
+		return err
+	}
+
+	token, err := server.IssueToken(keys, *subject, *ttl, scopes...)
+	if err != nil {
+		return fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	_, err = fmt.Fprintln(stdout, token)
+	return err
+}
+
+// listAuditEvents prints the events of the audit log as JSON, the newest first.
+func listAuditEvents(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("audit list", flag.ContinueOnError)
+	newClient := bindClientFlags(flags)
+	var query client.AuditQuery
+	flags.StringVar(&query.Action, "action", "", "action of the events, e.g. \"PUT /scores.db\"")
+	flags.StringVar(&query.Actor, "actor", "", "actor of the events, i.e. the subject of the token")
+	flags.IntVar(&query.Limit, "limit", 100, "maximum number of events")
+	since := flags.Duration("since", 0, "list the events of the given period only, e.g. 24h, all events if zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *since > 0 {
+		query.Since = time.Now().Add(-*since)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	events, err := c.GetAuditEvents(context.Background(), query)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(stdout, events)
+}
+
+// migrateDown reverts the most recently applied migrations and prints the status of the migrations as JSON.
+func migrateDown(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return writeMigrations(stdout)(server.MigrateDown(serverConfig.Database, *steps, logger))
+}
+
+// migrateStatus prints the status of the migrations as JSON.
+func migrateStatus(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate status", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return writeMigrations(stdout)(server.MigrationStatuses(serverConfig.Database))
+}
+
+// migrateUp applies the pending migrations and prints the status of the migrations as JSON.
+func migrateUp(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+	to := flags.Uint("to", 0, "version to migrate to, all pending migrations if zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return writeMigrations(stdout)(server.MigrateUp(serverConfig.Database, *to, logger))
+}
+
+// replayRun replays the game run read as JSON from the file given by the argument or from the standard input,
+// and prints the high score as JSON. An invalid replay is reported by the printed result rather than by an error.
+func replayRun(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("replay run", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var reader io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	var replay config.Replay
+	if err := json.NewDecoder(reader).Decode(&replay); err != nil {
+		return writeJSON(stdout, server.ReplayResult{Error: fmt.Sprintf("failed to decode the replay: %v", err)})
+	}
+
+	highScore, err := handler.Replay(replay)
+	if err != nil {
+		return writeJSON(stdout, server.ReplayResult{Error: err.Error()})
+	}
+
+	return writeJSON(stdout, server.ReplayResult{HighScore: highScore})
+}
+
+// runCommand runs the subcommand given by the arguments.
+func runCommand(args []string, stdout io.Writer) error {
+	name := strings.Join(args[:min(2, len(args))], " ")
+	command, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+
+	return command.run(args[min(2, len(args)):], stdout)
+}
+
+// setFlags sets or unsets the feature flags given by the arguments and prints the effective flags as JSON.
+// An argument KEY=VALUE sets the flag, an argument KEY without a value unsets it.
+func setFlags(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("flags set", flag.ContinueOnError)
+	newClient := bindClientFlags(flags)
+	scope := flags.String("scope", client.FlagScopeGlobal, "scope of the flags: global, player or session")
+	subject := flags.String("subject", "", "name of the player or subject of the session, unless the scope is global")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("missing flags")
+	}
+
+	changes := make(map[string]*string, flags.NArg())
+	for _, arg := range flags.Args() {
+		key, value, ok := strings.Cut(arg, "=")
+		changes[key] = nil
+		if ok {
+			changes[key] = &value
+		}
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	env, err := c.SetEnv(context.Background(), changes, *scope, *subject)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(stdout, env)
+}
+
+// usage prints the usage of the server binary.
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %s [flags] [command [command flags]]\n\nCommands:\n", os.Args[0])
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(out, "  %-15s %s\n", name, commands[name].description)
+	}
+
+	_, _ = fmt.Fprintf(out, "\nRun without a command to start the server.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// writeMigrations returns a function printing the status of the migrations as JSON, even if the migration failed part way.
+func writeMigrations(stdout io.Writer) func([]server.MigrationStatus, error) error {
+	return func(status []server.MigrationStatus, err error) error {
+		if status == nil {
+			return err
+		}
+
+		return errors.Join(err, writeJSON(stdout, status))
+	}
+}
+
+// writeJSON writes the value as indented JSON.
+func writeJSON(stdout io.Writer, value any) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/sarumaj/edu-space-invaders/src/pkg/server"
+)
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	rsaKeyPath, aesKeyPath := filepath.Join(dir, "rsa.pem"), filepath.Join(dir, "aes.pem")
+
+	run := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := runCommand(args, &stdout)
+		return strings.TrimSpace(stdout.String()), err
+	}
+
+	if _, err := run("keys", "generate", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath); err != nil {
+		t.Fatalf("keys generate failed: %v", err)
+	}
+
+	if _, err := run("keys", "generate", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath); err == nil {
+		t.Errorf("keys generate overwrote existing keys")
+	}
+
+	keys, err := server.OpenKeySet("", rsaKeyPath, aesKeyPath)
+	if err != nil {
+		t.Fatalf("generated keys are invalid: %v", err)
+	}
+
+	token, err := run("token", "issue", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath, "--subject", "test", "--scope", "player config:read")
+	if err != nil {
+		t.Fatalf("token issue failed: %v", err)
+	}
+
+	claims, err := server.TokenParser(keys)(token)
+	if err != nil {
+		t.Fatalf("issued token is invalid: %v", err)
+	}
+
+	if claims.Subject != "test" || !claims.HasScope(server.ScopeConfigRead) || claims.HasScope(server.ScopeConfigWrite) {
+		t.Errorf("issued token has unexpected claims: %+v", claims)
+	}
+
+	cookie, err := keys.Encrypt(token)
+	if err != nil {
+		t.Fatalf("failed to encrypt token: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		args       []string
+		wantErr    bool
+		wantSource string
+	}{
+		{"test#1", []string{"token", "inspect", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath, token}, false, "token"},
+		{"test#2", []string{"token", "inspect", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath, cookie}, false, "cookie"},
+		{"test#3", []string{"token", "inspect", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath, token + "x"}, true, "token"},
+		{"test#4", []string{"token", "issue", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath, "--subject", "test", "--scope", "root"}, true, ""},
+		{"test#5", []string{"token", "issue", "--rsa-key", rsaKeyPath, "--aes-key", aesKeyPath}, true, ""},
+		{"test#6", []string{"token"}, true, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, err := run(tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runCommand(%v) error = %v, wantErr %v", tt.args[:2], err, tt.wantErr)
+			}
+
+			if tt.wantSource == "" {
+				return
+			}
+
+			var report struct {
+				Source string `json:"source"`
+				Valid  bool   `json:"valid"`
+			}
+			if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+
+			if report.Source != tt.wantSource || report.Valid == tt.wantErr {
+				t.Errorf("runCommand(%v) = %+v, want source %q and valid %v", tt.args[:2], report, tt.wantSource, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientCommands(t *testing.T) {
+	dir := t.TempDir()
+	if err := runCommand([]string{"keys", "generate", "--keyset", dir, "--kid", "test"}, &strings.Builder{}); err != nil {
+		t.Fatalf("keys generate failed: %v", err)
+	}
+
+	keys, err := server.OpenKeySet(dir, "", "")
+	if err != nil {
+		t.Fatalf("generated keys are invalid: %v", err)
+	}
+
+	token, err := server.IssueToken(keys, "test", time.Hour, server.ScopeAdmin)
+	if err != nil {
+		t.Fatalf("IssueToken() failed: %v", err)
+	}
+
+	handler, err := server.New(server.Options{Store: server.NewMemoryStore(), Keys: keys, Replayer: server.CommandReplayer("false")})
+	if err != nil {
+		t.Fatalf("server.New() failed: %v", err)
+	}
+
+	gameServer := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		gameServer.Close()
+		_ = handler.Close()
+	})
+
+	scores := filepath.Join(t.TempDir(), "scores.csv")
+	if err := os.WriteFile(scores, []byte("name,score\na,7\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		wantErr bool
+		want    string
+	}{
+		{"test#1", []string{"flags", "set", "--url", gameServer.URL, "--token", token, "SPACE_INVADERS_A=1", "SPACE_INVADERS_B=2"}, false, `"SPACE_INVADERS_B": "2"`},
+		{"test#2", []string{"flags", "set", "--url", gameServer.URL, "--token", token, "SPACE_INVADERS_B"}, false, `"SPACE_INVADERS_A": "1"`},
+		{"test#3", []string{"flags", "set", "--url", gameServer.URL, "--token", token}, true, ""},
+		{"test#4", []string{"flags", "set", "--url", gameServer.URL, "SPACE_INVADERS_A=1"}, true, ""},
+		{"test#5", []string{"flags", "get", "--url", gameServer.URL, "--token", token}, false, `"SPACE_INVADERS_A": "1"`},
+		{"test#6", []string{"flags", "history", "--url", gameServer.URL, "--token", token, "--key", "SPACE_INVADERS_B"}, false, `"previous": "2"`},
+		{"test#7", []string{"audit", "list", "--url", gameServer.URL, "--token", token, "--action", "POST /.env", "--since", "1h"}, false, `"actor": "test"`},
+		{"test#8", []string{"import", "scores", "--url", gameServer.URL, "--token", token, "--dry-run", scores}, false, `"created": 1`},
+		{"test#9", []string{"import", "scores", "--url", gameServer.URL, "--token", token, scores}, false, `"dry_run": false`},
+		{"test#10", []string{"import", "scores", "--url", gameServer.URL, "--token", token, "--mode", "merge", scores}, true, ""},
+		{"test#11", []string{"export", "scores", "--url", gameServer.URL, "--token", token}, false, "a,7,"},
+		{"test#12", []string{"export", "metrics", "--url", gameServer.URL, "--token", token, "--format", "xml"}, true, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout strings.Builder
+			err := runCommand(tt.args, &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runCommand(%v) error = %v, wantErr %v", tt.args[:2], err, tt.wantErr)
+			}
+
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("runCommand(%v) = %s, want %s", tt.args[:2], stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateCommandsMemoryStore(t *testing.T) {
+	previous := serverConfig
+	t.Cleanup(func() { serverConfig = previous })
+	serverConfig.Database.URL = "memory://"
+
+	for _, args := range [][]string{{"migrate", "up"}, {"migrate", "down"}, {"migrate", "status"}} {
+		var stdout bytes.Buffer
+		if err := runCommand(args, &stdout); err == nil {
+			t.Errorf("%s succeeded on the in-memory store, want error", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestReplayRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	valid := write("valid.json", `{"seed":2,"frames":600,"inputs":[{"f":0,"d":"k","k":"Space","p":true},{"f":1,"d":"k","k":"Space","p":true}],"flags":[{"f":0,"cdv":3,"pc":-1}]}`)
+	invalid := write("invalid.json", `{"seed":2,"frames":600}`)
+	malformed := write("malformed.json", `{`)
+
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		wantErr bool
+		want    string
+	}{
+		{"test#1", []string{"replay", "run", valid}, false, "{\n  \"high_score\": 4\n}"},
+		{"test#2", []string{"replay", "run", invalid}, false, `"error": "game flags at frame 0 are missing"`},
+		{"test#3", []string{"replay", "run", malformed}, false, `"error": "failed to decode the replay`},
+		{"test#4", []string{"replay", "run", filepath.Join(dir, "missing.json")}, true, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout strings.Builder
+			err := runCommand(tt.args, &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runCommand(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("runCommand(%v) = %s, want %s", tt.args, stdout.String(), tt.want)
+			}
+		})
+	}
+}
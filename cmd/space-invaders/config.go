@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	server "github.com/sarumaj/edu-space-invaders/src/pkg/server"
+	zap "go.uber.org/zap"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in the printed configuration.
+const redacted = "xxxxx"
+
+// Config is the configuration of the game server.
+// The settings are read from the configuration file, the environment variables and the flags,
+// each source overriding the former one.
+// The schema is documented by config.example.yaml.
+type Config struct {
+	Server    ServerConfig           `yaml:"server"`
+	Database  server.DatabaseConfig  `yaml:"database"`
+	Keys      KeysConfig             `yaml:"keys"`
+	Limits    server.LimitsConfig    `yaml:"limits"`
+	Metrics   server.MetricsConfig   `yaml:"metrics"`
+	Retention server.RetentionConfig `yaml:"retention"`
+}
+
+// ServerConfig configures the listener of the game server.
+type ServerConfig struct {
+	Port            uint          `yaml:"port"`             // Port is the port to listen on.
+	ForceSecure     bool          `yaml:"force_secure"`     // ForceSecure redirects to HTTPS and enables HSTS.
+	ReadTimeout     time.Duration `yaml:"read_timeout"`     // ReadTimeout is the maximum duration for reading an entire request.
+	WriteTimeout    time.Duration `yaml:"write_timeout"`    // WriteTimeout is the maximum duration before timing out writes of the response.
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`     // IdleTimeout is the maximum time to wait for the next request on a keep-alive connection.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // ShutdownTimeout is the maximum time to wait for the in-flight requests on shutdown.
+	TrustedProxies  string        `yaml:"trusted_proxies"`  // TrustedProxies is a comma-separated list of IP addresses and CIDR ranges of the reverse proxies.
+}
+
+// trustedProxies returns the IP addresses and CIDR ranges of the trusted reverse proxies.
+func (c ServerConfig) trustedProxies() (proxies []string) {
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return
+}
+
+// KeysConfig configures the keys to sign JWT tokens and to encrypt session cookies.
+type KeysConfig struct {
+	AESKey string `yaml:"aes_key"` // AESKey is the path to the AES key.
+	RSAKey string `yaml:"rsa_key"` // RSAKey is the path to the RSA key.
+	KeySet string `yaml:"keyset"`  // KeySet is the path to a key directory, it overrides the key files.
+}
+
+// option binds a setting of the configuration to its flag and environment variable.
+type option struct {
+	name  string // name is the name of the flag, the environment variable is derived from it.
+	value any    // value points to the setting.
+	usage string
+}
+
+// env returns the name of the environment variable of the option, e.g. DATABASE_URL for --database-url.
+func (o option) env() string {
+	return strings.ToUpper(strings.ReplaceAll(o.name, "-", "_"))
+}
+
+// options returns the options bound to the settings of the configuration.
+func (c *Config) options() []option {
+	return []option{
+		{"aes-key", &c.Keys.AESKey, "path to the AES key to encrypt and decrypt JWT tokens"},
+		{"database-migrate", &c.Database.Migrate, "apply the pending database migrations on startup, otherwise the server is not ready until they are applied by migrate up"},
+		{"database-sslmode", &c.Database.SSLMode, "SSL mode of the database connection, unless given by the database URL"},
+		{"database-timezone", &c.Database.Timezone, "time zone of the database session, unless given by the database URL"},
+		{"database-url", &c.Database.URL, "database address, use memory:// for a volatile in-memory store"},
+		{"force-secure", &c.Server.ForceSecure, "force secure connection over HTTPS"},
+		{"idle-timeout", &c.Server.IdleTimeout, "maximum time to wait for the next request on a keep-alive connection"},
+		{"keyset", &c.Keys.KeySet, "path to a directory of RSA and AES keys in PEM files, overrides --rsa-key and --aes-key"},
+		{"limit-admin-burst", &c.Limits.Admin.Burst, "burst size for rate limiting the admin endpoints per client"},
+		{"limit-admin-rps", &c.Limits.Admin.Rate, "requests per second for rate limiting the admin endpoints per client, 0 disables the limit"},
+		{"limit-assets-burst", &c.Limits.Assets.Burst, "burst size for rate limiting the static assets per client"},
+		{"limit-assets-rps", &c.Limits.Assets.Rate, "requests per second for rate limiting the static assets per client, 0 disables the limit"},
+		{"limit-burst", &c.Limits.API.Burst, "burst size for rate limiting the API per client"},
+		{"limit-clients", &c.Limits.Clients, "maximum number of clients tracked for rate limiting, the least recently seen clients are evicted"},
+		{"limit-replay", &c.Limits.Replay, "maximum game time of the score submissions verified by replaying them, longer runs are kept unverified"},
+		{"limit-replays", &c.Limits.Replays, "maximum number of score submissions replayed at once, each in a process of its own, 0 for the number of CPUs"},
+		{"limit-rps", &c.Limits.API.Rate, "requests per second for rate limiting the API per client, 0 disables the limit"},
+		{"limit-scores-burst", &c.Limits.Scores.Burst, "burst size for rate limiting the score submissions per client"},
+		{"limit-scores-rps", &c.Limits.Scores.Rate, "requests per second for rate limiting the score submissions per client, 0 disables the limit"},
+		{"limit-ttl", &c.Limits.TTL, "time after which idle clients are evicted from rate limiting"},
+		{"metrics-address", &c.Metrics.Address, "address to serve the Prometheus metrics on without authentication, if empty the metrics are served on /metrics to the admin scope"},
+		{"metrics-buffer", &c.Metrics.Buffer, "maximum number of distinct endpoints and methods buffered before saving the request counts, further requests are dropped"},
+		{"metrics-flush-interval", &c.Metrics.FlushInterval, "interval to save the buffered request counts to the database"},
+		{"metrics-flush-size", &c.Metrics.FlushSize, "number of requests after which the buffered request counts are saved to the database before the interval elapses"},
+		{"port", &c.Server.Port, "port to listen on"},
+		{"read-timeout", &c.Server.ReadTimeout, "maximum duration for reading an entire request, including the body"},
+		{"retention-dry-run", &c.Retention.DryRun, "report the rows to be deleted by the retention policy without deleting them"},
+		{"retention-interval", &c.Retention.Interval, "interval to apply the retention policy"},
+		{"retention-keep-scores", &c.Retention.KeepScores, "number of highest scores to keep once the database exceeds its maximum size"},
+		{"retention-max-size", &c.Retention.MaxSize, "maximum size of the database in bytes before the lowest scores are deleted"},
+		{"retention-metric-age", &c.Retention.MetricAge, "age after which metrics which have not been updated are deleted, 0 keeps them forever"},
+		{"retention-unverified-run-age", &c.Retention.UnverifiedRunAge, "age after which unverified runs are deleted, 0 keeps them until the database exceeds its maximum size"},
+		{"rsa-key", &c.Keys.RSAKey, "path to the RSA key to sign and verify JWT tokens"},
+		{"shutdown-timeout", &c.Server.ShutdownTimeout, "maximum time to wait for the in-flight requests to complete on shutdown"},
+		{"trusted-proxies", &c.Server.TrustedProxies, "comma-separated IP addresses and CIDR ranges of the reverse proxies whose X-Forwarded-For header identifies the client"},
+		{"write-timeout", &c.Server.WriteTimeout, "maximum duration before timing out writes of the response"},
+	}
+}
+
+// bind defines a flag for each setting of the configuration.
+// The current values of the settings are the defaults of the flags.
+func (c *Config) bind(flags *flag.FlagSet) {
+	for _, o := range c.options() {
+		usage := fmt.Sprintf("%s (env %s)", o.usage, o.env())
+		switch value := o.value.(type) {
+		case *bool:
+			flags.BoolVar(value, o.name, *value, usage)
+		case *float64:
+			flags.Float64Var(value, o.name, *value, usage)
+		case *int:
+			flags.IntVar(value, o.name, *value, usage)
+		case *server.Size:
+			flags.Int64Var((*int64)(value), o.name, int64(*value), usage)
+		case *string:
+			flags.StringVar(value, o.name, *value, usage)
+		case *time.Duration:
+			flags.DurationVar(value, o.name, *value, usage)
+		case *uint:
+			flags.UintVar(value, o.name, *value, usage)
+		default:
+			panic(fmt.Sprintf("unsupported type of option %s: %T", o.name, o.value))
+		}
+	}
+}
+
+// logFields returns the settings of the configuration as log fields named after their flags.
+func (c Config) logFields() []zap.Field {
+	options := c.options()
+	fields := make([]zap.Field, 0, len(options))
+	for _, o := range options {
+		fields = append(fields, zap.Any(o.name, reflect.ValueOf(o.value).Elem().Interface()))
+	}
+
+	return fields
+}
+
+// Redacted returns a copy of the configuration with the secrets replaced.
+func (c Config) Redacted() Config {
+	// An invalid URL may contain the password unescaped.
+	address, err := url.Parse(c.Database.URL)
+	if err != nil {
+		c.Database.URL = redacted
+		return c
+	}
+
+	if _, ok := address.User.Password(); ok {
+		address.User = url.UserPassword(address.User.Username(), redacted)
+		c.Database.URL = address.String()
+	}
+
+	if query := address.Query(); query.Has("password") {
+		query.Set("password", redacted)
+		address.RawQuery = query.Encode()
+		c.Database.URL = address.String()
+	}
+
+	return c
+}
+
+// Validate validates the configuration.
+// It reports all invalid settings at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(invalid bool, format string, args ...any) {
+		if invalid {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port == 0 || c.Server.Port > 65535, "port must be between 1 and 65535: %d", c.Server.Port)
+	check(c.Server.ReadTimeout < 0, "read timeout must not be negative: %s", c.Server.ReadTimeout)
+	check(c.Server.WriteTimeout < 0, "write timeout must not be negative: %s", c.Server.WriteTimeout)
+	check(c.Server.IdleTimeout < 0, "idle timeout must not be negative: %s", c.Server.IdleTimeout)
+	check(c.Server.ShutdownTimeout <= 0, "shutdown timeout must be positive: %s", c.Server.ShutdownTimeout)
+	for _, proxy := range c.Server.trustedProxies() {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err != nil && net.ParseIP(proxy) == nil, "trusted proxy must be an IP address or a CIDR range: %q", proxy)
+	}
+
+	// The error of an invalid URL is reported without the URL, which may contain the password.
+	if address, err := url.Parse(c.Database.URL); err != nil {
+		if urlErr := (*url.Error)(nil); errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		errs = append(errs, fmt.Errorf("invalid database URL: %w", err))
+	} else {
+		check(address.Scheme == "", "database URL must have a scheme: %q", address.Redacted())
+	}
+
+	check(c.Keys.KeySet == "" && (c.Keys.RSAKey == "" || c.Keys.AESKey == ""), "RSA and AES keys are required unless a key directory is given")
+
+	for _, limit := range []struct {
+		group string
+		server.RateLimit
+	}{{"API", c.Limits.API}, {"admin", c.Limits.Admin}, {"assets", c.Limits.Assets}, {"scores", c.Limits.Scores}} {
+		check(limit.Rate < 0, "%s rate limit must not be negative: %g", limit.group, limit.Rate)
+		check(limit.Burst < 0, "%s burst size must not be negative: %d", limit.group, limit.Burst)
+	}
+
+	check(c.Limits.Clients < 1, "number of rate limited clients must be positive: %d", c.Limits.Clients)
+	check(c.Limits.TTL < 0, "rate limit TTL must not be negative: %s", c.Limits.TTL)
+	check(c.Limits.Replay < 0, "replay limit must not be negative: %s", c.Limits.Replay)
+	check(c.Limits.Replays < 0, "number of concurrent replays must not be negative: %d", c.Limits.Replays)
+
+	check(c.Metrics.Buffer < 1, "metrics buffer must be positive: %d", c.Metrics.Buffer)
+	check(c.Metrics.FlushInterval <= 0, "metrics flush interval must be positive: %s", c.Metrics.FlushInterval)
+	check(c.Metrics.FlushSize < 1, "metrics flush size must be positive: %d", c.Metrics.FlushSize)
+
+	errs = append(errs, c.Retention.Validate())
+	return errors.Join(errs...)
+}
+
+// defaultConfig returns the default configuration.
+// The limits, the metrics and the retention policy default to those of the game server.
+func defaultConfig() Config {
+	defaults := server.DefaultOptions()
+	return Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Database: server.DatabaseConfig{
+			URL:      "postgres://postgres:pass@db:5432/postgres",
+			SSLMode:  "disable",
+			Timezone: "Europe/Berlin",
+			Migrate:  true,
+		},
+		Keys: KeysConfig{
+			AESKey: "aes_key.pem",
+			RSAKey: "rsa_key.pem",
+		},
+		Limits:    defaults.Limits,
+		Metrics:   defaults.Metrics,
+		Retention: defaults.Retention,
+	}
+}
+
+// loadConfig returns the effective configuration.
+// The defaults are overridden by the configuration file at the given path, if any,
+// then by the environment variables and finally by the flags set on the command line.
+// All invalid settings are reported at once.
+func loadConfig(path string, lookupEnv func(string) (string, bool), flags *flag.FlagSet) (Config, error) {
+	config := defaultConfig()
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("failed to read configuration file: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+			return config, fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+	}
+
+	// The settings are parsed the same way, no matter whether they are set by environment variables or by flags.
+	settings := flag.NewFlagSet("config", flag.ContinueOnError)
+	settings.SetOutput(io.Discard)
+	config.bind(settings)
+
+	var errs []error
+	for _, o := range config.options() {
+		if raw, ok := lookupEnv(o.env()); ok && raw != "" {
+			if err := settings.Set(o.name, raw); err != nil {
+				errs = append(errs, fmt.Errorf("invalid environment variable %s=%q: %w", o.env(), raw, err))
+			}
+		}
+	}
+
+	if flags != nil {
+		flags.Visit(func(f *flag.Flag) {
+			if settings.Lookup(f.Name) != nil {
+				_ = settings.Set(f.Name, f.Value.String())
+			}
+		})
+	}
+
+	errs = append(errs, config.Validate())
+	return config, errors.Join(errs...)
+}
+
+// writeConfig writes the configuration as YAML.
+func writeConfig(w io.Writer, config Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	file := writeFile("config.yaml", "server:\n  port: 9000\n  read_timeout: 1m\nlimits:\n  scores:\n    rate: 1\n")
+	unknown := writeFile("unknown.yaml", "server:\n  prot: 9000\n")
+	empty := writeFile("empty.yaml", "")
+
+	for _, tt := range []struct {
+		name       string
+		path       string
+		env        map[string]string
+		args       []string
+		want       func(*Config)
+		wantErrors int
+	}{
+		{"test#1", "", nil, nil, func(*Config) {}, 0},
+		{"test#2", empty, nil, nil, func(*Config) {}, 0},
+		{"test#3", file, nil, nil, func(c *Config) {
+			c.Server.Port, c.Server.ReadTimeout, c.Limits.Scores.Rate = 9000, time.Minute, 1
+		}, 0},
+		{"test#4", file, map[string]string{"PORT": "9001", "READ_TIMEOUT": "2m"}, nil, func(c *Config) {
+			c.Server.Port, c.Server.ReadTimeout, c.Limits.Scores.Rate = 9001, 2*time.Minute, 1
+		}, 0},
+		{"test#5", file, map[string]string{"PORT": "9001"}, []string{"--port", "9002", "--limit-scores-rps", "2"}, func(c *Config) {
+			c.Server.Port, c.Server.ReadTimeout, c.Limits.Scores.Rate = 9002, time.Minute, 2
+		}, 0},
+		{"test#6", "", map[string]string{"PORT": "", "DATABASE_URL": "memory://"}, nil, func(c *Config) { c.Database.URL = "memory://" }, 0},
+		{"test#7", "", map[string]string{"PORT": "http", "LIMIT_TTL": "1"}, []string{"--limit-clients", "0", "--limit-replays", "-1", "--retention-keep-scores", "0"}, nil, 5},
+		{"test#8", unknown, nil, nil, nil, 1},
+		{"test#9", filepath.Join(dir, "missing.yaml"), nil, nil, nil, 1},
+		{"test#10", "", map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, 192.0.2.1"}, nil, func(c *Config) { c.Server.TrustedProxies = "10.0.0.0/8, 192.0.2.1" }, 0},
+		{"test#11", "", map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8,proxy"}, nil, nil, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			commandLine := defaultConfig()
+			commandLine.bind(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() failed: %v", err)
+			}
+
+			lookupEnv := func(key string) (string, bool) { value, ok := tt.env[key]; return value, ok }
+			got, err := loadConfig(tt.path, lookupEnv, flags)
+			if tt.wantErrors > 0 {
+				if err == nil || len(strings.Split(err.Error(), "\n")) < tt.wantErrors {
+					t.Errorf("loadConfig() error = %v, want %d errors", err, tt.wantErrors)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("loadConfig() failed: %v", err)
+			}
+
+			want := defaultConfig()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("loadConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigExample(t *testing.T) {
+	got, err := loadConfig("config.example.yaml", func(string) (string, bool) { return "", false }, nil)
+	if err != nil {
+		t.Fatalf("loadConfig() failed: %v", err)
+	}
+
+	if want := defaultConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("config.example.yaml = %+v, want the defaults %+v", got, want)
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args string
+		want string
+	}{
+		{"test#1", "postgres://user:secret@db:5432/postgres", "postgres://user:xxxxx@db:5432/postgres"},
+		{"test#2", "postgres://db/postgres?password=secret&sslmode=require", "postgres://db/postgres?password=xxxxx&sslmode=require"},
+		{"test#3", "memory://", "memory://"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultConfig()
+			config.Database.URL = tt.args
+
+			var buffer bytes.Buffer
+			if err := writeConfig(&buffer, config.Redacted()); err != nil {
+				t.Fatalf("writeConfig() failed: %v", err)
+			}
+
+			if strings.Contains(buffer.String(), "secret") || !strings.Contains(buffer.String(), "url: "+tt.want) {
+				t.Errorf("writeConfig() = %s, want url: %s", buffer.String(), tt.want)
+			}
+		})
+	}
+}
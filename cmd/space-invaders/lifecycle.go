@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	zap "go.uber.org/zap"
+)
+
+// closer is a resource released after the servers have been shut down, e.g. the store.
+type closer struct {
+	name  string
+	close func() error
+}
+
+// endpoint is an HTTP server bound to its listener.
+type endpoint struct {
+	name     string
+	server   *http.Server
+	listener net.Listener
+}
+
+// listen binds the HTTP server to its address.
+func listen(name string, server *http.Server) (endpoint, error) {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return endpoint{}, fmt.Errorf("%s server: %w", name, err)
+	}
+
+	return endpoint{name: name, server: server, listener: listener}, nil
+}
+
+// notifyContext returns a context which is canceled on the first of the given signals.
+// The cause of the context names the signal.
+// After the first signal, the signals are restored to their default behavior, so that a second signal terminates the process.
+func notifyContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+
+	go func() {
+		defer signal.Stop(received)
+
+		select {
+		case sig := <-received:
+			cancel(fmt.Errorf("received signal: %s", sig))
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// serveGracefully serves the endpoints until the context is done or one of the servers fails.
+// It then shuts down the servers in the given order: the servers stop accepting connections
+// and the in-flight requests are drained until the shutdown timeout elapses, after which the remaining connections are closed.
+// Finally, the resources are closed in the given order.
+// Each phase of the shutdown is logged.
+func serveGracefully(ctx context.Context, shutdownTimeout time.Duration, endpoints []endpoint, closers ...closer) error {
+	failed := make(chan error, len(endpoints))
+	for _, e := range endpoints {
+		logger.Info("Listening", zap.String("server", e.name), zap.Stringer("address", e.listener.Addr()))
+
+		go func() {
+			if err := e.server.Serve(e.listener); !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("%s server: %w", e.name, err)
+			}
+		}()
+	}
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutting down", zap.NamedError("cause", context.Cause(ctx)), zap.Duration("timeout", shutdownTimeout))
+
+	case err := <-failed:
+		logger.Error("Unexpected server error, shutting down", zap.Error(err))
+		errs = append(errs, err)
+
+	}
+
+	// Drain the in-flight requests.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, e := range endpoints {
+		if err := e.server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to drain the connections, closing them", zap.String("server", e.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s server: %w", e.name, err), e.server.Close())
+			continue
+		}
+
+		logger.Info("Connections drained", zap.String("server", e.name), zap.Duration("elapsed", time.Since(start)))
+	}
+
+	// Release the resources.
+	for _, c := range closers {
+		logger.Info("Closing", zap.String("resource", c.name))
+		if err := c.close(); err != nil {
+			logger.Error("Failed to close", zap.String("resource", c.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+
+	logger.Info("Server stopped", zap.Duration("elapsed", time.Since(start)))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestEndpoint(t *testing.T, handler http.Handler) endpoint {
+	t.Helper()
+
+	e, err := listen("test", &http.Server{Addr: "127.0.0.1:0", Handler: handler})
+	if err != nil {
+		t.Fatalf("listen() failed: %v", err)
+	}
+
+	return e
+}
+
+func TestServeGracefully(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		timeout     time.Duration
+		wantBody    string
+		wantErr     bool
+		wantClosers []string
+	}{
+		{"test#1", time.Second, "done", false, []string{"aggregator", "store"}},
+		{"test#2", 10 * time.Millisecond, "", true, []string{"aggregator", "store"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			started, release := make(chan struct{}), make(chan struct{})
+			e := newTestEndpoint(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-release:
+				case <-time.After(time.Second):
+				}
+				_, _ = io.WriteString(w, "done")
+			}))
+
+			var closed []string
+			closers := []closer{
+				{"aggregator", func() error { closed = append(closed, "aggregator"); return nil }},
+				{"store", func() error { closed = append(closed, "store"); return nil }},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error, 1)
+			go func() { result <- serveGracefully(ctx, tt.timeout, []endpoint{e}, closers...) }()
+
+			// Start a request and shut down while it is in flight.
+			body := make(chan string, 1)
+			go func() {
+				resp, err := http.Get("http://" + e.listener.Addr().String())
+				if err != nil {
+					body <- ""
+					return
+				}
+				defer resp.Body.Close()
+
+				raw, _ := io.ReadAll(resp.Body)
+				body <- string(raw)
+			}()
+
+			<-started
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+
+			if got := <-body; got != tt.wantBody {
+				t.Errorf("in-flight response = %q, want %q", got, tt.wantBody)
+			}
+
+			if err := <-result; (err != nil) != tt.wantErr {
+				t.Errorf("serveGracefully() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(closed, tt.wantClosers) {
+				t.Errorf("closed = %v, want %v", closed, tt.wantClosers)
+			}
+
+			if _, err := http.Get("http://" + e.listener.Addr().String()); err == nil {
+				t.Errorf("server still accepts connections after shutdown")
+			}
+		})
+	}
+}
+
+func TestServeGracefullyClosers(t *testing.T) {
+	e := newTestEndpoint(t, http.NotFoundHandler())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	want := errors.New("failed to close")
+	err := serveGracefully(ctx, time.Second, []endpoint{e}, closer{"store", func() error { return want }})
+	if !errors.Is(err, want) {
+		t.Errorf("serveGracefully() error = %v, want %v", err, want)
+	}
+}
@@ -2,47 +2,32 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
 	"slices"
-	"strings"
-	"time"
+	"syscall"
 
-	gzip "github.com/gin-contrib/gzip"
-	ginzap "github.com/gin-contrib/zap"
 	gin "github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v5"
+	server "github.com/sarumaj/edu-space-invaders/src/pkg/server"
 	zap "go.uber.org/zap"
 	zapcore "go.uber.org/zap/zapcore"
-	postgres "gorm.io/driver/postgres"
-	gorm "gorm.io/gorm"
-)
-
-const (
-	defaultEndpoint = "index.html"      // defaultEndpoint is the default endpoint.
-	envVarPrefix    = "SPACE_INVADERS_" // envVarPrefix is the prefix for the environment variables.
 )
 
 var (
-	environ []string
-	logger  *zap.Logger
-
-	aesKey      = flag.String("aes-key", "aes_key.pem", "path to the AES key to encrypt and decrypt JWT tokens")
-	databaseURL = flag.String("database-url", getenv("DATABASE_URL", "postgres://postgres:pass@db:5432/postgres"), "database address")
-	forceSecure = flag.Bool("force-secure", getenv("FORCE_SECURE", false), "force secure connection over HTTPS")
-	limitRPS    = flag.Float64("limit-rps", 90, "requests per second for rate limiting")
-	limitBurst  = flag.Uint("limit-burst", 12, "burst size for rate limiting")
-	port        = flag.Uint("port", getenv[uint]("PORT", 8080), "port to listen on")
-	rsaKey      = flag.String("rsa-key", "rsa_key.pem", "path to the RSA key to sign and verify JWT tokens")
+	logger       *zap.Logger
+	serverConfig = defaultConfig() // serverConfig is the effective configuration of the server.
+
+	configFile  = flag.String("config", getenv("CONFIG_FILE", ""), "path to the YAML configuration file (env CONFIG_FILE)")
+	printConfig = flag.Bool("print-config", false, "print the effective configuration with the secrets redacted and exit")
 )
 
 // init runs the initialization code.
 func init() {
-	flag.Parse()
 	gin.SetMode(gin.ReleaseMode)
 
 	cfg := zap.NewDevelopmentEncoderConfig()
@@ -52,123 +37,149 @@ func init() {
 		zapcore.NewCore(enc, zapcore.Lock(os.Stdout), zap.LevelEnablerFunc(func(lvl zapcore.Level) bool { return lvl < zapcore.ErrorLevel })),
 		zapcore.NewCore(enc, zapcore.Lock(os.Stderr), zap.LevelEnablerFunc(func(lvl zapcore.Level) bool { return lvl >= zapcore.ErrorLevel })),
 	))
+
+	serverConfig.bind(flag.CommandLine)
 }
 
 // main is the entry point of the game server.
 func main() {
+	flag.Usage = usage
+	flag.Parse()
 	defer func() { _ = logger.Sync() }()
 
+	// Load the configuration, the flags take precedence over the environment variables and the configuration file.
+	var err error
+	serverConfig, err = loadConfig(*configFile, os.LookupEnv, flag.CommandLine)
+	if *printConfig {
+		if err := writeConfig(os.Stdout, serverConfig.Redacted()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	if *printConfig {
+		return
+	}
+
+	// Run the subcommand, if any.
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args(), os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Log the server start.
-	logger.Info("Starting server",
-		zap.Uintp("port", port),
-		zap.Boolp("forceSecure", forceSecure),
-		zap.Stringp("databaseURL", databaseURL),
-		zap.Stringp("aesKey", aesKey),
-		zap.Stringp("rsaKey", rsaKey),
-		zap.Float64p("limitRPS", limitRPS),
-		zap.Uintp("limitBurst", limitBurst),
-	)
+	logger.Info("Starting server", append([]zap.Field{zap.Stringp("config", configFile)}, serverConfig.Redacted().logFields()...)...)
 
 	// Load the environment variables.
-	environ = os.Environ()
+	environ := os.Environ()
 	slices.Sort(environ)
 	logger.Info("Environment variables", zap.Strings("environ", environ))
 
-	// Connect to the database.
-	dsn, err := parsePostgresURL(*databaseURL)
-	if err != nil {
-		logger.Fatal("Failed to parse database URL", zapcore.Field{Key: "error", Interface: err, Type: zapcore.ErrorType})
-	}
+	// Shut down on SIGINT or SIGTERM.
+	ctx, stop := notifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Connect to the database.
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Open the store.
+	store, err := server.OpenStore(serverConfig.Database, logger)
 	if err != nil {
-		logger.Fatal("Failed to connect to database", zapcore.Field{Key: "error", Interface: err, Type: zapcore.ErrorType})
+		logger.Fatal("Failed to open store", zapcore.Field{Key: "error", Interface: err, Type: zapcore.ErrorType})
 	}
 
-	// Migrate the database.
-	if !database.DryRun {
-		_ = database.AutoMigrate(&Metric{}, &Score{})
+	// Load the keys to sign and verify JWT tokens and to encrypt and decrypt session cookies.
+	keys, err := server.OpenKeySet(serverConfig.Keys.KeySet, serverConfig.Keys.RSAKey, serverConfig.Keys.AESKey)
+	if err != nil {
+		logger.Fatal("Failed to load keys", zap.Error(err))
 	}
 
-	// Define the skipper function.
-	skipper := func(c *gin.Context) bool {
-		switch c.Request.Method {
-		case http.MethodGet, http.MethodHead:
-			switch strings.TrimSuffix(c.Request.URL.Path, "/") {
-			case "/health", "/.env":
-				return true
+	// Reload the keys on SIGHUP.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := keys.Reload(); err != nil {
+				logger.Error("Failed to reload keys, keeping the previous keys", zap.Error(err))
+				continue
 			}
-		}
 
-		return false
-	}
+			signers, ciphers := keys.IDs()
+			logger.Info("Keys reloaded", zap.Strings("rsa", signers), zap.Strings("aes", ciphers))
+		}
+	}()
 
-	// Configure router.
-	router := gin.New(func(e *gin.Engine) {
-		e.Use(ginzap.GinzapWithConfig(logger, &ginzap.Config{
-			TimeFormat:   time.RFC3339,
-			UTC:          true,
-			DefaultLevel: zapcore.DebugLevel,
-			Skipper:      skipper,
-			Context:      func(ctx *gin.Context) []zapcore.Field { return []zapcore.Field{zap.Any("headers", ctx.Request.Header)} },
-		}))
-		e.Use(ginzap.CustomRecoveryWithZap(logger, true, func(c *gin.Context, err any) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%v", err)})
-		}))
-	})
+	signers, ciphers := keys.IDs()
+	logger.Info("Keys loaded", zap.Strings("rsa", signers), zap.Strings("aes", ciphers))
 
-	// Register the asymmetric JWT validator.
-	raw, err := os.ReadFile(*rsaKey)
+	// Replay the submitted game runs in processes of their own, see replayRun.
+	executable, err := os.Executable()
 	if err != nil {
-		logger.Fatal("Failed to read RSA key", zap.Error(err))
+		logger.Fatal("Failed to locate the executable", zap.Error(err))
 	}
 
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(raw)
+	// Configure the game server.
+	handler, err := server.New(server.Options{
+		Store:          store,
+		Keys:           keys,
+		Replayer:       server.CommandReplayer(executable, "replay", "run"),
+		Logger:         logger,
+		Environ:        environ,
+		Limits:         serverConfig.Limits,
+		Metrics:        serverConfig.Metrics,
+		Retention:      serverConfig.Retention,
+		Secure:         serverConfig.Server.ForceSecure,
+		TrustedProxies: serverConfig.Server.trustedProxies(),
+	})
 	if err != nil {
-		logger.Fatal("Failed to parse RSA key", zap.Error(err))
+		logger.Fatal("Failed to configure server", zap.Error(err))
 	}
 
-	// Register the symmetric AES cipher.
-	raw, err = os.ReadFile(*aesKey)
-	if err != nil {
-		logger.Fatal("Failed to read AES key", zap.Error(err))
+	// Configure the servers.
+	newServer := func(addr string, handler http.Handler) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadTimeout:       serverConfig.Server.ReadTimeout,
+			ReadHeaderTimeout: serverConfig.Server.ReadTimeout,
+			WriteTimeout:      serverConfig.Server.WriteTimeout,
+			IdleTimeout:       serverConfig.Server.IdleTimeout,
+			ErrorLog:          zap.NewStdLog(logger),
+		}
 	}
 
-	cryptKey, err := parseAES2GCMKeyFromPem(raw)
+	app, err := listen("game", newServer(fmt.Sprintf(":%d", serverConfig.Server.Port), handler))
 	if err != nil {
-		logger.Fatal("Failed to parse AES key", zap.Error(err))
+		logger.Fatal("Failed to listen", zap.Error(err))
 	}
 
-	logger.Info("Keys loaded")
-	jwtAuthenticator := AuthenticatorMiddleware(&key.PublicKey, cryptKey, map[string]string{
-		"header": "Authorization",
-		"query":  "token",
-		"cookie": "session",
-	})
+	// Report not to be ready and end the event streams once the server shuts down,
+	// so that they do not hold up the draining of the requests.
+	app.server.RegisterOnShutdown(handler.Drain)
+
+	endpoints := []endpoint{app}
+	if serverConfig.Metrics.Address != "" {
+		// Serve the metrics on a separate address, e.g. reachable from the internal network only.
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler.MetricsHandler())
+		metrics, err := listen("metrics", newServer(serverConfig.Metrics.Address, mux))
+		if err != nil {
+			logger.Fatal("Failed to listen", zap.Error(err))
+		}
+
+		endpoints = append(endpoints, metrics)
+	}
 
-	// Register the routes.
-	router.Use(
-		ApplySecurityHeadersMiddleware(*forceSecure),
-		CrossOriginResourceSharingMiddleware(*forceSecure),
-		SessionMiddleware(key, cryptKey, "session", time.Hour),
-		gzip.Gzip(gzip.BestCompression),
-		MetricsMiddleware(database, skipper),
-		HttpsRedirectMiddleware(*forceSecure),
-		CacheControlMiddleware(),
-		LimitMiddleware(*limitRPS, *limitBurst, nil),
-	)
-
-	router.POST("/.env", jwtAuthenticator, HandleEnv())
-	router.PUT("/scores.db", jwtAuthenticator, SaveScores(database))
-	router.Match([]string{http.MethodHead, http.MethodGet}, "/*filepath", BeHeadMiddleware(), ServeFileSystem(map[*regexp.Regexp]gin.HandlersChain{
-		regexp.MustCompile(`^/?health/?$`):      {HandleHealth(database)},
-		regexp.MustCompile(`^/?config\.ini/?$`): {jwtAuthenticator, GetConfig()},
-		regexp.MustCompile(`^/?\.env/?$`):       {jwtAuthenticator, HandleEnv()},
-		regexp.MustCompile(`^/?scores\.db/?$`):  {GetScores(database)},
-	}))
-
-	if err := router.Run(fmt.Sprintf(":%d", *port)); err != nil {
-		logger.Fatal("Unexpected server error", zap.Error(err))
+	// Serve until SIGINT or SIGTERM, then drain the requests, save the last batch of metrics and close the database.
+	if err := serveGracefully(ctx, serverConfig.Server.ShutdownTimeout, endpoints,
+		closer{"game server", handler.Close},
+		closer{"store", store.Close},
+	); err != nil {
+		logger.Fatal("Unclean shutdown", zap.Error(err))
 	}
 }
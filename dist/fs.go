@@ -15,13 +15,15 @@ import (
 	"time"
 )
 
-//go:embed *.html *.css *.js *.wasm *.ico audio/*.wav *.json icons/*.png external/*
+//go:embed *.html *.css *.js *.wasm *.ico audio/*.wav *.json icons/*.png external/* *.br *.gz
 var embeddedFsys embed.FS
 
 var _ http.File = httpFile{}
 
 var _ fs.FileInfo = httpFileInfo{}
 
+var buildTime, _ = time.Parse("2006-01-02T15:04:05.000Z-07:00", BuildTime())
+
 var hashMap = func() map[string]string {
 	hashes := make(map[string]string)
 
@@ -86,17 +88,37 @@ type httpFileInfo struct {
 func (h httpFileInfo) Name() string     { return filepath.Base(h.name) }
 func (h httpFileInfo) Size() int64      { return h.size }
 func (httpFileInfo) Mode() fs.FileMode  { return fs.FileMode(os.ModePerm) }
-func (httpFileInfo) ModTime() time.Time { return time.Time{} }
+func (httpFileInfo) ModTime() time.Time { return buildTime }
 func (httpFileInfo) IsDir() bool        { return false }
 func (httpFileInfo) Sys() any           { return nil }
 
+type modTimeFile struct{ http.File }
+
+func (m modTimeFile) Stat() (fs.FileInfo, error) {
+	info, err := m.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return modTimeFileInfo{info}, nil
+}
+
+type modTimeFileInfo struct{ fs.FileInfo }
+
+func (modTimeFileInfo) ModTime() time.Time { return buildTime }
+
 type httpFS struct{ fsys fs.FS }
 
 func (h *httpFS) FS() fs.FS { return h.fsys }
 
 func (h *httpFS) Open(name string) (http.File, error) {
 	if !strings.HasSuffix(strings.TrimSuffix(name, "/"), ".sha256") {
-		return http.FS(h.fsys).Open(name)
+		file, err := http.FS(h.fsys).Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return modTimeFile{file}, nil
 	}
 
 	if hash, ok := hashMap[strings.TrimSuffix(filepath.Base(name), ".sha256")]; ok {
@@ -22,7 +22,7 @@ function checkHealth() {
     return;
   }
 
-  fetch("health", {
+  fetch("/health", {
     method: "GET",
     headers: { Accept: "application/json" },
   })
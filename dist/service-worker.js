@@ -6,25 +6,25 @@ self.addEventListener("install", (event) => {
         // Pre-cache some static assets
         return cache.addAll([
           "/",
-          "/favicon.ico",
-          "/health-worker.js",
+          "/a/32d516f30bdff57319462a4f7521114d61b8fd2097fd44083a2467dc6ec7dd88/favicon.ico",
+          "/a/16eb1348589dd4dc35d7ca47c660196b292052707fe31633006d0fc24213dbd3/health-worker.js",
           "/index.html",
-          "/manifest.json",
-          "/style.css",
-          "/wasm.js",
-          "/audio/enemy_destroyed.wav",
-          "/audio/enemy_hit.wav",
-          "/audio/spaceship_acceleration.wav",
-          "/audio/spaceship_boost.wav",
-          "/audio/spaceship_cannon_fire.wav",
-          "/audio/spaceship_crash.wav",
-          "/audio/spaceship_deceleration.wav",
-          "/audio/spaceship_freeze.wav",
-          "/audio/spaceship_whoosh.wav",
+          "/a/df074c301cbe18b6d1e3826c519eb4c051e80d2837a7b8a9279358aad885f9b1/manifest.json",
+          "/a/375c8c070d42d81b38e530ded745cd8ed0c70456475a977c0ff2778e7d00d6d1/style.css",
+          "/a/e5e1c7e07b7d682a943270b639bcae96385901aa467fa2f437e20402d5df309e/wasm.js",
+          "/a/3cab83fd1be73e342968f75a4e8ec767eb3648bd328087664d4e52596167f897/audio/enemy_destroyed.wav",
+          "/a/4658514709eeaa838412caa5ba1fc0c00451c7de6d6ad1459c1bcbf035c34b83/audio/enemy_hit.wav",
+          "/a/235bf377862a216a10c4771db6d1aef33029b2e3dab30c9d2d647f2a0d56f424/audio/spaceship_acceleration.wav",
+          "/a/e763300a4d1aefe64a04e10322d342e018660f43af73d7771b298ee62693be19/audio/spaceship_boost.wav",
+          "/a/5c7625246072bb57ce6ba245e09a2a37c301021072108dcc7350923bb2f29663/audio/spaceship_cannon_fire.wav",
+          "/a/83066632d4c98e96411e6fd70fdc36683b5c959cc19c8d2c70b7a7e903d8723c/audio/spaceship_crash.wav",
+          "/a/940d3b21aa80b02ef94bd8ad7b407cb21abc35f54300421e4ba5ffb735639c77/audio/spaceship_deceleration.wav",
+          "/a/7ea4982c8743e667071fb1002026e4c8ff446ff1208a90bfc2d93b07abb52d23/audio/spaceship_freeze.wav",
+          "/a/b7577c040e1d4ca82913d78936568745f5fabc458043e5f9852a4d561951dfe3/audio/spaceship_whoosh.wav",
           "/audio/theme_heroic.wav",
-          "/icons/icon-192x192.png",
-          "/icons/icon-512-512.png",
-          "/external/ajax/libs/font-awesome/6.0.0/css/all.min.css",
+          "/a/6d6185c1bb6efaa18d5505969746fe5e6f7e2dbbee4984890b6fec844f6f9408/icons/icon-192x192.png",
+          "/a/631498437b419553458643b181bd04fbee28976c9d40388d180565ce35fb121f/icons/icon-512-512.png",
+          "/a/e5e202e3c899507992952533f57b634722b69b34241d271963559d31aa33ef81/external/ajax/libs/font-awesome/6.0.0/css/all.min.css",
         ]);
       })
       .catch((error) => {
@@ -37,15 +37,26 @@ self.addEventListener("fetch", (event) => {
   const isEnvRequest = event.request.url.includes("/.env");
   const isScoresRequest = event.request.url.includes("/scores.db");
   const isHealthRequest = event.request.url.includes("/health");
+  const isApiRequest = event.request.url.includes("/api/");
 
   if (
     event.request.method === "GET" &&
     !isEnvRequest &&
     !isScoresRequest &&
-    !isHealthRequest
+    !isHealthRequest &&
+    !isApiRequest
   ) {
+    // Content-addressed assets (/a/<sha256>/<path>) never change, so they need no revalidation
+    const isImmutableRequest = new URL(event.request.url).pathname.startsWith(
+      "/a/"
+    );
+
     event.respondWith(
       caches.match(event.request).then((cachedResponse) => {
+        if (cachedResponse && isImmutableRequest) {
+          return cachedResponse;
+        }
+
         if (cachedResponse) {
           const etag = cachedResponse.headers.get("ETag");
 
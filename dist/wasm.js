@@ -33,7 +33,7 @@ async function loadWasm() {
   try {
     // Load and instantiate the WebAssembly module
     const wasmModule = await WebAssembly.instantiateStreaming(
-      fetch("main.wasm"),
+      fetch("/a/6d251bf78453dcfd671c8fcb27d0460c704ad439932aee174dd95ba7e7be5ea8/main.wasm"),
       go.importObject
     );
 
@@ -51,7 +51,7 @@ async function loadWasm() {
     return;
   }
 
-  const worker = new Worker("health-worker.js");
+  const worker = new Worker("/a/16eb1348589dd4dc35d7ca47c660196b292052707fe31633006d0fc24213dbd3/health-worker.js");
 
   worker.onmessage = function (e) {
     switch (e.data.type) {
@@ -3,9 +3,10 @@
 set -e
 
 print_usage() {
-  echo "Usage: $0 [-k key_path] [--rsa-key key_path] [--ttl duration] [-s subject] [--subject subject]"
+  echo "Usage: $0 [-k key_path] [--rsa-key key_path] [--ttl duration] [-s subject] [--subject subject] [--scope scope]"
   echo "  -k, --rsa-key key_path   Path to the RSA key file (default: ./rsa.pem)"
   echo "  -s, --subject subject    Subject of the token (default: sarumaj)"
+  echo "  --scope scope            Space-separated scopes of the token: player, config:read, config:write, admin (default: player)"
   echo "  --ttl duration           Duration in seconds for which the token will be valid (default: 600)"
 }
 
@@ -18,6 +19,7 @@ base64_url_encode() {
 KEY_PATH="./rsa.pem"
 TTL="600"
 SUBJECT="sarumaj"
+SCOPE="player"
 
 # Parse command line options
 while [[ "$#" -gt 0 ]]; do
@@ -30,6 +32,10 @@ while [[ "$#" -gt 0 ]]; do
     SUBJECT="$2"
     shift 2
     ;;
+  --scope)
+    SCOPE="$2"
+    shift 2
+    ;;
   --ttl)
     TTL="$2"
     shift 2
@@ -65,11 +71,12 @@ header='{
 	"typ": "JWT"
 }'
 
-payload=$(jq -n --arg iat "$iat" --arg ttl "$TTL" --arg sub "$SUBJECT" '
+payload=$(jq -n --arg iat "$iat" --arg ttl "$TTL" --arg sub "$SUBJECT" --arg scope "$SCOPE" '
   .iat = ($iat | tonumber) |
   .iss = "space-invaders" |
   .sub = $sub |
   .aud = ["space-invaders"] |
+  .scope = $scope |
   if ($ttl | tonumber) > 0 then
     .exp = (($iat | tonumber) + ($ttl | tonumber))
   else
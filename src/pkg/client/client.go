@@ -0,0 +1,551 @@
+// Package client implements a typed client of the API of the game server described by openapi.yaml.
+// It is shared by the game compiled to WASM, which sends its requests through the Fetch API of the browser,
+// and by the native tools, e.g. the subcommands of the game server binary, which use the net/http package.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secureJSONPrefix is the prefix of JSON arrays served by the game server to prevent JSON hijacking.
+const secureJSONPrefix = "while(1);"
+
+// OpenAPI is the description of the API implemented by the client.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// Client is a client of the API of the game server.
+// It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	token      string
+}
+
+// Option configures the client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client sending the requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithToken sets the JWT token sent in the Authorization header of the requests.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = strings.TrimPrefix(strings.TrimSpace(token), "Bearer ") }
+}
+
+// Error represents an error response of the game server.
+type Error struct {
+	StatusCode int           // StatusCode is the HTTP status of the response.
+	Message    string        // Message is the error reported by the server or the body of the response.
+	Scope      string        // Scope is the missing scope of a 403 response.
+	RetryAfter time.Duration // RetryAfter is the delay requested by a 429 response.
+}
+
+// Error returns the status and the message of the error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("server responded with %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// StatusCode returns the HTTP status of the error response, or 0 if err is not an error response.
+func StatusCode(err error) int {
+	var target *Error
+	if errors.As(err, &target) {
+		return target.StatusCode
+	}
+
+	return 0
+}
+
+// New returns a client of the game server at the base URL.
+// If the base URL is empty, the client of the game addresses the origin of the document (see defaultBaseURL).
+func New(baseURL string, options ...Option) (*Client, error) {
+	if baseURL == "" {
+		baseURL = defaultBaseURL()
+	}
+
+	if baseURL == "" {
+		return nil, fmt.Errorf("missing base URL")
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("base URL must be absolute: %q", baseURL)
+	}
+
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/") + "/"
+	c := &Client{baseURL: parsed, httpClient: &http.Client{Transport: defaultTransport()}}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+// ClaimName claims the name for the player of the session, the name is reserved for the player from then on.
+// It returns the identity of the player along with the recovery code, which is returned only once.
+// A name taken by another player or a session which has claimed a name already is reported by the status conflict.
+func (c *Client) ClaimName(ctx context.Context, name string) (PlayerCredentials, error) {
+	var credentials PlayerCredentials
+	err := c.do(ctx, http.MethodPost, "api/v1/session/player", nil, PlayerClaim{Name: name}, &credentials)
+	return credentials, err
+}
+
+// ExportTable writes the rows of the table (TableMetrics or TableScores) in the format (FormatCSV, FormatJSON or FormatNDJSON)
+// to the writer as they are streamed by the game server. An empty format selects JSON.
+func (c *Client) ExportTable(ctx context.Context, table, format string, writer io.Writer) error {
+	values := url.Values{}
+	setQuery(values, "format", format)
+
+	response, err := c.send(ctx, http.MethodGet, "api/v1/admin/export/"+url.PathEscape(table), values, nil, "*/*")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		raw, _ := io.ReadAll(response.Body)
+		return newError(response, raw, nil)
+	}
+
+	if _, err := io.Copy(writer, response.Body); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEvents returns the events of the audit log matching the query, the newest first.
+func (c *Client) GetAuditEvents(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	values := url.Values{}
+	setQuery(values, "actor", query.Actor)
+	setQuery(values, "action", query.Action)
+	if !query.Since.IsZero() {
+		values.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if !query.Until.IsZero() {
+		values.Set("until", query.Until.Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	var response struct {
+		Events []AuditEvent `json:"events"`
+	}
+	err := c.do(ctx, http.MethodGet, "api/v1/admin/audit", values, nil, &response)
+	return response.Events, err
+}
+
+// GetConfig returns the configuration of the game engine in the TOML format.
+func (c *Client) GetConfig(ctx context.Context) (string, error) {
+	var raw []byte
+	err := c.do(ctx, http.MethodGet, "config.ini", nil, nil, &raw)
+	return string(raw), err
+}
+
+// GetEnv returns the feature flags effective for the caller, including the overrides of its player.
+// If player is not empty, the overrides of the given player apply instead, which requires the config:read scope.
+func (c *Client) GetEnv(ctx context.Context, player string) (Env, error) {
+	values := url.Values{}
+	setQuery(values, "player", player)
+	return c.env(ctx, http.MethodGet, values, nil)
+}
+
+// GetFlagHistory returns the most recent changes of the feature flags, the newest first.
+// If key is not empty, only the changes of the given flag are returned, limit defaults to 100.
+func (c *Client) GetFlagHistory(ctx context.Context, key string, limit int) ([]FeatureFlagChange, error) {
+	values := url.Values{}
+	setQuery(values, "key", key)
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+
+	var response struct {
+		Changes []FeatureFlagChange `json:"changes"`
+	}
+	err := c.do(ctx, http.MethodGet, "api/v1/flags/history", values, nil, &response)
+	return response.Changes, err
+}
+
+// GetHealth returns the health of the server.
+func (c *Client) GetHealth(ctx context.Context) (Health, error) {
+	var health Health
+	err := c.do(ctx, http.MethodGet, "health", nil, nil, &health)
+	return health, err
+}
+
+// GetLeaderboard returns a page of the leaderboard.
+func (c *Client) GetLeaderboard(ctx context.Context, query LeaderboardQuery) (Leaderboard, error) {
+	values := url.Values{}
+	setQuery(values, "around", query.Around)
+	setQuery(values, "period", query.Period)
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset > 0 {
+		values.Set("offset", strconv.Itoa(query.Offset))
+	}
+
+	var board Leaderboard
+	err := c.do(ctx, http.MethodGet, "api/v1/leaderboard", values, nil, &board)
+	return board, err
+}
+
+// GetLiveness returns the liveness of the process.
+func (c *Client) GetLiveness(ctx context.Context) (ProbeReport, error) {
+	return c.probe(ctx, "livez")
+}
+
+// GetMetrics returns the operational metrics in the Prometheus text exposition format.
+func (c *Client) GetMetrics(ctx context.Context) (string, error) {
+	var raw []byte
+	err := c.do(ctx, http.MethodGet, "metrics", nil, nil, &raw)
+	return string(raw), err
+}
+
+// GetOpenAPI returns the description of the API served by the game server.
+func (c *Client) GetOpenAPI(ctx context.Context) ([]byte, error) {
+	var raw []byte
+	err := c.do(ctx, http.MethodGet, "api/v1/openapi.yaml", nil, nil, &raw)
+	return raw, err
+}
+
+// GetPlayer returns the profile of the player.
+func (c *Client) GetPlayer(ctx context.Context, name string) (PlayerProfile, error) {
+	var profile PlayerProfile
+	err := c.do(ctx, http.MethodGet, "api/v1/players/"+name, nil, nil, &profile)
+	return profile, err
+}
+
+// GetReadiness returns the readiness of the server.
+// If a check fails, the report is returned along with the error.
+func (c *Client) GetReadiness(ctx context.Context) (ProbeReport, error) {
+	return c.probe(ctx, "readyz")
+}
+
+// GetRetention returns the configuration of the retention job and the reports of its most recent runs.
+func (c *Client) GetRetention(ctx context.Context) (Retention, error) {
+	var retention Retention
+	err := c.do(ctx, http.MethodGet, "api/v1/admin/retention", nil, nil, &retention)
+	return retention, err
+}
+
+// GetScores returns the best scores of all players, the highest first.
+func (c *Client) GetScores(ctx context.Context) ([]Score, error) {
+	var scores []Score
+	err := c.do(ctx, http.MethodGet, "scores.db", nil, nil, &scores)
+	return scores, err
+}
+
+// GetSessionPlayer returns the player of the session.
+// A session which has not claimed a name yet is reported by the status not found.
+func (c *Client) GetSessionPlayer(ctx context.Context) (Player, error) {
+	var player Player
+	err := c.do(ctx, http.MethodGet, "api/v1/session/player", nil, nil, &player)
+	return player, err
+}
+
+// ImportScores merges the scores read from the reader in the format of the options with the existing scores
+// and returns the report of the changes. In dry-run mode, the changes are reported without being saved.
+func (c *Client) ImportScores(ctx context.Context, reader io.Reader, options ImportOptions) (ImportReport, error) {
+	values := url.Values{}
+	setQuery(values, "format", options.Format)
+	setQuery(values, "mode", options.Mode)
+	if options.DryRun {
+		values.Set("dry_run", "true")
+	}
+
+	var report ImportReport
+	err := c.do(ctx, http.MethodPost, "api/v1/admin/import/scores", values, rawBody{Reader: reader, contentType: mediaTypes[options.Format]}, &report)
+	return report, err
+}
+
+// IssueRecoveryCode issues a new recovery code for the player of the given name, e.g. for a name reserved by an import.
+// The previous recovery code of the player is revoked.
+func (c *Client) IssueRecoveryCode(ctx context.Context, name string) (PlayerCredentials, error) {
+	var credentials PlayerCredentials
+	err := c.do(ctx, http.MethodPost, "api/v1/admin/players/"+url.PathEscape(name)+"/recovery-code", nil, nil, &credentials)
+	return credentials, err
+}
+
+// PostNotice pushes the notice to all clients subscribed to the events of the game server.
+// It returns the number of subscribers.
+func (c *Client) PostNotice(ctx context.Context, message string) (int, error) {
+	var response struct {
+		Subscribers int `json:"subscribers"`
+	}
+	err := c.do(ctx, http.MethodPost, "api/v1/admin/notices", nil, Notice{Message: message}, &response)
+	return response.Subscribers, err
+}
+
+// RecoverPlayer restores the identity of the player of the recovery code, e.g. on another device.
+// The game server binds the session cookie to the player.
+func (c *Client) RecoverPlayer(ctx context.Context, code string) (Player, error) {
+	var player Player
+	err := c.do(ctx, http.MethodPost, "api/v1/session/recover", nil, PlayerRecovery{RecoveryCode: code}, &player)
+	return player, err
+}
+
+// RenewRecoveryCode issues a new recovery code for the player of the session and revokes the previous one.
+func (c *Client) RenewRecoveryCode(ctx context.Context) (PlayerCredentials, error) {
+	var credentials PlayerCredentials
+	err := c.do(ctx, http.MethodPost, "api/v1/session/player/recovery-code", nil, nil, &credentials)
+	return credentials, err
+}
+
+// RunRetention runs the retention job immediately and returns its report.
+// If dryRun is true, the rows to be deleted are counted only.
+func (c *Client) RunRetention(ctx context.Context, dryRun bool) (RetentionReport, error) {
+	values := url.Values{"dry_run": {strconv.FormatBool(dryRun)}}
+	var report RetentionReport
+	err := c.do(ctx, http.MethodPost, "api/v1/admin/retention", values, nil, &report)
+	return report, err
+}
+
+// SaveScores saves the scores, keeping the higher score of each player.
+func (c *Client) SaveScores(ctx context.Context, scores []Score) error {
+	return c.do(ctx, http.MethodPut, "scores.db", nil, scores, nil)
+}
+
+// SetEnv sets the feature flags, a nil value unsets the flag.
+// The flags are set for the given scope, i.e. globally or for the player or session given by subject.
+// It returns the feature flags effective for the target of the changes.
+func (c *Client) SetEnv(ctx context.Context, flags map[string]*string, scope, subject string) (Env, error) {
+	values := url.Values{}
+	setQuery(values, "scope", scope)
+	setQuery(values, "subject", subject)
+	return c.env(ctx, http.MethodPost, values, flags)
+}
+
+// StreamEvents subscribes to the events pushed by the game server and passes them to handle in order.
+// The changes of the feature flags of the player of the caller are pushed as well.
+// It returns once the context is done, the server ends the stream (nil), or handle fails.
+func (c *Client) StreamEvents(ctx context.Context, handle func(Event) error) error {
+	response, err := c.send(ctx, http.MethodGet, "api/v1/events", nil, nil, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		raw, _ := io.ReadAll(response.Body)
+		return newError(response, raw, nil)
+	}
+
+	var event Event
+	var data []string
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			// A blank line dispatches the event, a line starting with a colon is a comment.
+			if scanner.Text() != "" || len(data) == 0 {
+				continue
+			}
+
+			event.Data = json.RawMessage(strings.Join(data, "\n"))
+			if err := handle(event); err != nil {
+				return err
+			}
+			event, data = Event{ID: event.ID}, nil
+
+		case "id":
+			event.ID = value
+
+		case "event":
+			event.Type = value
+
+		case "data":
+			data = append(data, value)
+
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return scanner.Err()
+}
+
+// SubmitScore submits the result of a game run.
+// It returns the best score of the player and its rank on the leaderboard.
+// A run which could not be verified by replaying it is not ranked, see ScoreResult.Verified.
+func (c *Client) SubmitScore(ctx context.Context, submission ScoreSubmission) (ScoreResult, error) {
+	var result ScoreResult
+	err := c.do(ctx, http.MethodPost, "scores", nil, submission, &result)
+	return result, err
+}
+
+// do sends the request and decodes the response into out, unless out is nil.
+// The body is encoded as JSON, unless nil. A response body decoded into a byte slice is returned as is.
+// Responses with a status other than 2xx are returned as *Error, the body is decoded into out nonetheless
+// if it is a JSON object, e.g. the report of a failing probe.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	response, err := c.send(ctx, method, path, query, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	raw, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	raw = bytes.TrimPrefix(raw, []byte(secureJSONPrefix))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return newError(response, raw, out)
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+
+	case *[]byte:
+		*out = raw
+		return nil
+
+	default:
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+		return nil
+
+	}
+}
+
+// send sends the request accepting the given media type and returns the response regardless of its status.
+// The body is encoded as JSON, unless nil or a rawBody sent as is.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, accept string) (*http.Response, error) {
+	target := c.baseURL.JoinPath(path)
+	if len(query) > 0 {
+		target.RawQuery = query.Encode()
+	}
+
+	var reader io.Reader
+	contentType := "application/json"
+	switch body := body.(type) {
+	case nil:
+
+	case rawBody:
+		reader, contentType = body.Reader, body.contentType
+
+	default:
+		serialized, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize request: %w", err)
+		}
+		reader = bytes.NewReader(serialized)
+
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", accept)
+	if reader != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	return response, nil
+}
+
+// env sends the request to the .env endpoint and returns the prefixed feature flags of the response.
+func (c *Client) env(ctx context.Context, method string, query url.Values, flags map[string]*string) (Env, error) {
+	var body any
+	if flags != nil {
+		body = flags
+	}
+
+	var response map[string]any
+	if err := c.do(ctx, method, ".env", query, body, &response); err != nil {
+		return nil, err
+	}
+
+	prefix, _ := response["_prefix"].(string)
+	env := make(Env, len(response))
+	for key, value := range response {
+		if prefix != "" && strings.HasPrefix(key, prefix) {
+			env[key] = fmt.Sprint(value)
+		}
+	}
+
+	return env, nil
+}
+
+// probe returns the report of the probe at the given path.
+func (c *Client) probe(ctx context.Context, path string) (ProbeReport, error) {
+	var report ProbeReport
+	err := c.do(ctx, http.MethodGet, path, nil, nil, &report)
+	return report, err
+}
+
+// newError returns the error of the response.
+// A JSON object body is decoded into out, if it is not a byte slice.
+func newError(response *http.Response, raw []byte, out any) error {
+	err := &Error{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(raw))}
+
+	var body struct {
+		Error string `json:"error"`
+		Scope string `json:"scope"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+		err.Message, err.Scope = body.Error, body.Scope
+	}
+
+	if seconds, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	if _, isRaw := out.(*[]byte); out != nil && !isRaw && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		_ = json.Unmarshal(raw, out)
+	}
+
+	return err
+}
+
+// rawBody is a request body sent as is with the given content type.
+type rawBody struct {
+	io.Reader
+	contentType string
+}
+
+// setQuery sets the query parameter, unless the value is empty.
+func setQuery(values url.Values, key, value string) {
+	if value != "" {
+		values.Set(key, value)
+	}
+}
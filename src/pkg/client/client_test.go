@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestClientImplementsOpenAPI(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string `yaml:"operationId"`
+		} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(OpenAPI, &spec); err != nil {
+		t.Fatalf("failed to parse openapi.yaml: %v", err)
+	}
+
+	client := reflect.TypeOf(&Client{})
+	operations := 0
+	for path, methods := range spec.Paths {
+		for method, operation := range methods {
+			name := strings.ToUpper(operation.OperationID[:1]) + operation.OperationID[1:]
+			if _, ok := client.MethodByName(name); !ok {
+				t.Errorf("%s %s: Client does not implement %s", strings.ToUpper(method), path, name)
+			}
+			operations++
+		}
+	}
+
+	if operations != client.NumMethod() {
+		t.Errorf("openapi.yaml describes %d operations, Client implements %d", operations, client.NumMethod())
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /base/scores.db":
+			_, _ = w.Write([]byte(`while(1);[{"name":"a","score":3}]`))
+
+		case "GET /base/.env":
+			_, _ = w.Write([]byte(`{"SPACE_INVADERS_A":"` + r.URL.Query().Get("player") + `","OTHER":"x","_size":2,"_prefix":"SPACE_INVADERS_"}`))
+
+		case "POST /base/scores":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"missing token"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"a","score":3,"rank":1}`))
+
+		case "GET /base/readyz":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"fail","checks":{"database":{"status":"fail","error":"down"}}}`))
+
+		default:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"rate limit exceeded"}`))
+
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	if _, err := New(""); err == nil {
+		t.Errorf("New(\"\") succeeded, want error")
+	}
+
+	c, err := New(server.URL+"/base", WithToken("Bearer token"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	scores, err := c.GetScores(ctx)
+	if err != nil || len(scores) != 1 || scores[0].Name != "a" || scores[0].Score != 3 {
+		t.Errorf("GetScores() = (%v, %v), want the score of a", scores, err)
+	}
+
+	env, err := c.GetEnv(ctx, "p")
+	if err != nil || !reflect.DeepEqual(env, Env{"SPACE_INVADERS_A": "p"}) {
+		t.Errorf("GetEnv() = (%v, %v), want the prefixed flags", env, err)
+	}
+
+	result, err := c.SubmitScore(ctx, ScoreSubmission{Name: "a", Level: 3, Duration: time.Second})
+	if err != nil || result.Rank != 1 {
+		t.Errorf("SubmitScore() = (%v, %v), want rank 1", result, err)
+	}
+
+	unauthenticated, _ := New(server.URL + "/base")
+	if _, err := unauthenticated.SubmitScore(ctx, ScoreSubmission{}); StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("SubmitScore() without token = %v, want status %d", err, http.StatusUnauthorized)
+	}
+
+	report, err := c.GetReadiness(ctx)
+	if StatusCode(err) != http.StatusServiceUnavailable || report.Checks["database"].Error != "down" {
+		t.Errorf("GetReadiness() = (%v, %v), want the failing report", report, err)
+	}
+
+	_, err = c.GetLeaderboard(ctx, LeaderboardQuery{Limit: 5})
+	if target, ok := err.(*Error); !ok || target.RetryAfter != 2*time.Second || target.Message != "rate limit exceeded" {
+		t.Errorf("GetLeaderboard() = %v, want rate limit error", err)
+	}
+}
+
+func TestIsRecoveryCode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args string
+		want bool
+	}{
+		{"test#1", "ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23-4567", true},
+		{"test#2", " abcd-efgh-ijkl-mnop-qrst-uvwx-yz23-4567 ", true},
+		{"test#3", "ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23", false},
+		{"test#4", "ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23-4561", false},
+		{"test#5", "Commandant", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRecoveryCode(tt.args); got != tt.want {
+				t.Errorf("IsRecoveryCode(%q) = %t, want %t", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+//go:build js && wasm
+
+package client
+
+import (
+	"net/http"
+	"syscall/js"
+)
+
+// fetchTransport sends the requests through the Fetch API of the browser.
+// The default transport of the net/http package is backed by the Fetch API when compiled to WASM,
+// the options of fetch are given by the js.fetch headers.
+type fetchTransport struct{}
+
+// RoundTrip sends the request including the session cookie of the same origin.
+func (fetchTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("js.fetch:credentials", "same-origin")
+	request.Header.Set("js.fetch:mode", "cors")
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// defaultBaseURL returns the origin of the document, e.g. https://space-invaders.sarumaj.com.
+func defaultBaseURL() string {
+	return js.Global().Get("location").Get("origin").String()
+}
+
+// defaultTransport returns the transport sending the requests through the Fetch API.
+func defaultTransport() http.RoundTripper {
+	return fetchTransport{}
+}
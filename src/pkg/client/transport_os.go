@@ -0,0 +1,11 @@
+//go:build !js || !wasm
+
+package client
+
+import "net/http"
+
+// defaultBaseURL returns an empty base URL, native clients must be given the address of the game server.
+func defaultBaseURL() string { return "" }
+
+// defaultTransport returns the default transport of the net/http package.
+func defaultTransport() http.RoundTripper { return http.DefaultTransport }
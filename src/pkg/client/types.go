@@ -0,0 +1,319 @@
+package client
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	EventFlags  = "flags"  // EventFlags announces changes of the feature flags concerning the subscriber, the data lists their keys.
+	EventNotice = "notice" // EventNotice carries a Notice of the operators.
+	EventScore  = "score"  // EventScore announces a new best score among the top scores, the data is a ScoreResult.
+)
+
+const (
+	FormatCSV    = "csv"    // FormatCSV is a header row followed by a row per record.
+	FormatJSON   = "json"   // FormatJSON is an array of objects.
+	FormatNDJSON = "ndjson" // FormatNDJSON is an object per line.
+)
+
+const (
+	ImportKeepHigher   = "keep-higher"   // ImportKeepHigher replaces existing scores by higher ones.
+	ImportOverwrite    = "overwrite"     // ImportOverwrite replaces existing scores by different ones.
+	ImportSkipExisting = "skip-existing" // ImportSkipExisting imports the scores of new players only.
+)
+
+const (
+	TableMetrics = "metrics" // TableMetrics is the table of the request counts per endpoint and method.
+	TableScores  = "scores"  // TableScores is the table of the best scores of the players.
+)
+
+// mediaTypes maps the formats to their media types.
+var mediaTypes = map[string]string{
+	FormatCSV:    "text/csv",
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+const (
+	FlagScopeGlobal  = "global"  // FlagScopeGlobal is the scope of the feature flags applying to all players.
+	FlagScopePlayer  = "player"  // FlagScopePlayer is the scope of the feature flags overridden for a player.
+	FlagScopeSession = "session" // FlagScopeSession is the scope of the feature flags overridden for a session.
+)
+
+// AuditEvent represents a privileged action recorded in the audit log.
+type AuditEvent struct {
+	ID         uint64          `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Status     int             `json:"status"`
+	ClientIP   string          `json:"client_ip"`
+	RequestID  string          `json:"request_id"`
+}
+
+// AuditQuery represents the filter of the audit log, zero values are omitted.
+type AuditQuery struct {
+	Actor  string
+	Action string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Env represents the feature flags effective for the caller, keyed by the environment variables.
+type Env map[string]string
+
+// Event represents an event pushed by the game server.
+type Event struct {
+	ID   string
+	Type string
+	Data json.RawMessage
+}
+
+// Decode decodes the data of the event into out.
+func (e Event) Decode(out any) error {
+	return json.Unmarshal(e.Data, out)
+}
+
+// FeatureFlagChange represents a change of a feature flag.
+type FeatureFlagChange struct {
+	ID        uint64    `json:"id"`
+	Key       string    `json:"key"`
+	Scope     string    `json:"scope"`
+	Subject   string    `json:"subject,omitempty"`
+	Value     *string   `json:"value"`    // Value is the new value, nil if the flag has been unset.
+	Previous  *string   `json:"previous"` // Previous is the previous value, nil if the flag has not been set.
+	ChangedAt time.Time `json:"changed_at"`
+	ChangedBy string    `json:"changed_by"`
+}
+
+// Health represents the health of the server.
+type Health struct {
+	BootTime  string         `json:"BootTime"`
+	BuildTime string         `json:"BuildTime"`
+	Current   string         `json:"Current"`
+	Metrics   map[string]any `json:"Metrics"`
+	Status    string         `json:"Status"`
+	UpTime    string         `json:"UpTime"`
+}
+
+// ImportOptions represents the options of an import of scores, zero values select the defaults of the server.
+type ImportOptions struct {
+	Format string // Format is FormatCSV, FormatJSON (default) or FormatNDJSON.
+	Mode   string // Mode is ImportKeepHigher (default), ImportOverwrite or ImportSkipExisting.
+	DryRun bool   // DryRun reports the changes without saving them.
+}
+
+// ImportReport represents the outcome of an import of scores.
+type ImportReport struct {
+	Mode      string        `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Total     int           `json:"total"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Changes   []ScoreChange `json:"changes"` // Changes are the first 100 changes.
+}
+
+// GameFlags represents the feature flags which affect the course of a game run, recorded along with the inputs, see Replay.
+type GameFlags struct {
+	Frame                     int64 `json:"f"`            // Frame is the frame of the game run the flags have taken effect at.
+	CollisionDetectionVersion int   `json:"cdv"`          // CollisionDetectionVersion is the version of the collision detection.
+	GodMode                   bool  `json:"gm,omitempty"` // GodMode is true if the spaceship is invincible, such runs are not ranked.
+	PlanetChoice              int   `json:"pc"`           // PlanetChoice is the planet forced to be drawn, -1 for random.
+	RepelEnemies              bool  `json:"re,omitempty"` // RepelEnemies is true if the enemies are repelled by the spaceship, such runs are not ranked.
+}
+
+// Input represents a single input of the player recorded during a game run, see Replay.
+type Input struct {
+	Frame    int64   `json:"f"`            // Frame is the frame of the game run the input has been handled at.
+	Device   string  `json:"d"`            // Device is "k" for the keyboard, "m" for the mouse or "t" for a touch screen.
+	Key      string  `json:"k,omitempty"`  // Key is the key of a key event.
+	Button   int     `json:"b,omitempty"`  // Button is the mouse button of a mouse event.
+	Type     int     `json:"t,omitempty"`  // Type is the type of a mouse or touch event.
+	Pressed  bool    `json:"p,omitempty"`  // Pressed is true if the key or mouse button is pressed.
+	MultiTap bool    `json:"mt,omitempty"` // MultiTap is true if a touch event has correlated touches.
+	X        float64 `json:"x,omitempty"`  // X is the horizontal position on the canvas a mouse or touch event moves to.
+	Y        float64 `json:"y,omitempty"`  // Y is the vertical position on the canvas a mouse or touch event moves to.
+}
+
+// Leaderboard represents a page of the leaderboard.
+type Leaderboard struct {
+	Entries []LeaderboardEntry `json:"entries"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	Period  string             `json:"period"`
+	Self    *LeaderboardEntry  `json:"self,omitempty"` // Self is the best ranked entry of the caller.
+	Total   int64              `json:"total"`
+}
+
+// LeaderboardEntry represents a ranked score.
+type LeaderboardEntry struct {
+	Score
+	Rank int64 `json:"rank"`
+}
+
+// LeaderboardQuery represents the selection of a page of the leaderboard, zero values are omitted.
+type LeaderboardQuery struct {
+	Around string // Around is the name of the player to center the page around.
+	Limit  int
+	Offset int
+	Period string // Period is daily, weekly or all.
+}
+
+// Notice represents a notice of the operators pushed to the clients.
+type Notice struct {
+	Message string `json:"message"`
+}
+
+// Player represents the identity of a player, see Client.GetSessionPlayer.
+type Player struct {
+	ID        string     `json:"id"` // ID is the subject of the sessions of the player.
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// PlayerClaim represents the claim of a name by the player of the session.
+type PlayerClaim struct {
+	Name string `json:"name"`
+}
+
+// PlayerCredentials represents the identity of a player along with a new recovery code.
+// The recovery code is returned only once, it restores the identity on another device, see Client.RecoverPlayer.
+type PlayerCredentials struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// PlayerProfile represents the statistics of a player.
+type PlayerProfile struct {
+	Name              string     `json:"name"`
+	AverageLevel      float64    `json:"average_level"`
+	BestScore         int64      `json:"best_score"`
+	DiscoveredPlanets []string   `json:"discovered_planets"`
+	Rank              int64      `json:"rank"`
+	RecentRuns        []ScoreRun `json:"recent_runs"`
+	RunCount          int64      `json:"run_count"`
+}
+
+// PlayerRecovery represents the recovery code of a player.
+type PlayerRecovery struct {
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// ProbeReport represents the outcome of the checks of a probe.
+type ProbeReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]ProbeResult `json:"checks"`
+}
+
+// ProbeResult represents the outcome of a single check of a probe.
+type ProbeResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Replay represents the record of a game run, which the server replays to verify the submitted level.
+type Replay struct {
+	Seed   uint64  `json:"seed"`   // Seed is the seed of the random numbers of the game run.
+	Frames int64   `json:"frames"` // Frames is the number of frames of the game run.
+	Inputs []Input `json:"inputs"` // Inputs are the inputs of the player in the order they have been handled.
+
+	// Flags are the game flags effective at the start of the game run, followed by their changes in the order of their frames.
+	Flags []GameFlags `json:"flags,omitempty"`
+}
+
+// Retention represents the configuration of the retention job and the reports of its most recent runs.
+type Retention struct {
+	Config struct {
+		DryRun           bool   `json:"dry_run"`
+		Interval         string `json:"interval"`
+		KeepScores       int    `json:"keep_scores"`
+		MaxSize          int64  `json:"max_size"`
+		MetricAge        string `json:"metric_age"`
+		UnverifiedRunAge string `json:"unverified_run_age"`
+	} `json:"config"`
+	Reports []RetentionReport `json:"reports"`
+}
+
+// RetentionReport represents the outcome of a run of the retention job.
+type RetentionReport struct {
+	StartedAt      time.Time `json:"started_at"`
+	Duration       string    `json:"duration"`
+	DryRun         bool      `json:"dry_run"`
+	Size           int64     `json:"size"`
+	SizeExceeded   bool      `json:"size_exceeded"`
+	Metrics        int64     `json:"metrics"`
+	Scores         int64     `json:"scores"`
+	Runs           int64     `json:"runs"`
+	UnverifiedRuns int64     `json:"unverified_runs"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Score represents the best score of a player.
+type Score struct {
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Name      string     `json:"name"`
+	Score     int64      `json:"score"`
+}
+
+// ScoreChange represents the change of the score of a player by an import.
+type ScoreChange struct {
+	Name   string `json:"name"`
+	Before *int64 `json:"before"` // Before is nil if the player is new.
+	After  int64  `json:"after"`
+}
+
+// ScoreResult represents the outcome of a score submission.
+type ScoreResult struct {
+	Name     string `json:"name"`
+	Score    int64  `json:"score"`    // Score is the best score of the player.
+	Rank     int64  `json:"rank"`     // Rank is the position of the best score on the leaderboard.
+	Verified bool   `json:"verified"` // Verified is false if the submitted run could not be verified and has been set aside.
+}
+
+// ScoreRun represents a single game run of a player.
+type ScoreRun struct {
+	ID                uint64        `json:"id"`
+	CreatedAt         *time.Time    `json:"created_at,omitempty"`
+	Name              string        `json:"name"`
+	Level             int64         `json:"level"`
+	DiscoveredPlanets []string      `json:"discovered_planets"`
+	Duration          time.Duration `json:"duration"`
+	EnemyKills        int64         `json:"enemy_kills"`
+	CauseOfDeath      string        `json:"cause_of_death"`
+}
+
+// ScoreSubmission represents the result of a single game run.
+type ScoreSubmission struct {
+	Name              string        `json:"name"`
+	Level             int64         `json:"level"`
+	DiscoveredPlanets []string      `json:"discovered_planets"`
+	Duration          time.Duration `json:"duration"`
+	EnemyKills        int64         `json:"enemy_kills"`
+	CauseOfDeath      string        `json:"cause_of_death"`
+	Replay            *Replay       `json:"replay,omitempty"` // Replay verifies the level, runs without a replay are not ranked.
+}
+
+// recoveryCodePattern matches the recovery codes of the players, eight groups of four base32 characters.
+var recoveryCodePattern = regexp.MustCompile(`^[A-Z2-7]{4}(-[A-Z2-7]{4}){7}$`)
+
+// IsRecoveryCode returns true if the text is formatted like a recovery code, regardless of the case.
+func IsRecoveryCode(text string) bool {
+	return recoveryCodePattern.MatchString(NormalizeRecoveryCode(text))
+}
+
+// NormalizeRecoveryCode returns the recovery code in upper case without surrounding spaces.
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// epoch is the game time of the first frame of a game run.
+var epoch = time.Unix(0, 0).UTC()
+
+// frames is the number of frames of the current game run.
+// It is the time base of the game objects, so that a game run does not depend on the frame rate
+// nor on the wall-clock time, and can be replayed.
+var frames atomic.Int64
+
+// Frame returns the number of frames of the current game run.
+func Frame() int64 { return frames.Load() }
+
+// Now returns the game time, i.e. the duration of the frames of the current game run
+// at the desired frame rate since the epoch.
+func Now() time.Time {
+	return epoch.Add(time.Duration(float64(frames.Load()) * float64(time.Second) / Config.Control.DesiredFramesPerSecondRate))
+}
+
+// ResetClock resets the game clock to the first frame.
+func ResetClock() { frames.Store(0) }
+
+// Tick advances the game clock by a single frame.
+func Tick() { frames.Add(1) }
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	ResetClock()
+	start := Now()
+
+	for i := 0; i < int(Config.Control.DesiredFramesPerSecondRate); i++ {
+		Tick()
+	}
+
+	if got, want := Frame(), int64(Config.Control.DesiredFramesPerSecondRate); got != want {
+		t.Errorf("Frame() = %d, want %d", got, want)
+	}
+
+	if got := Now().Sub(start); got != time.Second {
+		t.Errorf("Now() advanced by %s after the frames of a second, want %s", got, time.Second)
+	}
+
+	ResetClock()
+	if got := Now(); !got.Equal(start) {
+		t.Errorf("Now() = %s after ResetClock(), want %s", got, start)
+	}
+}
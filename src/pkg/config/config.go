@@ -183,12 +183,17 @@ type config struct {
 			GameOver                     TemplateString
 			Greeting                     TemplateString
 			HowToRestart                 TemplateString
+			NameInvalid                  TemplateString
+			NameRequired                 TemplateString
+			NameTaken                    TemplateString
+			NewTopScore                  TemplateString
 			PerformanceDropped           TemplateString
 			PerformanceImproved          TemplateString
 			PlanetDiscovered             TemplateString
 			PlanetImpactsSystem          TemplateString
 			Prompt                       TemplateString
 			ScoreBoardUpdated            TemplateString
+			ServerNotice                 TemplateString
 			SpaceshipBoosted             TemplateString
 			SpaceshipDowngradedByEnemy   TemplateString
 			SpaceshipFrozen              TemplateString
@@ -196,6 +201,7 @@ type config struct {
 			SpaceshipStillFrozen         TemplateString
 			SpaceshipUpgradedByEnemyKill TemplateString
 			SpaceshipUpgradedByTank      TemplateString
+			UnknownRecoveryCode          TemplateString
 			WaitForScoreBoardUpdate      TemplateString
 		} `ini:"MessageBox.Messages"`
 	}
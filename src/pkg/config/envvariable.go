@@ -62,3 +62,9 @@ func (e EnvVariable[T]) Get() (result T) {
 
 	return parse(raw)
 }
+
+// Key returns the key of the environment variable, without its fallback value.
+func (e EnvVariable[T]) Key() string {
+	key, _, _ := strings.Cut(string(e), ":")
+	return key
+}
@@ -3,19 +3,22 @@
 package config
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
-	"slices"
-	"strings"
+	"html"
+	"math"
 	"sync"
 	"syscall/js"
 	"time"
+
+	"github.com/sarumaj/edu-space-invaders/src/pkg/client"
 )
 
 const (
 	originalWidth  = 800 // Original width of the drawable canvas area (px, after considering the padding and border of the surrounding containers)
 	originalHeight = 600 // Original height of the drawable canvas area (px, after considering the padding and border of the surrounding containers)
+	maxBackoff     = 64  // Maximum factor of the delay between the retries of the requests to the game server
 )
 
 const (
@@ -40,11 +43,13 @@ const (
 )
 
 var (
+	apiClient              = newAPIClient()
 	audioCtx               = getAudioContext()
 	audioPlayers           = make(map[string]audioPlayer)
 	audioPlayersMutex      = sync.RWMutex{}
 	audioTracks            = make(map[string][]byte)
 	audioTracksMutex       = sync.RWMutex{}
+	cancelEvents           = func() {}
 	canvasObject           = document.Call("getElementById", canvasId)
 	canvasObjectContext    = canvasObject.Call("getContext", "2d", MakeObject(map[string]any{"willReadFrequently": true}))
 	console                = GlobalGet("console")
@@ -59,8 +64,7 @@ var (
 	invisibleCanvasScrollY = 0.0
 	messageBox             = document.Call("getElementById", messageBoxID)
 	lastLogSentTime        = time.Time{}
-	scoreBoard             []score
-	scoreBoardMutex        = sync.RWMutex{}
+	playerName             = ""
 	window                 = GlobalGet("window")
 	windowLocation         = window.Get("location")
 )
@@ -93,14 +97,44 @@ func (e logEvent) ChannelButton() js.Value {
 	return map[logEvent]js.Value{true: eventLogChannelBtn, false: infoLogChannelBtn}[e]
 }
 
-// score represents a score.
+// score represents a ranked score.
 type score struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Name      string    `json:"name"`
+	Rank      int       `json:"rank"`
 	Score     int       `json:"score"`
 }
 
+// client returns the replay as submitted to the game server.
+func (replay *Replay) client() *client.Replay {
+	if replay == nil {
+		return nil
+	}
+
+	inputs := make([]client.Input, 0, len(replay.Inputs))
+	for _, input := range replay.Inputs {
+		inputs = append(inputs, client.Input{
+			Frame:    input.Frame,
+			Device:   string(input.Device),
+			Key:      input.Key,
+			Button:   input.Button,
+			Type:     input.Type,
+			Pressed:  input.Pressed,
+			MultiTap: input.MultiTap,
+			X:        input.X,
+			Y:        input.Y,
+		})
+	}
+
+	flags := make([]client.GameFlags, 0, len(replay.Flags))
+	for _, f := range replay.Flags {
+		flags = append(flags, client.GameFlags(f))
+	}
+
+	return &client.Replay{Seed: replay.Seed, Frames: replay.Frames, Inputs: inputs, Flags: flags}
+}
+
 // init is a function that initializes the game interface.
 func init() {
 	// Set up the game interface
@@ -108,60 +142,75 @@ func init() {
 	setupCanvasInterface()
 	setupMessageBoxInterface()
 	setupRefreshInterface()
-	setupScoreBoard()
 
 	// Detach the watchdogs
-	envCallback(1)
+	eventsCallback(1)
 }
 
 // envCallback is a function that fetches the environment variables.
+// The game server responds with the feature flags effective for the session and the name it has claimed, if any.
+// The request is retried with exponential backoff until it succeeds.
 func envCallback(exponentialBackoff float64) {
 	delayInMs := 2_500 * time.Millisecond
 
 	go func() {
-		js.Global().Call("fetch", ".env", MakeObject(map[string]any{
-			"method":  http.MethodGet,
-			"headers": MakeObject(map[string]any{"Accept": "application/json"}),
-		})).Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-			response := p[0]
-			if !response.Get("ok").Bool() {
-				return response.Call("text").Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-					return GlobalGet("Promise").Call("reject", GlobalGet("Error").New(p[0].String()))
-				}))
-			}
-
-			return response.Call("json")
-
-		})).Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-			envData := ConvertObjectToMap(p[0])
-			prefix, _ := envData["_prefix"].(string)
-			env := make(map[string]any)
-			for key, value := range envData {
-				if key != "_prefix" && len(prefix) > 0 && strings.HasPrefix(key, prefix) {
-					env[key] = value
-				}
-			}
-
-			if Config.Control.Debug.Get() {
-				Log(fmt.Sprintf("Retrieved environment variables: %#v", env))
-			}
-			GlobalSet(goEnv, MakeObject(env))
-
-			time.AfterFunc(delayInMs, func() {
-				envCallback(exponentialBackoff)
+		env, err := apiClient.GetEnv(context.Background(), "")
+		if err != nil {
+			LogError(fmt.Errorf("Error getting env: %w", err))
+
+			time.AfterFunc(delayInMs*time.Duration(exponentialBackoff), func() {
+				envCallback(math.Min(exponentialBackoff*2, maxBackoff))
 			})
 
-			return nil
+			return
+		}
+
+		if Config.Control.Debug.Get() {
+			Log(fmt.Sprintf("Retrieved environment variables: %#v", env))
+		}
 
-		})).Call("catch", js.FuncOf(func(_ js.Value, p []js.Value) interface{} {
-			LogError(fmt.Errorf("Error getting env: %s", p[0].String()))
+		object := make(map[string]any, len(env))
+		for key, value := range env {
+			object[key] = value
+		}
+		GlobalSet(goEnv, MakeObject(object))
+	}()
+}
 
-			time.AfterFunc(delayInMs*time.Duration(exponentialBackoff), func() {
-				envCallback(exponentialBackoff * 2)
-			})
+// eventsCallback is a function that subscribes to the events pushed by the game server.
+// The environment variables are fetched on every (re)connect, and again whenever the feature flags change.
+// The subscription is renewed with exponential backoff once the stream ends,
+// and immediately once the name of the player changes.
+func eventsCallback(exponentialBackoff float64) {
+	delayInMs := 2_500 * time.Millisecond
 
-			return nil
-		}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelEvents = cancel
+
+	go func() {
+		defer cancel()
+
+		envCallback(1)
+		connected := time.Now()
+		err := apiClient.StreamEvents(ctx, handleEvent)
+		switch {
+		case errors.Is(err, context.Canceled):
+			eventsCallback(1)
+			return
+
+		case err != nil:
+			LogError(fmt.Errorf("Error streaming events: %w", err))
+
+		}
+
+		// A stream that has been open for a while is not a failure to back off from
+		if time.Since(connected) > delayInMs*time.Duration(exponentialBackoff) {
+			exponentialBackoff = 1
+		}
+
+		time.AfterFunc(delayInMs*time.Duration(exponentialBackoff), func() {
+			eventsCallback(math.Min(exponentialBackoff*2, maxBackoff))
+		})
 	}()
 }
 
@@ -174,22 +223,60 @@ func getAudioContext() js.Value {
 	return ctx
 }
 
-// scoreBoardSortFunc is a function that sorts the scores.
-// The scores are sorted in descending order of the score.
-// If the scores have the same score, they are ordered in ascending order of their timestamps.
-func scoreBoardSortFunc(i, j score) int {
-	if i.Score == j.Score {
-		if !i.UpdatedAt.IsZero() && !j.UpdatedAt.IsZero() {
-			// Sort in ascending order
-			return j.UpdatedAt.Compare(i.UpdatedAt)
+// handleEvent is a function that handles an event pushed by the game server.
+// Malformed events are logged and skipped.
+func handleEvent(event client.Event) error {
+	switch event.Type {
+	case client.EventFlags:
+		envCallback(1)
+
+	case client.EventNotice:
+		var notice client.Notice
+		if err := event.Decode(&notice); err != nil {
+			LogError(fmt.Errorf("Error decoding notice: %w", err))
+			return nil
+		}
+
+		SendMessage(Execute(Config.MessageBox.Messages.ServerNotice, Template{
+			"Message": html.EscapeString(notice.Message),
+		}), false, false)
+
+	case client.EventScore:
+		var result client.ScoreResult
+		if err := event.Decode(&result); err != nil {
+			LogError(fmt.Errorf("Error decoding score: %w", err))
+			return nil
 		}
 
-		// Sort in ascending order
-		return j.CreatedAt.Compare(i.CreatedAt)
+		SendMessage(Execute(Config.MessageBox.Messages.NewTopScore, Template{
+			"Name":  html.EscapeString(result.Name),
+			"Rank":  result.Rank,
+			"Score": result.Score,
+		}), false, false)
+
 	}
 
-	// Sort in descending order
-	return j.Score - i.Score
+	return nil
+}
+
+// newAPIClient is a function that returns the client of the game server serving the document.
+func newAPIClient() *client.Client {
+	c, err := client.New("")
+	ThrowError(err)
+	return c
+}
+
+// resolveURL is a function that resolves the path relative to the origin of the document.
+func resolveURL(path string) string {
+	protocol := windowLocation.Get("protocol").String()
+	hostname := windowLocation.Get("hostname").String()
+	port := windowLocation.Get("port").String()
+
+	if port == "" {
+		return fmt.Sprintf("%s//%s/%s", protocol, hostname, path)
+	}
+
+	return fmt.Sprintf("%s//%s:%s/%s", protocol, hostname, port, path)
 }
 
 // setupAudioInterface is a function that sets up the audio interface.
@@ -360,42 +447,3 @@ func setupRefreshInterface() {
 	refreshButton.Call("addEventListener", "click", GlobalGet("refreshButtonClick"))
 	refreshButton.Call("addEventListener", "touchend", GlobalGet("refreshButtonTouchEnd"))
 }
-
-// setupScoreBoard is a function that sets up the score board.
-func setupScoreBoard() {
-	scoreBoardMutex.Lock()
-
-	GlobalCall("fetch", "scores.db", MakeObject(map[string]any{
-		"method":  http.MethodGet,
-		"headers": MakeObject(map[string]any{"Content-Type": "application/json"}),
-	})).Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-		if !p[0].Get("ok").Bool() {
-			return p[0].Call("text").Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-				return GlobalGet("Promise").Call("reject", GlobalGet("Error").New(p[0].String()))
-			}))
-		}
-
-		return p[0].Call("text")
-
-	})).Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-		defer scoreBoardMutex.Unlock()
-
-		if err := json.Unmarshal([]byte(strings.TrimPrefix(p[0].String(), "while(1);")), &scoreBoard); err != nil {
-			return GlobalGet("Promise").Call("reject", GlobalGet("Error").New(err.Error()))
-		}
-
-		slices.SortStableFunc(scoreBoard, scoreBoardSortFunc)
-		if Config.Control.Debug.Get() {
-			Log(fmt.Sprintf("Fetched score board: %#v", scoreBoard))
-		}
-
-		return nil
-
-	})).Call("catch", js.FuncOf(func(_ js.Value, p []js.Value) any {
-		defer scoreBoardMutex.Unlock()
-
-		LogError(fmt.Errorf("failed to load score board: %s", p[0].String()))
-		return nil
-
-	}))
-}
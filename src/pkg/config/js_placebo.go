@@ -3,11 +3,15 @@
 package config
 
 import (
+	"io"
 	"log"
 	"os"
 	"time"
 )
 
+// logger logs the messages of the game, see SetLogOutput.
+var logger = log.New(os.Stderr, "", log.LstdFlags)
+
 type dimensions struct {
 	BoxWidth, BoxHeight                  float64
 	BoxLeft, BoxTop, BoxRight, BoxBottom float64
@@ -17,6 +21,7 @@ type dimensions struct {
 
 type score struct {
 	Name  string `json:"name"`
+	Rank  int    `json:"rank"`
 	Score int    `json:"score"`
 }
 
@@ -24,9 +29,14 @@ func AddEventListener(event string, listener any)         {}
 func AddEventListenerToCanvas(event string, listener any) {}
 
 func CanvasBoundingBox() dimensions {
-	return dimensions{BoxWidth: 800, BoxHeight: 600, BoxRight: 800, BoxBottom: 600}
+	return dimensions{
+		BoxWidth: 800, BoxHeight: 600, BoxRight: 800, BoxBottom: 600,
+		OriginalWidth: 800, OriginalHeight: 600,
+		ScaleWidth: 1, ScaleHeight: 1,
+	}
 }
 
+func ClaimName(name string) (string, error)                                           { return "", nil }
 func ClearBackground()                                                                {}
 func ClearCanvas()                                                                    {}
 func ConvertArrayToSlice(array any) []any                                             { return nil }
@@ -54,31 +64,41 @@ func DrawStar(coords [2]float64, spikes int, radius, innerRadius float64, color
 
 func DrawSun(coords [2]float64, radius float64) {}
 func Getenv(key string) string                  { return os.Getenv(key) }
-func GetScores(top int) (scores []score)        { return }
+func GetScores(limit int, name string) []score  { return nil }
+func GetSessionPlayer() string                  { return "" }
 func GlobalCall(name string, args ...any) any   { return nil }
 func GlobalGet(key string) any                  { return nil }
 func GlobalSet(key string, value any)           {}
 func IsPlaying(name string) bool                { return false }
 func IsTouchDevice() bool                       { return false }
 func LoadAudio(url string) ([]byte, error)      { return nil, nil }
-func Log(msg string)                            { log.Println(msg) }
+func Log(msg string)                            { logger.Println(msg) }
 
 func LogError(err error) {
 	if err != nil {
-		log.Println(err)
+		logger.Println(err)
 	}
 }
 
 func MakeObject(m map[string]any) any                                            { return m }
 func NewInstance(typ string, args ...any) any                                    { return nil }
 func PlayAudio(name string, loop bool)                                           {}
-func SaveScores()                                                                {}
-func SendMessage(msg string, reset, event bool)                                  { log.Println(msg) }
-func SendMessageThrottled(msg string, reset, event bool, cooldown time.Duration) { log.Println(msg) }
-func Setenv(key, value string)                                                   { _ = os.Setenv(key, value) }
-func SetScore(name string, score int) (rank int)                                 { return }
-func StopAudio(name string)                                                      {}
-func StopAudioSources(selector func(name string) bool)                           {}
+func RecoverPlayer(recoveryCode string) (string, error)                          { return "", nil }
+func SendMessage(msg string, reset, event bool)                                  { logger.Println(msg) }
+func SendMessageThrottled(msg string, reset, event bool, cooldown time.Duration) { logger.Println(msg) }
+
+// SetLogOutput sets the destination of the messages of the game and returns the previous one.
+func SetLogOutput(w io.Writer) io.Writer {
+	previous := logger.Writer()
+	logger.SetOutput(w)
+	return previous
+}
+
+func SetPlayer(name string)                             {}
+func Setenv(key, value string)                          { _ = os.Setenv(key, value) }
+func StopAudio(name string)                             {}
+func StopAudioSources(selector func(name string) bool)  {}
+func SubmitScore(submission ScoreSubmission) (rank int) { return }
 
 func ThrowError(err error) {
 	if err != nil {
@@ -3,13 +3,14 @@
 package config
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"slices"
 	"syscall/js"
 	"time"
+
+	"github.com/sarumaj/edu-space-invaders/src/pkg/client"
 )
 
 // AddEventListener is a function that adds an event listener to the document.
@@ -43,6 +44,26 @@ func CanvasBoundingBox() dimensions {
 	return dim
 }
 
+// ClaimName is a function that claims the name for the player of the session, the name is reserved for the player from then on.
+// It returns the recovery code, which restores the identity of the player on another device, see RecoverPlayer.
+// It returns ErrNameTaken if another player has claimed the name and ErrInvalidName if the name is not allowed.
+func ClaimName(name string) (recoveryCode string, err error) {
+	credentials, err := apiClient.ClaimName(context.Background(), name)
+	switch {
+	case client.StatusCode(err) == http.StatusConflict:
+		return "", ErrNameTaken
+
+	case client.StatusCode(err) == http.StatusUnprocessableEntity:
+		return "", ErrInvalidName
+
+	case err != nil:
+		return "", fmt.Errorf("failed to claim name: %w", err)
+
+	}
+
+	return credentials.RecoveryCode, nil
+}
+
 // ClearBackground is a function that clears the invisible document.
 func ClearBackground() {
 	invisibleCtx.Call("clearRect", 0, 0, invisibleCanvas.Get("width"), invisibleCanvas.Get("height"))
@@ -122,16 +143,46 @@ func Getenv(key string) string {
 	return got.String()
 }
 
-// GetScores is a function that returns the scores.
-func GetScores(top int) (scores []score) {
-	scoreBoardMutex.RLock()
-	defer scoreBoardMutex.RUnlock()
+// GetScores is a function that returns a page of the leaderboard.
+// If name is not empty, the page is centered around the rank of the player with the given name.
+// It returns at most limit scores ranked by the game server.
+func GetScores(limit int, name string) (scores []score) {
+	board, err := apiClient.GetLeaderboard(context.Background(), client.LeaderboardQuery{Around: name, Limit: limit})
+	if err != nil {
+		LogError(fmt.Errorf("failed to load leaderboard: %w", err))
+		return
+	}
 
-	for i := 0; i < top && i < len(scoreBoard); i++ {
-		scores = append(scores, scoreBoard[i])
+	for _, entry := range board.Entries {
+		s := score{Name: entry.Name, Rank: int(entry.Rank), Score: int(entry.Score.Score)}
+		if entry.CreatedAt != nil {
+			s.CreatedAt = *entry.CreatedAt
+		}
+		if entry.UpdatedAt != nil {
+			s.UpdatedAt = *entry.UpdatedAt
+		}
+		scores = append(scores, s)
 	}
 
-	return
+	if Config.Control.Debug.Get() {
+		Log(fmt.Sprintf("Fetched leaderboard: %#v", scores))
+	}
+
+	return scores
+}
+
+// GetSessionPlayer is a function that returns the name claimed by the player of the session.
+// It returns an empty string if the session has not claimed a name yet.
+func GetSessionPlayer() string {
+	player, err := apiClient.GetSessionPlayer(context.Background())
+	if err != nil {
+		if client.StatusCode(err) != http.StatusNotFound {
+			LogError(fmt.Errorf("failed to load player: %w", err))
+		}
+		return ""
+	}
+
+	return player.Name
 }
 
 // GlobalCall is a function that calls the global function name with the specified arguments.
@@ -190,16 +241,7 @@ func IsTouchDevice() bool {
 
 // LoadAudio is a function that loads an audio file.
 func LoadAudio(name string) ([]byte, error) {
-	protocol := windowLocation.Get("protocol").String()
-	hostname := windowLocation.Get("hostname").String()
-	port := windowLocation.Get("port").String()
-
-	url := fmt.Sprintf("%s//%s:%s/audio/%s", protocol, hostname, port, name)
-	if port == "" {
-		url = fmt.Sprintf("%s//%s/audio/%s", protocol, hostname, name)
-	}
-
-	response, err := http.Get(url)
+	response, err := http.Get(resolveURL("audio/" + name))
 	if err != nil {
 		return nil, err
 	}
@@ -326,44 +368,21 @@ func PlayAudio(name string, loop bool) {
 	audioBufferPromise.Call("then", then).Call("catch", catch)
 }
 
-// SaveScores is a function that saves the score board persistently.
-func SaveScores() {
-	scoreBoardMutex.RLock()
-	serialized, err := json.Marshal(scoreBoard)
-	scoreBoardMutex.RUnlock()
-
-	if err != nil {
-		LogError(fmt.Errorf("failed to serialize score board: %v", err))
-		return
-	}
+// RecoverPlayer is a function that restores the identity of the player of the recovery code, e.g. on another device.
+// The session of the game is bound to the player from then on.
+// It returns the name of the player or ErrUnknownRecoveryCode if the recovery code belongs to no player.
+func RecoverPlayer(recoveryCode string) (name string, err error) {
+	player, err := apiClient.RecoverPlayer(context.Background(), recoveryCode)
+	switch {
+	case client.StatusCode(err) == http.StatusNotFound:
+		return "", ErrUnknownRecoveryCode
 
-	// Save the score board
-	SendMessage(Execute(Config.MessageBox.Messages.WaitForScoreBoardUpdate), false, false)
-	scoreBoardMutex.Lock()
-
-	GlobalCall("fetch", "scores.db", MakeObject(map[string]any{
-		"method":  http.MethodPut,
-		"headers": MakeObject(map[string]any{"Content-Type": "application/json"}),
-		"body":    string(serialized),
-	})).Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-		defer scoreBoardMutex.Unlock()
-
-		if !p[0].Get("ok").Bool() {
-			return p[0].Call("text").Call("then", js.FuncOf(func(_ js.Value, p []js.Value) any {
-				LogError(fmt.Errorf("server responded with error: %s", p[0].String()))
-				return nil
-			}))
-		}
+	case err != nil:
+		return "", fmt.Errorf("failed to recover player: %w", err)
 
-		// Send success message
-		SendMessage(Execute(Config.MessageBox.Messages.ScoreBoardUpdated), false, false)
-		return nil
-	})).Call("catch", js.FuncOf(func(_ js.Value, p []js.Value) any {
-		defer scoreBoardMutex.Unlock()
+	}
 
-		LogError(fmt.Errorf("failed to save score board: %s", p[0].String()))
-		return nil
-	}))
+	return player.Name, nil
 }
 
 // SendInfoMessage sends a message to the message box.
@@ -426,6 +445,18 @@ func SendMessageThrottled(msg string, reset, event logEvent, cooldown time.Durat
 	lastLogSentTime = time.Now()
 }
 
+// SetPlayer is a function that sets the name of the player, once claimed by or recovered for the session.
+// The game server derives the player from the session, hence the subscription to the events is renewed
+// to receive the changes of the feature flags of the player.
+func SetPlayer(name string) {
+	if playerName == name {
+		return
+	}
+
+	playerName = name
+	cancelEvents()
+}
+
 // Setenv is a function that sets the environment variable key to value.
 func Setenv(key, value string) {
 	environ := GlobalGet(goEnv)
@@ -433,48 +464,28 @@ func Setenv(key, value string) {
 	GlobalSet(goEnv, environ)
 }
 
-// SetScore is a function that sets the score.
-func SetScore(name string, newScore int) (rank int) {
-	scoreBoardMutex.Lock()
-
-	// Update the score board
-	var exists bool
-	for i, s := range scoreBoard {
-		if s.Name == name {
-			if newScore <= s.Score {
-				scoreBoardMutex.Unlock()
-				return len(scoreBoard) + 1
-			}
-
-			scoreBoard[i].Score = newScore
-			exists = true
-			break
-		}
-	}
-
-	// Add the score if it does not exist
-	if !exists {
-		scoreBoard = append(scoreBoard, score{Name: name, Score: newScore})
-	}
-
-	// Sort the score board
-	slices.SortStableFunc(scoreBoard, scoreBoardSortFunc)
-	scoreBoardMutex.Unlock()
+// SubmitScore is a function that submits the result of a game run to the game server.
+// The server keeps the best score of the player and computes its rank.
+// It returns the rank of the player or 0 if the submission failed.
+func SubmitScore(submission ScoreSubmission) (rank int) {
+	SendMessage(Execute(Config.MessageBox.Messages.WaitForScoreBoardUpdate), false, false)
 
-	// Calculate the rank of the new score
-	scoreBoardMutex.RLock()
-	for i, s := range scoreBoard {
-		if s.Name == name && s.Score == newScore {
-			rank = i + 1
-			break
-		}
+	result, err := apiClient.SubmitScore(context.Background(), client.ScoreSubmission{
+		Name:              submission.Name,
+		Level:             int64(submission.Level),
+		DiscoveredPlanets: submission.DiscoveredPlanets,
+		Duration:          submission.Duration,
+		EnemyKills:        int64(submission.EnemyKills),
+		CauseOfDeath:      submission.CauseOfDeath,
+		Replay:            submission.Replay.client(),
+	})
+	if err != nil {
+		LogError(fmt.Errorf("failed to submit score: %w", err))
+		return
 	}
-	scoreBoardMutex.RUnlock()
-
-	// Save the score board asynchronously
-	go SaveScores()
 
-	return
+	SendMessage(Execute(Config.MessageBox.Messages.ScoreBoardUpdated), false, false)
+	return int(result.Rank)
 }
 
 // StopAudio is a function that stops an audio track.
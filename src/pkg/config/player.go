@@ -0,0 +1,9 @@
+package config
+
+import "errors"
+
+var (
+	ErrInvalidName         = errors.New("invalid name")                   // ErrInvalidName is returned by ClaimName if the mission control rejects the name.
+	ErrNameTaken           = errors.New("name claimed by another player") // ErrNameTaken is returned by ClaimName if another player has claimed the name.
+	ErrUnknownRecoveryCode = errors.New("unknown recovery code")          // ErrUnknownRecoveryCode is returned by RecoverPlayer if the recovery code belongs to no player.
+)
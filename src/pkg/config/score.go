@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	InputDeviceKeyboard InputDevice = "k" // InputDeviceKeyboard is the device of the key events.
+	InputDeviceMouse    InputDevice = "m" // InputDeviceMouse is the device of the mouse events.
+	InputDeviceTouch    InputDevice = "t" // InputDeviceTouch is the device of the touch events.
+)
+
+// GameFlags represents the feature flags which affect the course of a game run, recorded to replay the game run.
+// The keys are abbreviated like those of Input.
+type GameFlags struct {
+	Frame                     int64 `json:"f"`            // Frame is the frame of the game run the flags have taken effect at, see Frame.
+	CollisionDetectionVersion int   `json:"cdv"`          // CollisionDetectionVersion is the version of the collision detection.
+	GodMode                   bool  `json:"gm,omitempty"` // GodMode is true if the spaceship is invincible.
+	PlanetChoice              int   `json:"pc"`           // PlanetChoice is the planet forced to be drawn, -1 for random.
+	RepelEnemies              bool  `json:"re,omitempty"` // RepelEnemies is true if the enemies are repelled by the spaceship.
+}
+
+// CurrentGameFlags returns the game flags effective at the current frame.
+func CurrentGameFlags() GameFlags {
+	return GameFlags{
+		Frame:                     Frame(),
+		CollisionDetectionVersion: Config.Control.CollisionDetectionVersion.Get(),
+		GodMode:                   Config.Control.GodMode.Get(),
+		PlanetChoice:              Config.Control.PlanetChoice.Get(),
+		RepelEnemies:              Config.Control.RepelEnemies.Get(),
+	}
+}
+
+// Apply sets the environment variables of the game flags, so that they take effect.
+func (flags GameFlags) Apply() {
+	Setenv(Config.Control.CollisionDetectionVersion.Key(), strconv.Itoa(flags.CollisionDetectionVersion))
+	Setenv(Config.Control.GodMode.Key(), strconv.FormatBool(flags.GodMode))
+	Setenv(Config.Control.PlanetChoice.Key(), strconv.Itoa(flags.PlanetChoice))
+	Setenv(Config.Control.RepelEnemies.Key(), strconv.FormatBool(flags.RepelEnemies))
+}
+
+// Equal returns true if the flags have the same values, regardless of their frames.
+func (flags GameFlags) Equal(other GameFlags) bool {
+	flags.Frame = other.Frame
+	return flags == other
+}
+
+// Unfair returns true if the flags give the player an advantage, so that the game run must not be ranked.
+func (flags GameFlags) Unfair() bool { return flags.GodMode || flags.RepelEnemies }
+
+// InputDevice represents the device of an input of the player.
+type InputDevice string
+
+// Input represents a single input of the player, recorded to replay the game run.
+// The keys are abbreviated, since a game run may consist of many thousands of inputs.
+type Input struct {
+	Frame    int64       `json:"f"`            // Frame is the frame of the game run the input has been handled at, see Frame.
+	Device   InputDevice `json:"d"`            // Device is the device of the input.
+	Key      string      `json:"k,omitempty"`  // Key is the key of a key event.
+	Button   int         `json:"b,omitempty"`  // Button is the mouse button of a mouse event.
+	Type     int         `json:"t,omitempty"`  // Type is the type of a mouse or touch event.
+	Pressed  bool        `json:"p,omitempty"`  // Pressed is true if the key or mouse button is pressed.
+	MultiTap bool        `json:"mt,omitempty"` // MultiTap is true if a touch event has correlated touches.
+	X        float64     `json:"x,omitempty"`  // X is the horizontal position on the canvas a mouse or touch event moves to.
+	Y        float64     `json:"y,omitempty"`  // Y is the vertical position on the canvas a mouse or touch event moves to.
+}
+
+// Replay represents the record of a game run, which reproduces the game run when replayed.
+type Replay struct {
+	Seed   uint64  `json:"seed"`   // Seed is the seed of the random numbers of the game run.
+	Frames int64   `json:"frames"` // Frames is the number of frames of the game run.
+	Inputs []Input `json:"inputs"` // Inputs are the inputs of the player in the order they have been handled.
+
+	// Flags are the game flags effective at the start of the game run, followed by their changes in the order of their frames.
+	Flags []GameFlags `json:"flags,omitempty"`
+}
+
+// ScoreSubmission represents the result of a single game run submitted to the game server.
+type ScoreSubmission struct {
+	Name              string        `json:"name"`
+	Level             int           `json:"level"`
+	DiscoveredPlanets []string      `json:"discovered_planets"`
+	Duration          time.Duration `json:"duration"`
+	EnemyKills        int           `json:"enemy_kills"`
+	CauseOfDeath      string        `json:"cause_of_death"`
+	Replay            *Replay       `json:"replay,omitempty"`
+}
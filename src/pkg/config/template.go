@@ -3,6 +3,7 @@ package config
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"regexp"
 	"strings"
 	"text/template"
@@ -16,8 +17,10 @@ import (
 var printer = message.NewPrinter(language.English)
 
 // funcsMap contains the template functions.
+// The functions formatting their arguments escape them, unless they are markup of another function,
+// since the messages are rendered as HTML and the arguments may be chosen by the players, e.g. their names.
 var funcsMap = template.FuncMap{
-	"bold": func(args ...any) string { return fmt.Sprintf(`<b>%s</b>`, fmt.Sprint(args...)) },
+	"bold": func(args ...any) markup { return markup(fmt.Sprintf(`<b>%s</b>`, escape(args...))) },
 	"char": func(n any) string {
 		switch num := n.(type) {
 		case string:
@@ -31,8 +34,8 @@ var funcsMap = template.FuncMap{
 			return ""
 		}
 	},
-	"color": func(color string, args ...any) string {
-		return fmt.Sprintf(`<span style="color: %s;">%s</span>`, color, fmt.Sprint(args...))
+	"color": func(color string, args ...any) markup {
+		return markup(fmt.Sprintf(`<span style="color: %s;">%s</span>`, html.EscapeString(color), escape(args...)))
 	},
 	"config": func() config { return Config },
 	"default": func(arg, fallback any) any {
@@ -63,12 +66,28 @@ var funcsMap = template.FuncMap{
 	},
 	"int":           convert[int],
 	"isTouchDevice": IsTouchDevice,
-	"italic":        func(args ...any) string { return fmt.Sprintf(`<i>%s</i>`, fmt.Sprint(args...)) },
-	"print":         printer.Sprint,
-	"printf":        printer.Sprintf,
-	"strike":        func(args ...any) string { return fmt.Sprintf(`<s>%s</s>`, fmt.Sprint(args...)) },
-	"timestamp":     func() string { return fmt.Sprintf("[%s]", time.Now().Format("15:04:05.000")) },
-	"underline":     func(args ...any) string { return fmt.Sprintf(`<u>%s</u>`, fmt.Sprint(args...)) },
+	"italic":        func(args ...any) markup { return markup(fmt.Sprintf(`<i>%s</i>`, escape(args...))) },
+	"print":         func(args ...any) markup { return markup(html.EscapeString(printer.Sprint(args...))) },
+	"printf": func(format string, args ...any) markup {
+		return markup(html.EscapeString(printer.Sprintf(format, args...)))
+	},
+	"strike":    func(args ...any) markup { return markup(fmt.Sprintf(`<s>%s</s>`, escape(args...))) },
+	"timestamp": func() string { return fmt.Sprintf("[%s]", time.Now().Format("15:04:05.000")) },
+	"underline": func(args ...any) markup { return markup(fmt.Sprintf(`<u>%s</u>`, escape(args...))) },
+}
+
+// markup represents the HTML formatted by a template function, which is not escaped again.
+type markup string
+
+// escape formats the arguments like fmt.Sprint and escapes them as HTML, unless the only argument is markup.
+func escape(args ...any) string {
+	if len(args) == 1 {
+		if arg, ok := args[0].(markup); ok {
+			return string(arg)
+		}
+	}
+
+	return html.EscapeString(fmt.Sprint(args...))
 }
 
 // convert converts the given value to the type T.
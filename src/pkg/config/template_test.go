@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestTemplate(t *testing.T) {
 	type args struct {
@@ -17,13 +20,13 @@ func TestTemplate(t *testing.T) {
 				Template{"Damage": 10000, "Name": "Test", "Level": 1},
 				`<p>Damage: {{ printf "%d" .Damage | color "red" }}, {{ "Name" | italic }}: {{ printf "%q" .Name | bold }}, Level: {{ printf "%d" .Level }}, Reason: {{ default .Reason "unknown" }}</p>`,
 			},
-			`<p>Damage: <span style="color: red;">10,000</span>, <i>Name</i>: <b>"Test"</b>, Level: 1, Reason: unknown</p>`},
+			`<p>Damage: <span style="color: red;">10,000</span>, <i>Name</i>: <b>&#34;Test&#34;</b>, Level: 1, Reason: unknown</p>`},
 		{"test#2",
 			args{
 				Template{"Damage": 10000, "Name": "Test", "Level": 1, "Reason": "test"},
 				`<p>Damage: {{ printf "%d" .Damage | color "red" }}, {{ "Name" | italic }}: {{ printf "%q" .Name | bold }}, Level: {{ printf "%d" .Level }}, Reason: {{ default .Reason "unknown" }}</p>`,
 			},
-			`<p>Damage: <span style="color: red;">10,000</span>, <i>Name</i>: <b>"Test"</b>, Level: 1, Reason: test</p>`},
+			`<p>Damage: <span style="color: red;">10,000</span>, <i>Name</i>: <b>&#34;Test&#34;</b>, Level: 1, Reason: test</p>`},
 		{"test#3",
 			args{
 				Template{"Damage": 100},
@@ -35,6 +38,13 @@ func TestTemplate(t *testing.T) {
 				{{- end }}
 				comparing to the average damage.`,
 			}, `Your damage is small comparing to the average damage.`},
+		{"test#4",
+			args{
+				Template{"Name": `<img src=x onerror="alert(1)">`, "Reason": "<b>test</b>"},
+				`<p>{{ bold .Name }}, {{ color "gold" .Name | bold }}, {{ print .Name }}, {{ printf "%s" .Name | italic }}, {{ default .Reason "unknown" }}</p>`,
+			},
+			`<p><b>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</b>, <b><span style="color: gold;">&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</span></b>, ` +
+				`&lt;img src=x onerror=&#34;alert(1)&#34;&gt;, <i>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</i>, <b>test</b></p>`},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			if got := tt.args.t.execute(tt.args.str.Sanitize()); got != tt.want {
@@ -43,3 +53,26 @@ func TestTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateEscapesNames(t *testing.T) {
+	const name = `<script>alert(1)</script>`
+	scores := []score{{Name: name, Rank: 1, Score: 1}}
+
+	for _, tt := range []struct {
+		name string
+		args TemplateString
+		data Template
+	}{
+		{"test#1", Config.MessageBox.Messages.GameOver, Template{"HighScore": 1, "Rank": 11, "TopScores": scores, "Neighbours": scores}},
+		{"test#2", Config.MessageBox.Messages.Greeting, Template{"Commandant": name, "RecoveryCode": "code"}},
+		{"test#3", Config.MessageBox.Messages.NewTopScore, Template{"Name": name, "Rank": 1, "Score": 1}},
+		{"test#4", Config.MessageBox.Messages.GameOver, Template{"HighScore": 1, "Rank": 0, "TopScores": scores, "Unnamed": true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Execute(tt.args, tt.data)
+			if strings.Contains(got, "<script>") || !strings.Contains(got, "&lt;script&gt;") {
+				t.Errorf("Execute() = %v, want the name escaped", got)
+			}
+		})
+	}
+}
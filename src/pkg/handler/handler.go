@@ -2,6 +2,8 @@ package handler
 
 import (
 	"context"
+	"fmt"
+	"math/rand/v2"
 	"sync"
 	"time"
 
@@ -18,14 +20,20 @@ type handler struct {
 	ctx        context.Context      // ctx is an abortable context of the handler
 	cancel     context.CancelFunc   // cancel is the cancel function of the handler
 	enemies    enemy.Enemies        // enemies is the list of enemies
+	flags      []config.GameFlags   // flags is the flag log of the current game, which replays the game together with the inputs
+	inputs     []config.Input       // inputs is the input log of the current game, which replays the game together with the seed
 	keyEvent   chan keyEvent        // keyupEvent is the channel for key events
 	keysHeld   map[keyBinding]bool  // keysHeld is the map of keys held
+	kills      int                  // kills is the number of enemies destroyed by the spaceship in the current game
 	mouseEvent chan mouseEvent      // mouseEvent is the channel for mouse events
 	mouseHeld  map[mouseButton]bool // mouseHeld is the map of mouse buttons held
 	once       sync.Once            // once is meant to register the keydown event only once
 	planet     *planet.Planet       // planet is the planet to be drawn
+	recovery   string               // recovery is the recovery code of the name claimed on start, shown once along with the greeting
+	seed       uint64               // seed is the seed of the random numbers of the current game
 	spaceship  *spaceship.Spaceship // spaceship is the player's spaceship
 	stars      star.Stars           // stars is the list of stars
+	startedAt  time.Time            // startedAt is the time the current game has been started
 	touchEvent chan touchEvent      // touchEvent is the channel for touch events
 	touchHeld  bool                 // touchHeld is the flag to indicate if the touch is held
 }
@@ -129,15 +137,7 @@ func (h *handler) applyGravityOnSpaceship() {
 		area := h.spaceship.Area()
 		// Destroy the spaceship if it is too small.
 		if area <= 1 && !config.Config.Control.GodMode.Get() {
-			config.SendMessage(config.Execute(config.Config.MessageBox.Messages.GameOver, config.Template{
-				"DiscoveredPlanets": h.spaceship.Discovered(),
-				"HighScore":         h.spaceship.Level.HighScore,
-				"Rank":              config.SetScore(h.spaceship.Commandant, h.spaceship.Level.HighScore),
-				"Reason":            config.Execute(config.Config.Planet.Impact.BlackHole.SpaceshipDestroyedReason),
-				"TopScores":         config.GetScores(10),
-			}), false, false)
-			h.pause()
-			h.cancel()
+			h.gameOver("Sucked into a black hole", config.Execute(config.Config.Planet.Impact.BlackHole.SpaceshipDestroyedReason))
 			return
 		}
 
@@ -353,6 +353,9 @@ func (h *handler) checkCollisions() {
 			}
 
 			h.enemies[j].Destroy() // Destroy the enemy due to the collision.
+			if e.Type() != enemy.Tank {
+				h.kills++
+			}
 			config.SendMessage(config.Execute(config.Config.MessageBox.Messages.EnemyDestroyed, config.Template{
 				"EnemyName": e.Name,
 				"EnemyType": e.Type(),
@@ -395,14 +398,7 @@ func (h *handler) checkCollisions() {
 				}
 
 				if h.spaceship.IsDestroyed() { // Check if the spaceship has been destroyed.
-					config.SendMessage(config.Execute(config.Config.MessageBox.Messages.GameOver, config.Template{
-						"DiscoveredPlanets": h.spaceship.Discovered(),
-						"HighScore":         h.spaceship.Level.HighScore,
-						"Rank":              config.SetScore(h.spaceship.Commandant, h.spaceship.Level.HighScore),
-						"TopScores":         config.GetScores(10),
-					}), false, false)
-					h.pause()
-					h.cancel()
+					h.gameOver(fmt.Sprintf("Collided with %s (%s)", e.Name, e.Type()), "")
 					return
 				}
 
@@ -461,14 +457,7 @@ func (h *handler) checkCollisions() {
 
 			// Check if the spaceship has been destroyed.
 			if h.spaceship.IsDestroyed() {
-				config.SendMessage(config.Execute(config.Config.MessageBox.Messages.GameOver, config.Template{
-					"DiscoveredPlanets": h.spaceship.Discovered(),
-					"HighScore":         h.spaceship.Level.HighScore,
-					"Rank":              config.SetScore(h.spaceship.Commandant, h.spaceship.Level.HighScore),
-					"TopScores":         config.GetScores(10),
-				}), false, false)
-				h.pause()
-				h.cancel()
+				h.gameOver(fmt.Sprintf("Collided with %s (%s)", e.Name, e.Type()), "")
 				return
 			}
 
@@ -511,6 +500,11 @@ func (h *handler) checkCollisions() {
 				"Damage":    damage,
 			}), false, true)
 
+			// Count the enemy as a kill if it has no health points left.
+			if h.enemies[j].IsDestroyed() {
+				h.kills++
+			}
+
 			// If the enemy has no health points, upgrade the spaceship.
 			if h.enemies[j].IsDestroyed() && h.spaceship.Level.GainExperience(e) {
 				config.SendMessage(config.Execute(config.Config.MessageBox.Messages.SpaceshipUpgradedByEnemyKill, config.Template{
@@ -569,6 +563,54 @@ func (h *handler) draw() {
 	}
 }
 
+// gameOver ends the game.
+// It submits the result of the game run to the game server and notifies the user.
+// The cause is the short description of the cause of death recorded in the history of the player.
+// The reason is the message explaining the cause of death, if empty, the default reason is used.
+func (h *handler) gameOver(cause, reason string) {
+	discovered := h.spaceship.Discovered()
+
+	// The scores of a player without a name cannot be ranked.
+	var rank int
+	if h.spaceship.Commandant != "" {
+		rank = config.SubmitScore(config.ScoreSubmission{
+			Name:              h.spaceship.Commandant,
+			Level:             h.spaceship.Level.HighScore,
+			DiscoveredPlanets: discovered,
+			Duration:          time.Since(h.startedAt),
+			EnemyKills:        h.kills,
+			CauseOfDeath:      cause,
+			Replay: &config.Replay{
+				Seed:   h.seed,
+				Frames: config.Frame(),
+				Inputs: h.inputs,
+				Flags:  h.flags,
+			},
+		})
+	}
+
+	data := config.Template{
+		"DiscoveredPlanets": discovered,
+		"HighScore":         h.spaceship.Level.HighScore,
+		"Rank":              rank,
+		"TopScores":         config.GetScores(10, ""),
+		"Unnamed":           h.spaceship.Commandant == "",
+	}
+
+	// Show the neighbouring scores if the player did not make it into the top scores.
+	if rank > 10 {
+		data["Neighbours"] = config.GetScores(5, h.spaceship.Commandant)
+	}
+
+	if reason != "" {
+		data["Reason"] = reason
+	}
+
+	config.SendMessage(config.Execute(config.Config.MessageBox.Messages.GameOver, data), false, false)
+	h.pause()
+	h.cancel()
+}
+
 // handleKeyEvent handles the key event.
 // It sets the running state to true when the key event is triggered.
 // It moves the spaceship to the left when the arrow left key is pressed.
@@ -584,6 +626,9 @@ func (h *handler) handleKeyEvent(key keyEvent) {
 		return
 
 	default:
+		// Key releases are handled even if the game is offline or suspended.
+		h.record(config.Input{Device: config.InputDeviceKeyboard, Key: string(key.Key), Pressed: key.Pressed}, !key.Pressed)
+
 		if !key.Pressed {
 			switch key.Key {
 			case ArrowDown, ArrowLeft, ArrowRight, ArrowUp, Space:
@@ -617,8 +662,9 @@ func (h *handler) handleKeyhold() {
 		return
 
 	default:
-		for key, ok := range h.keysHeld {
-			if !ok {
+		// The keys are handled in a fixed order, so that the game can be replayed.
+		for _, key := range []keyBinding{ArrowDown, ArrowLeft, ArrowRight, ArrowUp, Space} {
+			if !h.keysHeld[key] {
 				continue
 			}
 
@@ -654,6 +700,17 @@ func (h *handler) handleMouse(event mouseEvent) {
 		return
 
 	default:
+		// Mouse button releases are handled even if the game is offline or suspended.
+		target := moveTarget(event.CurrentPosition, event.StartPosition)
+		h.record(config.Input{
+			Device:  config.InputDeviceMouse,
+			Button:  int(event.Button),
+			Type:    int(event.Type),
+			Pressed: event.Pressed,
+			X:       target.X.Float(),
+			Y:       target.Y.Float(),
+		}, !event.Pressed)
+
 		if !event.Pressed { // If the mouse button is released, do nothing.
 			delete(h.mouseHeld, event.Button)
 			return
@@ -688,7 +745,7 @@ func (h *handler) handleMouse(event mouseEvent) {
 
 		// handling of mouse move event
 		h.mouseHeld[event.Button] = true // make sure the button is held (if button down event has been missed)
-		h.handleMoveEventTypes(event.CurrentPosition, event.StartPosition)
+		h.handleMoveEventTypes(target)
 	}
 }
 
@@ -717,6 +774,15 @@ func (h *handler) handleTouch(event touchEvent) {
 		return
 
 	default:
+		target := moveTarget(event.CurrentPosition, event.StartPosition)
+		h.record(config.Input{
+			Device:   config.InputDeviceTouch,
+			Type:     int(event.Type),
+			MultiTap: event.MultiTap,
+			X:        target.X.Float(),
+			Y:        target.Y.Float(),
+		}, false)
+
 		if h.start() { // If the game has just started, do nothing.
 			return
 		}
@@ -740,26 +806,15 @@ func (h *handler) handleTouch(event touchEvent) {
 
 		// handle touch move event
 		h.touchHeld = true // make sure the touch is held (if touch down event has been missed)
-		h.handleMoveEventTypes(event.CurrentPosition, event.StartPosition)
+		h.handleMoveEventTypes(target)
 	}
 }
 
 // handleMoveEventTypes handles the move event types (mouse move event and touch move event).
-// It moves the spaceship to the position.
-// If the current position is not zero, it moves the spaceship to the current position.
-// If the start position is not zero, it moves the spaceship to the start position.
-// It corrects the position by the canvas dimensions.
-func (h *handler) handleMoveEventTypes(eventCurrentPosition, eventStartPosition numeric.Position) {
-	canvasDimensions := config.CanvasBoundingBox()
-	positionCorrection := numeric.Locate(canvasDimensions.ScaleWidth, canvasDimensions.ScaleHeight)
-
-	switch {
-	case !eventCurrentPosition.IsZero():
-		h.spaceship.MoveTo(eventCurrentPosition.DivX(positionCorrection))
-
-	case !eventStartPosition.IsZero():
-		h.spaceship.MoveTo(eventStartPosition.DivX(positionCorrection))
-
+// It moves the spaceship to the target position, unless it is zero, see moveTarget.
+func (h *handler) handleMoveEventTypes(target numeric.Position) {
+	if !target.IsZero() {
+		h.spaceship.MoveTo(target)
 	}
 }
 
@@ -792,6 +847,28 @@ func (h *handler) pause() {
 	config.SendMessage(config.Execute(config.Config.MessageBox.Messages.GamePaused), false, false)
 }
 
+// reset prepares a new game of the commandant.
+// It seeds the random numbers, resets the game clock, the input log and the flag log,
+// and creates the spaceship, the stars and the planet, but not the enemies.
+func (h *handler) reset(seed uint64, commandant string) {
+	numeric.Seed(seed)
+	config.ResetClock()
+
+	h.seed = seed
+	h.inputs = nil
+	h.flags = nil
+	h.recordFlags()
+	h.kills = 0
+	h.startedAt = time.Time{}
+	h.enemies = nil
+	h.spaceship = spaceship.Embark(commandant)
+	h.stars = star.Explode(config.Config.Star.Count)
+	h.planet = planet.Reveal(true, true)
+	h.keysHeld = make(map[keyBinding]bool)
+	h.mouseHeld = make(map[mouseButton]bool)
+	h.touchHeld = false
+}
+
 // render is a method that renders the game.
 // It draws the spaceship, bullets and enemies on the canvas.
 // The spaceship is drawn in white color.
@@ -817,21 +894,51 @@ func (h *handler) render() {
 	h.draw()
 }
 
+// record appends the input to the input log of the current game.
+// The inputs ignored because the game is offline or suspended are not recorded,
+// unless they are handled regardless.
+func (h *handler) record(input config.Input, regardless bool) {
+	if !regardless && (offline.Get(h.ctx) || suspended.Get(h.ctx)) {
+		return
+	}
+
+	h.recordFlags()
+	input.Frame = config.Frame()
+	h.inputs = append(h.inputs, input)
+}
+
+// recordFlags appends the game flags to the flag log of the current game, if they have changed since they were last recorded.
+func (h *handler) recordFlags() {
+	flags := config.CurrentGameFlags()
+	if len(h.flags) > 0 && h.flags[len(h.flags)-1].Equal(flags) {
+		return
+	}
+
+	h.flags = append(h.flags, flags)
+}
+
 // refresh refreshes the game state.
+// It records the changes of the game flags and advances the game clock by a frame.
 // It updates the bullets of the spaceship.
 // It updates the enemies.
 // It updates the state of the spaceship.
 // It checks the collisions.
-func (h *handler) refresh() {
+// It animates the spaceship and the enemies.
+// It reports whether the game state has been refreshed.
+func (h *handler) refresh() bool {
 	switch {
 	case
 		offline.Get(h.ctx),   // If the game is offline, do nothing.
 		suspended.Get(h.ctx), // If the game is suspended, do nothing.
 		!running.Get(h.ctx):  // If the game is not running, do nothing.
 
-		return
+		return false
 	}
 
+	// Record the game flags the frame is refreshed with and advance the game clock.
+	h.recordFlags()
+	config.Tick()
+
 	// Update the positions of the enemies.
 	h.enemies.Update(h.spaceship.Geometry.Position())
 
@@ -852,6 +959,12 @@ func (h *handler) refresh() {
 
 	// Check the collisions.
 	h.checkCollisions()
+
+	// Animate the spaceship and the enemies.
+	h.spaceship.Animate()
+	h.enemies.Animate()
+
+	return true
 }
 
 // start starts the game if not already started.
@@ -872,6 +985,10 @@ func (h *handler) start() bool {
 			config.SendMessage(config.Execute(config.Config.MessageBox.Messages.GameStarted), false, false)
 		}
 
+		if h.startedAt.IsZero() {
+			h.startedAt = time.Now()
+		}
+
 		go config.PlayAudio("theme_heroic.wav", true)
 
 		return true
@@ -880,6 +997,23 @@ func (h *handler) start() bool {
 	return false
 }
 
+// tick performs a single frame of the game loop.
+// It refreshes the game state and renders the game,
+// and applies the held keys, mouse buttons and touches if the game state has been refreshed.
+// It reports whether the game state has been refreshed.
+func (h *handler) tick() bool {
+	refreshed := h.refresh()
+	h.render()
+	if !refreshed {
+		return false
+	}
+
+	h.handleKeyhold()
+	h.handleMouseHeld()
+	h.handleTouchHeld()
+	return true
+}
+
 // Await waits for the handler to finish and executes the shutdown function.
 func (h *handler) Await() {
 	<-h.ctx.Done()
@@ -902,10 +1036,13 @@ func (h *handler) GenerateEnemies(num int, randomY bool) {
 func (h *handler) Loop() {
 	fpsRate := time.Second / time.Duration(config.Config.Control.DesiredFramesPerSecondRate)
 
-	if isFirstTime.Get(h.ctx) {
+	// Greet the player on start and once a name is claimed on restart, to show its recovery code.
+	if isFirstTime.Get(h.ctx) || h.recovery != "" {
 		config.SendMessage(config.Execute(config.Config.MessageBox.Messages.Greeting, config.Template{
-			"Commandant": h.spaceship.Commandant,
+			"Commandant":   h.spaceship.Commandant,
+			"RecoveryCode": h.recovery,
 		}), true, false)
+		h.recovery = ""
 	}
 
 	// Notify the user about how to start the game.
@@ -947,12 +1084,7 @@ func (h *handler) Loop() {
 			return
 
 		case <-ticker.C:
-
-			h.refresh()
-			h.render()
-			h.handleKeyhold()
-			h.handleMouseHeld()
-			h.handleTouchHeld()
+			h.tick()
 
 		case key := <-h.keyEvent:
 			h.handleKeyEvent(key)
@@ -969,13 +1101,14 @@ func (h *handler) Loop() {
 
 // Restart restarts the game.
 func (h *handler) Restart() {
-	h.spaceship = spaceship.Embark(h.spaceship.Commandant)
-	h.enemies = nil
-	h.stars = star.Explode(config.Config.Star.Count)
-	h.planet = planet.Reveal(true, true)
+	h.reset(rand.Uint64(), h.spaceship.Commandant)
 	h.ctx, h.cancel = context.WithCancel(context.Background())
 	running.Set(&h.ctx, false)
 	isFirstTime.Set(&h.ctx, false)
+	if h.spaceship.Commandant == "" {
+		h.ask()
+		config.SetPlayer(h.spaceship.Commandant)
+	}
 }
 
 // New creates a new handler.
@@ -988,16 +1121,34 @@ func New() *handler {
 		mouseHeld:  make(map[mouseButton]bool),
 		touchEvent: make(chan touchEvent),
 		touchHeld:  false,
-		planet:     planet.Reveal(true, true),
-		spaceship:  spaceship.Embark(""),
-		stars:      star.Explode(config.Config.Star.Count),
 	}
 
+	h.reset(rand.Uint64(), "")
 	h.ctx, h.cancel = context.WithCancel(context.Background())
 	running.Set(&h.ctx, false)
 	isFirstTime.Set(&h.ctx, true)
 	h.registerEventHandlers()
 	h.ask()
+	config.SetPlayer(h.spaceship.Commandant)
 
 	return h
 }
+
+// moveTarget returns the position on the canvas a mouse or touch event moves the spaceship to.
+// It is the current position of the event if not zero, otherwise its start position,
+// corrected by the canvas dimensions.
+func moveTarget(eventCurrentPosition, eventStartPosition numeric.Position) numeric.Position {
+	canvasDimensions := config.CanvasBoundingBox()
+	positionCorrection := numeric.Locate(canvasDimensions.ScaleWidth, canvasDimensions.ScaleHeight)
+
+	switch {
+	case !eventCurrentPosition.IsZero():
+		return eventCurrentPosition.DivX(positionCorrection)
+
+	case !eventStartPosition.IsZero():
+		return eventStartPosition.DivX(positionCorrection)
+
+	}
+
+	return numeric.Position{}
+}
@@ -3,24 +3,77 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"syscall/js"
 	"time"
 
+	"github.com/sarumaj/edu-space-invaders/src/pkg/client"
 	"github.com/sarumaj/edu-space-invaders/src/pkg/config"
 	"github.com/sarumaj/edu-space-invaders/src/pkg/numeric"
 )
 
 // ask is a method that asks the user for input.
+// The name claimed by the player of the session is used if any.
+// Otherwise, the user is asked for a name to claim or for the recovery code of a name claimed on another device,
+// until the name is claimed, the player is recovered or the user cancels twice.
+// The scores of a user without a name are not submitted, the user is asked again on restart.
 func (h *handler) ask() {
-	if commandant := config.GlobalCall(
-		"prompt",
-		config.Execute(config.Config.MessageBox.Messages.Prompt),
-		h.spaceship.Commandant,
-	); commandant.Truthy() && commandant.String() != "" {
+	if name := config.GetSessionPlayer(); name != "" {
+		h.spaceship.Commandant = name
+		return
+	}
+
+	prompt := config.Execute(config.Config.MessageBox.Messages.Prompt)
+	for {
+		answer := config.GlobalCall("prompt", prompt, h.spaceship.Commandant)
+		if !answer.Truthy() || strings.TrimSpace(answer.String()) == "" {
+			if required := config.Execute(config.Config.MessageBox.Messages.NameRequired); prompt != required {
+				prompt = required
+				continue
+			}
+
+			return
+		}
+
+		input := strings.TrimSpace(answer.String())
+		if client.IsRecoveryCode(input) {
+			name, err := config.RecoverPlayer(input)
+			switch {
+			case errors.Is(err, config.ErrUnknownRecoveryCode):
+				prompt = config.Execute(config.Config.MessageBox.Messages.UnknownRecoveryCode)
+				continue
 
-		h.spaceship.Commandant = commandant.String()
+			case err != nil:
+				config.LogError(err)
+				return
+
+			}
+
+			h.spaceship.Commandant = name
+			return
+		}
+
+		recovery, err := config.ClaimName(input)
+		switch {
+		case errors.Is(err, config.ErrNameTaken):
+			prompt = config.Execute(config.Config.MessageBox.Messages.NameTaken, config.Template{"Name": input})
+			continue
+
+		case errors.Is(err, config.ErrInvalidName):
+			prompt = config.Execute(config.Config.MessageBox.Messages.NameInvalid, config.Template{"Name": input})
+			continue
+
+		case err != nil:
+			config.LogError(err)
+			return
+
+		}
+
+		h.spaceship.Commandant, h.recovery = input, recovery
+		return
 	}
 }
 
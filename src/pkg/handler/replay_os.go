@@ -0,0 +1,132 @@
+//go:build !js || !wasm
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/sarumaj/edu-space-invaders/src/pkg/config"
+	"github.com/sarumaj/edu-space-invaders/src/pkg/numeric"
+)
+
+// applyFlags applies the game flags which take effect at the current frame and returns the remaining ones.
+// An error is returned if the flags are not in the order of their frames.
+func applyFlags(flags []config.GameFlags) ([]config.GameFlags, error) {
+	for len(flags) > 0 && flags[0].Frame == config.Frame() {
+		flags[0].Apply()
+		flags = flags[1:]
+	}
+
+	if len(flags) > 0 && flags[0].Frame < config.Frame() {
+		return nil, fmt.Errorf("game flags at frame %d are out of order at frame %d", flags[0].Frame, config.Frame())
+	}
+
+	return flags, nil
+}
+
+// handleInput handles a recorded input like the game loop handles the events of the player.
+func (h *handler) handleInput(input config.Input) error {
+	position := numeric.Locate(input.X, input.Y)
+
+	switch input.Device {
+	case config.InputDeviceKeyboard:
+		switch key := keyBinding(input.Key); key {
+		case ArrowDown, ArrowLeft, ArrowRight, ArrowUp, Pause, Space:
+			h.handleKeyEvent(keyEvent{Key: key, Pressed: input.Pressed})
+
+		default:
+			return fmt.Errorf("unknown key %q at frame %d", input.Key, input.Frame)
+
+		}
+
+	case config.InputDeviceMouse:
+		h.handleMouse(mouseEvent{
+			CurrentPosition: position,
+			Button:          mouseButton(input.Button),
+			Pressed:         input.Pressed,
+			Type:            mouseEventType(input.Type),
+		})
+
+	case config.InputDeviceTouch:
+		h.handleTouch(touchEvent{
+			CurrentPosition: position,
+			Type:            touchType(input.Type),
+			MultiTap:        input.MultiTap,
+		})
+
+	default:
+		return fmt.Errorf("unknown input device %q at frame %d", input.Device, input.Frame)
+
+	}
+
+	return nil
+}
+
+// Replay replays a game run headlessly and returns the high score the spaceship has reached.
+// The game is set up the way the game loop of the browser sets it up, with the seed and the game flags of the replay.
+// The game flags and the inputs take effect at the frames they have been recorded at,
+// in between the game is refreshed frame by frame,
+// until the game is over, the number of frames of the replay is reached, or the game is paused for good.
+// An error is returned if the game flags at the start are missing, or if the game flags or the inputs
+// are invalid or not in the order of their frames.
+// The game flags of the process are restored afterwards.
+// The random numbers, the game clock and the game flags are global to the process,
+// hence a replay must not run concurrently with another replay or game of the process.
+func Replay(replay config.Replay) (highScore int, err error) {
+	if len(replay.Flags) == 0 || replay.Flags[0].Frame != 0 {
+		return 0, fmt.Errorf("game flags at frame 0 are missing")
+	}
+
+	// The messages of the game are of no interest.
+	defer config.SetLogOutput(config.SetLogOutput(io.Discard))
+	defer config.CurrentGameFlags().Apply()
+
+	config.ResetClock()
+	flags, err := applyFlags(replay.Flags)
+	if err != nil {
+		return 0, err
+	}
+
+	h := &handler{}
+	h.reset(replay.Seed, "")
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	defer h.cancel()
+
+	running.Set(&h.ctx, false)
+	isFirstTime.Set(&h.ctx, true)
+	h.GenerateEnemies(config.Config.Enemy.Count, true)
+
+	inputs := replay.Inputs
+	for h.ctx.Err() == nil && config.Frame() < replay.Frames {
+		if flags, err = applyFlags(flags); err != nil {
+			return 0, err
+		}
+
+		for len(inputs) > 0 && inputs[0].Frame == config.Frame() && h.ctx.Err() == nil {
+			if err := h.handleInput(inputs[0]); err != nil {
+				return 0, err
+			}
+
+			inputs = inputs[1:]
+		}
+
+		if len(inputs) > 0 && inputs[0].Frame < config.Frame() {
+			return 0, fmt.Errorf("input at frame %d is out of order at frame %d", inputs[0].Frame, config.Frame())
+		}
+
+		if h.ctx.Err() != nil || h.tick() {
+			continue
+		}
+
+		// The game is not running, hence only an input can resume it.
+		if len(inputs) > 0 {
+			return 0, fmt.Errorf("input at frame %d follows a pause at frame %d", inputs[0].Frame, config.Frame())
+		}
+
+		break
+	}
+
+	return h.spaceship.Level.HighScore, nil
+}
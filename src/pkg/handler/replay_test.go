@@ -0,0 +1,147 @@
+//go:build !js || !wasm
+
+package handler
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sarumaj/edu-space-invaders/src/pkg/config"
+	"github.com/sarumaj/edu-space-invaders/src/pkg/numeric"
+)
+
+// play plays a game headlessly the way the game loop of the browser does,
+// handling the events of the script before the frames they are keyed by.
+// It returns the record of the game run and the high score of the spaceship.
+func play(t *testing.T, seed uint64, frames int64, script map[int64][]any) (config.Replay, int) {
+	t.Helper()
+	defer config.SetLogOutput(config.SetLogOutput(io.Discard))
+	defer config.CurrentGameFlags().Apply()
+
+	h := &handler{}
+	h.reset(seed, "")
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	defer h.cancel()
+
+	running.Set(&h.ctx, false)
+	isFirstTime.Set(&h.ctx, true)
+	h.GenerateEnemies(config.Config.Enemy.Count, true)
+
+	for frame := int64(0); frame < frames && h.ctx.Err() == nil; frame++ {
+		for _, event := range script[frame] {
+			switch event := event.(type) {
+			case keyEvent:
+				h.handleKeyEvent(event)
+			case mouseEvent:
+				h.handleMouse(event)
+			case touchEvent:
+				h.handleTouch(event)
+			case config.GameFlags:
+				event.Apply()
+			}
+		}
+
+		_ = h.tick()
+	}
+
+	return config.Replay{Seed: h.seed, Frames: config.Frame(), Inputs: h.inputs, Flags: h.flags}, h.spaceship.Level.HighScore
+}
+
+func TestReplay(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		seed   uint64
+		frames int64
+		script map[int64][]any
+	}{
+		{"test#1", 1, 3600, map[int64][]any{
+			0:   {keyEvent{Key: Space, Pressed: true}},
+			1:   {keyEvent{Key: Space, Pressed: true}},
+			120: {keyEvent{Key: ArrowLeft, Pressed: true}},
+			240: {keyEvent{Key: ArrowLeft}, keyEvent{Key: ArrowRight, Pressed: true}},
+			600: {keyEvent{Key: Pause, Pressed: true}},
+			601: {keyEvent{Key: ArrowUp, Pressed: true}},
+		}},
+		{"test#2", 2, 3600, map[int64][]any{
+			0:    {mouseEvent{Button: MouseButtonPrimary, Type: MouseEventTypeDown, Pressed: true, StartPosition: numeric.Locate(400, 500)}},
+			1:    {mouseEvent{Button: MouseButtonPrimary, Type: MouseEventTypeDown, Pressed: true, StartPosition: numeric.Locate(400, 500)}},
+			300:  {mouseEvent{Button: MouseButtonPrimary, Type: MouseEventTypeMove, Pressed: true, CurrentPosition: numeric.Locate(100, 400)}},
+			900:  {mouseEvent{Button: MouseButtonPrimary, Type: MouseEventTypeMove, Pressed: true, CurrentPosition: numeric.Locate(700, 550)}},
+			1500: {mouseEvent{Button: MouseButtonPrimary, Type: MouseEventTypeUp}},
+		}},
+		{"test#3", 3, 3600, map[int64][]any{
+			0:    {touchEvent{Type: TouchTypeStart, StartPosition: numeric.Locate(400, 500)}},
+			1:    {touchEvent{Type: TouchTypeStart, StartPosition: numeric.Locate(400, 500)}},
+			60:   {touchEvent{Type: TouchTypeMove, CurrentPosition: numeric.Locate(500, 450)}},
+			600:  {touchEvent{Type: TouchTypeMove, CurrentPosition: numeric.Locate(200, 450)}},
+			1200: {touchEvent{Type: TouchTypeEnd}},
+		}},
+		{"test#4", 4, 3600, map[int64][]any{
+			0:    {keyEvent{Key: Space, Pressed: true}},
+			1:    {keyEvent{Key: Space, Pressed: true}, keyEvent{Key: ArrowRight, Pressed: true}},
+			300:  {config.GameFlags{CollisionDetectionVersion: 1, PlanetChoice: -1}},
+			900:  {config.GameFlags{CollisionDetectionVersion: 2, PlanetChoice: -1}, keyEvent{Key: ArrowRight}},
+			1500: {config.GameFlags{CollisionDetectionVersion: 3, PlanetChoice: -1}},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			replay, want := play(t, tt.seed, tt.frames, tt.script)
+			if len(replay.Inputs) == 0 || len(replay.Flags) == 0 {
+				t.Fatal("play() recorded no inputs or no game flags")
+			}
+
+			got, err := Replay(replay)
+			if err != nil {
+				t.Fatalf("Replay() failed: %v", err)
+			}
+
+			if got != want {
+				t.Errorf("Replay() = %d, want %d", got, want)
+			}
+
+			t.Logf("high score %d after %d frames and %d inputs", got, replay.Frames, len(replay.Inputs))
+		})
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	flags := []config.GameFlags{{CollisionDetectionVersion: 3, PlanetChoice: -1}}
+	for _, tt := range []struct {
+		name   string
+		replay config.Replay
+	}{
+		{"test#1", config.Replay{Seed: 1, Frames: 60, Flags: flags, Inputs: []config.Input{
+			{Frame: 0, Device: config.InputDeviceKeyboard, Key: "Escape", Pressed: true},
+		}}},
+		{"test#2", config.Replay{Seed: 1, Frames: 60, Flags: flags, Inputs: []config.Input{
+			{Frame: 0, Device: "joystick"},
+		}}},
+		{"test#3", config.Replay{Seed: 1, Frames: 60, Flags: flags, Inputs: []config.Input{
+			{Frame: 0, Device: config.InputDeviceKeyboard, Key: string(Space), Pressed: true},
+			{Frame: 10, Device: config.InputDeviceKeyboard, Key: string(Space), Pressed: true},
+			{Frame: 5, Device: config.InputDeviceKeyboard, Key: string(Space)},
+		}}},
+		{"test#4", config.Replay{Seed: 1, Frames: 60, Flags: flags, Inputs: []config.Input{
+			{Frame: 10, Device: config.InputDeviceKeyboard, Key: string(Space), Pressed: true},
+		}}},
+		{"test#5", config.Replay{Seed: 1, Frames: 60, Inputs: []config.Input{
+			{Frame: 0, Device: config.InputDeviceKeyboard, Key: string(Space), Pressed: true},
+		}}},
+		{"test#6", config.Replay{Seed: 1, Frames: 60, Flags: []config.GameFlags{{Frame: 5, CollisionDetectionVersion: 3, PlanetChoice: -1}}}},
+		{"test#7", config.Replay{Seed: 1, Frames: 60, Flags: []config.GameFlags{
+			{Frame: 0, CollisionDetectionVersion: 3, PlanetChoice: -1},
+			{Frame: 10, CollisionDetectionVersion: 1, PlanetChoice: -1},
+			{Frame: 5, CollisionDetectionVersion: 2, PlanetChoice: -1},
+		}, Inputs: []config.Input{
+			{Frame: 0, Device: config.InputDeviceKeyboard, Key: string(Space), Pressed: true},
+			{Frame: 1, Device: config.InputDeviceKeyboard, Key: string(Space), Pressed: true},
+		}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Replay(tt.replay); err == nil {
+				t.Error("Replay() should have failed")
+			}
+		})
+	}
+}
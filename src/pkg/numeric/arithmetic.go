@@ -3,8 +3,32 @@ package numeric
 import (
 	"math/rand/v2"
 	"slices"
+	"sync"
 )
 
+// random is the source of the random numbers of the game.
+// It is seeded for every game run, so that a game run can be replayed, see Seed.
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+
+// randomFloat returns a random number in [0, 1).
+func randomFloat() float64 {
+	random.Lock()
+	defer random.Unlock()
+
+	return random.Float64()
+}
+
+// randomIntN returns a random number in [0, n).
+func randomIntN(n int) int {
+	random.Lock()
+	defer random.Unlock()
+
+	return random.IntN(n)
+}
+
 // Equal checks if the two objects are equal.
 func Equal[P interface {
 	Number | Position | Size
@@ -33,13 +57,13 @@ func Randomize[Numeric interface{ ~float64 | ~int }](n Numeric, cutoff Number) N
 
 // RandomRange returns a random number between min and max.
 func RandomRange[Numeric1, Numeric2 interface{ ~float64 | ~int }](min Numeric1, max Numeric2) Number {
-	return Number(min) + Number(rand.Float64())*(Number(max)-Number(min))
+	return Number(min) + Number(randomFloat())*(Number(max)-Number(min))
 }
 
 // RandomSort sorts the slice randomly.
 func RandomSort[Numeric interface{ ~float64 | ~int }](slice []Numeric) []Numeric {
 	slices.SortStableFunc(slice, func(a, b Numeric) int {
-		return rand.IntN(3) - 1
+		return randomIntN(3) - 1
 	})
 
 	return slice
@@ -55,5 +79,13 @@ func SampleUniform[Numeric interface{ ~float64 | ~int }](probability Numeric) bo
 		return true
 	}
 
-	return rand.Float64() < float64(probability)
+	return randomFloat() < float64(probability)
+}
+
+// Seed seeds the source of the random numbers.
+// The same seed yields the same sequence of random numbers.
+func Seed(seed uint64) {
+	random.Lock()
+	random.Rand = rand.New(rand.NewPCG(seed, seed))
+	random.Unlock()
 }
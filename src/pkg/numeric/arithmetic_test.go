@@ -0,0 +1,27 @@
+package numeric
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	sample := func() (numbers []Number) {
+		for i := 0; i < 10; i++ {
+			numbers = append(numbers, RandomRange(0, 100))
+		}
+		return
+	}
+
+	Seed(42)
+	first := sample()
+	Seed(42)
+	if second := sample(); !reflect.DeepEqual(first, second) {
+		t.Errorf("RandomRange() after Seed(42) = %v, want %v", second, first)
+	}
+
+	Seed(43)
+	if third := sample(); reflect.DeepEqual(first, third) {
+		t.Errorf("RandomRange() after Seed(43) = %v, want a different sequence", third)
+	}
+}
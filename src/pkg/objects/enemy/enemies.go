@@ -8,6 +8,13 @@ import (
 // Enemies represents a collection of enemies.
 type Enemies []Enemy
 
+// Animate performs a single step of the transitions of the enemies.
+func (enemies Enemies) Animate() {
+	for i := range enemies {
+		enemies[i].Animate()
+	}
+}
+
 // AppendNew appends a new enemy to the collection.
 // The new enemy is created with the specified name and random Y position.
 // The new enemy is placed at the highest level of the existing enemies.
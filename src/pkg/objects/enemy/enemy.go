@@ -19,6 +19,13 @@ type Enemy struct {
 	kind                EnemyType                 // Type is the type of the enemy.
 }
 
+// Animate performs a single step of the color and size transitions of the enemy.
+// It is meant to be called once per frame, independently of the enemy being drawn.
+func (enemy *Enemy) Animate() {
+	enemy.Color.Interpolate()
+	enemy.Geometry.Interpolate()
+}
+
 // Area returns the area of the enemy.
 func (enemy Enemy) Area() numeric.Number {
 	switch config.Config.Control.CollisionDetectionVersion.Get() {
@@ -106,9 +113,6 @@ func (enemy *Enemy) Draw() {
 		statusColors = append(statusColors, "rgba(240, 0, 0, 0.8)")
 	}
 
-	enemy.Color.Interpolate()
-	enemy.Geometry.Interpolate()
-
 	config.DrawSpaceship(
 		enemy.Geometry.Position().Pack(),
 		enemy.Geometry.Size().Pack(),
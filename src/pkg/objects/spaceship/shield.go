@@ -3,6 +3,7 @@ package spaceship
 import (
 	"time"
 
+	"github.com/sarumaj/edu-space-invaders/src/pkg/config"
 	"github.com/sarumaj/edu-space-invaders/src/pkg/numeric"
 )
 
@@ -11,7 +12,7 @@ type Shield struct {
 	Charge         int // Charge is the charge of the shield.
 	Capacity       int // Capacity is the capacity of the shield.
 	ChargeDuration time.Duration
-	lastChargedAt  time.Time
+	lastChargedAt  time.Time // lastChargedAt is the game time of the last charge, see config.Now.
 }
 
 // Health returns the health of the shield.
@@ -25,13 +26,13 @@ func (shield *Shield) Recharge() {
 	switch {
 	case
 		shield.Charge == shield.Capacity,
-		time.Since(shield.lastChargedAt) < shield.ChargeDuration:
+		config.Now().Sub(shield.lastChargedAt) < shield.ChargeDuration:
 
 		return
 	}
 
 	shield.Charge += 1
-	shield.lastChargedAt = time.Now()
+	shield.lastChargedAt = config.Now()
 }
 
 // Reduce reduces the shield charge and capacity.
@@ -26,9 +26,9 @@ type Spaceship struct {
 	Bullets             bullet.Bullets             // Bullets fired by the spaceship
 	Level               *SpaceshipLevel            // Spaceship level
 	state               SpaceshipState             // Spaceship state
-	lastFired           time.Time                  // Last time the spaceship fired
-	lastStateTransition time.Time                  // Last time the spaceship changed state
-	lastDiscovery       time.Time                  // Last time the spaceship discovered a planet
+	lastFired           time.Time                  // Last game time the spaceship fired
+	lastStateTransition time.Time                  // Last game time the spaceship changed state
+	lastDiscovery       time.Time                  // Last game time the spaceship discovered a planet
 	discoveredPlanets   map[planet.PlanetType]bool // Discovered planets
 }
 
@@ -41,8 +41,9 @@ func (spaceship *Spaceship) ifFrozen() bool {
 		config.SendMessageThrottled(
 			config.Execute(config.Config.MessageBox.Messages.SpaceshipStillFrozen,
 				config.Template{
-					"FreezeDuration": time.Until(spaceship.lastStateTransition.
-						Add(config.Config.Spaceship.FreezeDuration)).
+					"FreezeDuration": spaceship.lastStateTransition.
+						Add(config.Config.Spaceship.FreezeDuration).
+						Sub(config.Now()).
 						Round(config.Config.MessageBox.ChannelLogThrottling),
 				},
 			), false, true, config.Config.MessageBox.ChannelLogThrottling)
@@ -66,6 +67,13 @@ func (spaceship Spaceship) Area() numeric.Number {
 	return spaceship.Geometry.Size().Area()
 }
 
+// Animate performs a single step of the color and size transitions of the spaceship.
+// It is meant to be called once per frame, independently of the spaceship being drawn.
+func (spaceship *Spaceship) Animate() {
+	spaceship.Color.Interpolate()
+	spaceship.Geometry.Interpolate()
+}
+
 // ApplyRepulsion applies repulsion to the spaceship and the enemy.
 // The repulsion is applied based on the spaceship's and enemy speed and direction.
 func (spaceship *Spaceship) ApplyRepulsion(e enemy.Enemy) numeric.Position {
@@ -110,7 +118,7 @@ func (spaceship *Spaceship) ApplyRepulsion(e enemy.Enemy) numeric.Position {
 // and its size is doubled. If the number of cannons exceeds
 // the maximum number of cannons, it is set to the maximum number.
 func (spaceship *Spaceship) ChangeState(state SpaceshipState) {
-	spaceship.lastStateTransition = time.Now()
+	spaceship.lastStateTransition = config.Now()
 	if spaceship.state == state {
 		return
 	}
@@ -186,13 +194,13 @@ func (spaceship *Spaceship) Discover(p *planet.Planet) bool {
 		!p.Type.IsPlanet(), // If the celestial object is not an actual planet
 		!p.WithinRange(spaceship.Geometry.Position().Add(spaceship.Geometry.Size().Half().ToVector()), 1), // If the spaceship is not within range of the planet
 		spaceship.discoveredPlanets[p.Type], // If the planet has been discovered
-		time.Since(spaceship.lastDiscovery) < config.Config.Planet.DiscoveryCooldown*time.Duration(len(spaceship.discoveredPlanets)), // If a planet has been discovered recently
-		!numeric.SampleUniform(config.Config.Planet.DiscoveryProbability):                                                            // If the planet is not discovered based on the probability
+		config.Now().Sub(spaceship.lastDiscovery) < config.Config.Planet.DiscoveryCooldown*time.Duration(len(spaceship.discoveredPlanets)), // If a planet has been discovered recently
+		!numeric.SampleUniform(config.Config.Planet.DiscoveryProbability):                                                                  // If the planet is not discovered based on the probability
 
 		return false
 	}
 
-	spaceship.lastDiscovery = time.Now()
+	spaceship.lastDiscovery = config.Now()
 	spaceship.discoveredPlanets[p.Type] = true
 
 	return true
@@ -244,8 +252,6 @@ func (spaceship *Spaceship) Draw() {
 		statusColors = append(statusColors, "rgba(0, 0, 240, 0.8)") // Blue
 	}
 
-	spaceship.Color.Interpolate()
-	spaceship.Geometry.Interpolate()
 	config.DrawSpaceship(
 		spaceship.Geometry.Position().Pack(),
 		spaceship.Geometry.Size().Pack(),
@@ -267,7 +273,7 @@ func (spaceship *Spaceship) Fire() {
 	switch {
 	case
 		spaceship.ifFrozen(),
-		time.Since(spaceship.lastFired) < spaceship.Cooldown:
+		config.Now().Sub(spaceship.lastFired) < spaceship.Cooldown:
 
 		return
 	}
@@ -298,7 +304,7 @@ func (spaceship *Spaceship) Fire() {
 		)
 	}
 
-	spaceship.lastFired = time.Now()
+	spaceship.lastFired = config.Now()
 
 	go config.PlayAudio("spaceship_cannon_fire.wav", false)
 }
@@ -527,7 +533,7 @@ func (spaceship Spaceship) String() string {
 // If the time since the last state transition is greater than
 // the spaceship state duration, the spaceship's state is set to Neutral.
 func (spaceship *Spaceship) UpdateState() {
-	if time.Since(spaceship.lastStateTransition) < spaceship.state.GetDuration() {
+	if config.Now().Sub(spaceship.lastStateTransition) < spaceship.state.GetDuration() {
 		return
 	}
 
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	zap "go.uber.org/zap"
+)
+
+// MetricsConfig configures the request counts and the Prometheus metrics.
+type MetricsConfig struct {
+	Address       string        `yaml:"address"`        // Address serves the Prometheus metrics without authentication, if not empty.
+	Buffer        int           `yaml:"buffer"`         // Buffer is the maximum number of distinct endpoints and methods buffered.
+	FlushInterval time.Duration `yaml:"flush_interval"` // FlushInterval is the interval to save the buffered request counts.
+	FlushSize     int           `yaml:"flush_size"`     // FlushSize is the number of requests after which the request counts are saved early.
+}
+
+// metricAggregator counts the requests per endpoint and method in memory and saves them to the store in batches.
+// A batch is saved every interval or as soon as the given number of requests has been recorded, whichever comes first.
+// The number of distinct endpoints and methods held in memory is bounded by the capacity,
+// requests to further endpoints are dropped until the next batch has been saved.
+// Batches which failed to save are retried with the next batch.
+type metricAggregator struct {
+	store     Store
+	interval  time.Duration
+	batchSize int
+	capacity  int
+	logger    *zap.Logger
+
+	mutex    sync.Mutex
+	pending  map[[2]string]int64 // pending are the counts not saved yet, keyed by endpoint and method.
+	requests int                 // requests is the number of requests recorded since the last batch.
+
+	dropped atomic.Uint64 // dropped is the number of requests which did not fit into the buffer.
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	err     error // err is the error of the final flush.
+}
+
+// Close stops the aggregator and saves the pending counts.
+// It is safe to call Close multiple times.
+func (a *metricAggregator) Close() error {
+	a.once.Do(func() { close(a.stop) })
+	<-a.done
+	return a.err
+}
+
+// Dropped returns the number of requests which have been dropped because the buffer was full.
+func (a *metricAggregator) Dropped() uint64 { return a.dropped.Load() }
+
+// Pending returns the number of distinct endpoints and methods which have not been saved yet.
+func (a *metricAggregator) Pending() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return len(a.pending)
+}
+
+// Record counts the request to the endpoint.
+// It never blocks on the store.
+func (a *metricAggregator) Record(endpoint, method string) {
+	key := [2]string{endpoint, method}
+
+	a.mutex.Lock()
+	if _, ok := a.pending[key]; !ok && len(a.pending) >= a.capacity {
+		a.mutex.Unlock()
+		a.dropped.Add(1)
+		return
+	}
+
+	a.pending[key]++
+	a.requests++
+	full := a.requests >= a.batchSize
+	a.mutex.Unlock()
+
+	if full {
+		select {
+		case a.trigger <- struct{}{}:
+		default: // A flush is already pending.
+		}
+	}
+}
+
+// flush saves the pending counts in a single batch.
+// If the batch fails to save, the counts are merged back into the buffer as far as it has capacity left.
+func (a *metricAggregator) flush() error {
+	a.mutex.Lock()
+	pending := a.pending
+	a.pending, a.requests = make(map[[2]string]int64, len(pending)), 0
+	a.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// Sort the batch to update the rows in a consistent order.
+	metrics := make([]Metric, 0, len(pending))
+	for key, count := range pending {
+		metrics = append(metrics, Metric{Endpoint: key[0], Method: key[1], Count: count})
+	}
+
+	slices.SortFunc(metrics, func(a, b Metric) int {
+		return cmp.Or(cmp.Compare(a.Endpoint, b.Endpoint), cmp.Compare(a.Method, b.Method))
+	})
+
+	if err := a.store.SaveMetrics(metrics); err != nil {
+		a.mutex.Lock()
+		var dropped uint64
+		for key, count := range pending {
+			if _, ok := a.pending[key]; !ok && len(a.pending) >= a.capacity {
+				dropped += uint64(count)
+				continue
+			}
+			a.pending[key] += count
+		}
+		a.mutex.Unlock()
+
+		a.dropped.Add(dropped)
+		a.logger.Error("Failed to save metrics", zap.Int("batch", len(metrics)), zap.Uint64("dropped", dropped), zap.Error(err))
+		return err
+	}
+
+	a.logger.Debug("Metrics saved", zap.Int("batch", len(metrics)))
+	return nil
+}
+
+// run saves the batches until the aggregator is closed.
+func (a *metricAggregator) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.trigger:
+		case <-a.stop:
+			a.err = a.flush()
+			return
+		}
+
+		_ = a.flush()
+	}
+}
+
+// MetricAggregator starts an aggregator saving the request counts to the store.
+// The batches are saved every interval or every batchSize requests.
+// At most capacity distinct endpoints and methods are buffered.
+// The aggregator must be closed to save the last batch, failures to save a batch are logged.
+func MetricAggregator(store Store, interval time.Duration, batchSize, capacity int, logger *zap.Logger) *metricAggregator {
+	a := &metricAggregator{
+		store:     store,
+		interval:  interval,
+		batchSize: max(batchSize, 1),
+		capacity:  max(capacity, 1),
+		logger:    logger,
+		pending:   make(map[[2]string]int64),
+		trigger:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go a.run()
+	return a
+}
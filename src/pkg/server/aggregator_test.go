@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	zap "go.uber.org/zap"
+)
+
+// failingStore is a store which fails to save metrics until it is healed.
+type failingStore struct {
+	*memoryStore
+	mutex   sync.Mutex
+	failing bool
+}
+
+func (s *failingStore) SaveMetrics(metrics []Metric) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.failing {
+		return errors.New("database unavailable")
+	}
+
+	return s.memoryStore.SaveMetrics(metrics)
+}
+
+func TestMetricAggregator(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		batchSize   int
+		capacity    int
+		args        [][2]string
+		want        map[[2]string]int64
+		wantDropped uint64
+	}{
+		{"test#1", 100, 10, nil, map[[2]string]int64{}, 0},
+		{"test#2", 100, 10, [][2]string{{"/a", "GET"}, {"/b", "GET"}, {"/a", "GET"}, {"/a", "HEAD"}},
+			map[[2]string]int64{{"/a", "GET"}: 2, {"/a", "HEAD"}: 1, {"/b", "GET"}: 1}, 0},
+		{"test#3", 100, 2, [][2]string{{"/a", "GET"}, {"/b", "GET"}, {"/c", "GET"}, {"/a", "GET"}},
+			map[[2]string]int64{{"/a", "GET"}: 2, {"/b", "GET"}: 1}, 1},
+		{"test#4", 1, 1, [][2]string{{"/a", "GET"}, {"/a", "GET"}, {"/a", "GET"}},
+			map[[2]string]int64{{"/a", "GET"}: 3}, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			aggregator := MetricAggregator(store, time.Hour, tt.batchSize, tt.capacity, zap.NewNop())
+			for _, arg := range tt.args {
+				aggregator.Record(arg[0], arg[1])
+			}
+
+			if err := aggregator.Close(); err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+
+			metrics, _ := store.GetMetrics()
+			got := make(map[[2]string]int64)
+			for _, metric := range metrics {
+				got[[2]string{metric.Endpoint, metric.Method}] = metric.Count
+			}
+
+			if len(got) != len(tt.want) {
+				t.Errorf("GetMetrics() = %v, want %v", got, tt.want)
+			}
+
+			for key, count := range tt.want {
+				if got[key] != count {
+					t.Errorf("GetMetrics()[%v] = %d, want %d", key, got[key], count)
+				}
+			}
+
+			if got := aggregator.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestMetricAggregatorBatchSize(t *testing.T) {
+	store := newMemoryStore()
+	aggregator := MetricAggregator(store, time.Hour, 2, 10, zap.NewNop())
+	defer func() { _ = aggregator.Close() }()
+
+	aggregator.Record("/a", "GET")
+	aggregator.Record("/a", "GET")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if metrics, _ := store.GetMetrics(); len(metrics) == 1 && metrics[0].Count == 2 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the batch has not been saved after reaching the batch size")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMetricAggregatorRetry(t *testing.T) {
+	store := &failingStore{memoryStore: newMemoryStore(), failing: true}
+	aggregator := MetricAggregator(store, time.Hour, 100, 2, zap.NewNop())
+
+	aggregator.Record("/a", "GET")
+	aggregator.Record("/b", "GET")
+	if err := aggregator.flush(); err == nil {
+		t.Fatalf("flush() succeeded, want error")
+	}
+
+	// The failed batch fills the buffer again, so new endpoints are dropped.
+	aggregator.Record("/a", "GET")
+	aggregator.Record("/c", "GET")
+
+	store.mutex.Lock()
+	store.failing = false
+	store.mutex.Unlock()
+
+	if err := aggregator.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	metrics, _ := store.GetMetrics()
+	if len(metrics) != 2 || metrics[0].Endpoint != "/a" || metrics[0].Count != 2 || metrics[1].Count != 1 {
+		t.Errorf("GetMetrics() = %v, want /a counted twice and /b counted once", metrics)
+	}
+
+	if got := aggregator.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want %d", got, 1)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	dist "github.com/sarumaj/edu-space-invaders/dist"
+)
+
+// immutableCacheControl is the Cache-Control header of the assets served under their content-addressed URL.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// assetPath matches the content-addressed URLs of the assets, /a/<sha256>/<name>, see cmd/fingerprint.
+var assetPath = regexp.MustCompile(`^/?a/([0-9a-f]{64})/(.+)$`)
+
+// unversionedAssets are always served under their own path: the entry point, whose URL is bookmarked,
+// and the service worker, whose URL must not change between its versions.
+var unversionedAssets = []string{defaultEndpoint, "service-worker.js"}
+
+// assetExists reports whether the distribution package contains the asset.
+func assetExists(name string) bool {
+	_, ok := dist.LookupHash(name)
+	return ok
+}
+
+// assetName returns the name of the static asset served on the path, which may be a content-addressed URL.
+func assetName(path string) string {
+	if match := assetPath.FindStringSubmatch(path); match != nil {
+		return match[2]
+	}
+
+	if name := strings.Trim(path, "/"); name != "" {
+		return name
+	}
+
+	return defaultEndpoint
+}
+
+// assetURL returns the content-addressed URL of the current version of the asset.
+// It reports false for the unversioned assets and the precompressed variants, which are served under their own path.
+func assetURL(name string) (string, bool) {
+	hash, ok := dist.LookupHash(name)
+	if !ok || slices.Contains(unversionedAssets, name) {
+		return "", false
+	}
+
+	for _, encoding := range contentEncodings {
+		if base, ok := strings.CutSuffix(name, encoding.extension); ok && assetExists(base) {
+			return "", false
+		}
+	}
+
+	return "/a/" + hash + "/" + name, true
+}
+
+// isImmutable reports whether the path is the content-addressed URL of the current version of its asset.
+func isImmutable(path string) bool {
+	location, ok := assetURL(assetName(path))
+	return ok && location == "/"+strings.TrimLeft(path, "/")
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+	dist "github.com/sarumaj/edu-space-invaders/dist"
+)
+
+func TestAssetURL(t *testing.T) {
+	hash := func(name string) string {
+		hash, _ := dist.LookupHash(name)
+		return hash
+	}
+
+	for _, tt := range []struct {
+		name   string
+		args   string
+		want   string
+		wantOk bool
+	}{
+		{"test#1", "main.wasm", "/a/" + hash("main.wasm") + "/main.wasm", true},
+		{"test#2", "icons/icon-192x192.png", "/a/" + hash("icons/icon-192x192.png") + "/icons/icon-192x192.png", true},
+		{"test#3", "index.html", "", false},
+		{"test#4", "service-worker.js", "", false},
+		{"test#5", "style.css.br", "", false},
+		{"test#6", "missing.js", "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := assetURL(tt.args)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("assetURL(%q) = (%q, %v), want (%q, %v)", tt.args, got, ok, tt.want, tt.wantOk)
+			}
+
+			if ok && (assetName(got) != tt.args || !isImmutable(got)) {
+				t.Errorf("assetName(%q) = %q, isImmutable(%q) = %v", got, assetName(got), got, isImmutable(got))
+			}
+		})
+	}
+}
+
+func TestServeContentAddressed(t *testing.T) {
+	router := gin.New()
+	router.Use(CacheControlMiddleware())
+	router.Match([]string{http.MethodHead, http.MethodGet}, "/*filepath", BeHeadMiddleware(), ServeFileSystem(nil))
+
+	location := func(name string) string {
+		location, ok := assetURL(name)
+		if !ok {
+			t.Fatalf("asset %s is not versioned", name)
+		}
+		return location
+	}
+
+	stale := "/a/" + strings.Repeat("0", 64) + "/wasm.js"
+	for _, tt := range []struct {
+		name             string
+		target           string
+		want             int
+		wantLocation     string
+		wantCacheControl string
+	}{
+		{"test#1", location("wasm.js"), http.StatusOK, "", immutableCacheControl},
+		{"test#2", "/wasm.js", http.StatusFound, location("wasm.js"), "public, must-revalidate"},
+		{"test#3", stale, http.StatusFound, location("wasm.js"), "public, must-revalidate"},
+		{"test#4", "/icons/icon-192x192.png", http.StatusFound, location("icons/icon-192x192.png"), "public, must-revalidate"},
+		{"test#5", "/", http.StatusOK, "", "public, must-revalidate"},
+		{"test#6", "/service-worker.js", http.StatusOK, "", "public, must-revalidate"},
+		{"test#7", "/a/" + strings.Repeat("0", 64) + "/service-worker.js", http.StatusNotFound, "", "public, must-revalidate"},
+		{"test#8", "/main.wasm.sha256", http.StatusOK, "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.target, rec.Code, tt.want)
+			}
+
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("GET %s: Location = %q, want %q", tt.target, got, tt.wantLocation)
+			}
+
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("GET %s: Cache-Control = %q, want %q", tt.target, got, tt.wantCacheControl)
+			}
+
+			if got := rec.Header().Get("Last-Modified"); rec.Code == http.StatusOK && got == "" {
+				t.Errorf("GET %s: missing Last-Modified", tt.target)
+			}
+		})
+	}
+}
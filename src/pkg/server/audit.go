@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	zap "go.uber.org/zap"
+)
+
+const (
+	auditActionRetention = "RETENTION" // auditActionRetention is the action of the scheduled runs of the retention policy.
+	auditActorSystem     = "system"    // auditActorSystem is the actor of the actions taken by the server itself.
+	requestIDHeader      = "X-Request-ID"
+)
+
+// auditRecord holds the details of an audited action, given by the handler of the request.
+type auditRecord struct {
+	Target string
+	Before any
+	After  any
+}
+
+// auditor records the privileged actions in the audit log of the store.
+type auditor struct {
+	store  Store
+	logger *zap.Logger
+}
+
+// Record saves the event to the audit log.
+// The before and after values are encoded as JSON, nil values are omitted.
+// Failures are logged only, so that the action itself is not affected.
+func (a auditor) Record(event AuditEvent, before, after any) {
+	var err error
+	if event.Before, err = marshalAuditValue(before); err == nil {
+		event.After, err = marshalAuditValue(after)
+	}
+
+	if err == nil {
+		err = a.store.SaveAuditEvent(event)
+	}
+
+	if err != nil {
+		a.logger.Error("Failed to record audit event", zap.String("actor", event.Actor), zap.String("action", event.Action), zap.Error(err))
+	}
+}
+
+// AuditMiddleware is a middleware that records the authenticated mutating requests in the audit log.
+// The event is recorded once the request has been handled, the actor is the subject of the JWT claims.
+// Handlers describe the target and the before and after values of their action with setAudit.
+// Requests which have not been authenticated, e.g. rejected with a 401 status code, are not recorded.
+func AuditMiddleware(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			ctx.Next()
+			return
+		}
+
+		occurredAt := time.Now()
+		ctx.Next()
+
+		if _, ok := ctx.Get("claims"); !ok {
+			return
+		}
+
+		var record auditRecord
+		if value, ok := ctx.Get("audit"); ok {
+			record, _ = value.(auditRecord)
+		}
+
+		auditor{store: store, logger: getLogger(ctx)}.Record(AuditEvent{
+			OccurredAt: occurredAt,
+			Actor:      getSubject(ctx),
+			Action:     ctx.Request.Method + " " + getRoute(ctx),
+			Target:     record.Target,
+			Status:     ctx.Writer.Status(),
+			ClientIP:   ctx.ClientIP(),
+			RequestID:  ctx.GetString("requestID"),
+		}, record.Before, record.After)
+	}
+}
+
+// RequestIDMiddleware is a middleware that identifies each request.
+// It keeps the identifier given by the X-Request-ID header of the request, e.g. set by a reverse proxy,
+// or generates a random one, and echoes it in the X-Request-ID header of the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			var err error
+			if requestID, err = newSessionID(); err != nil {
+				getLogger(ctx).Error("Failed to generate request ID", zap.Error(err))
+			}
+		}
+
+		ctx.Set("requestID", requestID)
+		ctx.Header(requestIDHeader, requestID)
+		ctx.Next()
+	}
+}
+
+// marshalAuditValue encodes the value as JSON, it returns nil for a nil value.
+func marshalAuditValue(value any) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(value)
+}
+
+// setAudit describes the action of the request for the audit log, see AuditMiddleware.
+func setAudit(ctx *gin.Context, target string, before, after any) {
+	ctx.Set("audit", auditRecord{Target: target, Before: before, After: after})
+}
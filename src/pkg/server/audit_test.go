@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	store := newMemoryStore()
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), AuditMiddleware(store), func(ctx *gin.Context) {
+		if subject := ctx.GetHeader("X-Subject"); subject != "" {
+			ctx.Set("claims", jwt.RegisteredClaims{Subject: subject})
+		}
+	})
+	router.POST("/.env", HandleEnv(store, nil))
+	router.GET("/api/v1/admin/audit", GetAuditEvents(store))
+
+	for _, tt := range []struct {
+		name    string
+		subject string
+		body    string
+		want    int
+	}{
+		{"test#1", "", `{"SPACE_INVADERS_A":"1"}`, http.StatusOK},
+		{"test#2", "admin", `{"SPACE_INVADERS_A":"1"}`, http.StatusOK},
+		{"test#3", "admin", `{"SPACE_INVADERS_A":null}`, http.StatusOK},
+		{"test#4", "other", `{`, http.StatusBadRequest},
+	} {
+		if rec := serve(router, http.MethodPost, "/.env", tt.subject, tt.body); rec.Code != tt.want {
+			t.Fatalf("%s: POST /.env = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	for _, tt := range []struct {
+		name      string
+		query     string
+		want      []string
+		wantAfter string
+	}{
+		{"test#1", "", []string{"other", "admin", "admin"}, ""},
+		{"test#2", "?actor=admin&limit=1", []string{"admin"}, `{"SPACE_INVADERS_A":null}`},
+		{"test#3", "?action=POST+%2F.env&actor=nobody", []string{}, ""},
+		{"test#4", "?until=2000-01-01T00:00:00Z", []string{}, ""},
+		{"test#5", "?since=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), []string{"other", "admin", "admin"}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, "/api/v1/admin/audit"+tt.query, "", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /api/v1/admin/audit = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var got struct{ Events []AuditEvent }
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			actors := []string{}
+			for _, event := range got.Events {
+				if event.Action != "POST /.env" || event.RequestID == "" {
+					t.Errorf("event = %+v, want action POST /.env with a request ID", event)
+				}
+				actors = append(actors, event.Actor)
+			}
+
+			if len(actors) != len(tt.want) {
+				t.Fatalf("actors = %v, want %v", actors, tt.want)
+			}
+			for i := range actors {
+				if actors[i] != tt.want[i] {
+					t.Fatalf("actors = %v, want %v", actors, tt.want)
+				}
+			}
+
+			if tt.wantAfter != "" && string(got.Events[0].After) != tt.wantAfter {
+				t.Errorf("after = %s, want %s", got.Events[0].After, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.GetString("requestID")) })
+
+	rec := serve(router, http.MethodGet, "/", "", "")
+	if got := rec.Header().Get(requestIDHeader); got == "" || got != rec.Body.String() {
+		t.Errorf("X-Request-ID = %q, want generated %q", got, rec.Body.String())
+	}
+
+	req := serve(router, http.MethodGet, "/", "", "")
+	if req.Header().Get(requestIDHeader) == rec.Header().Get(requestIDHeader) {
+		t.Errorf("X-Request-ID = %q, want a distinct identifier per request", req.Header().Get(requestIDHeader))
+	}
+}
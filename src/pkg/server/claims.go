@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const tokenIssuer = "space-invaders" // tokenIssuer is the issuer and the audience of the JWT tokens.
+
+const (
+	ScopeAdmin       = "admin"        // ScopeAdmin grants all scopes.
+	ScopeConfigRead  = "config:read"  // ScopeConfigRead grants read access to the configuration of the game engine and to the history of the feature flags.
+	ScopeConfigWrite = "config:write" // ScopeConfigWrite grants write access to the feature flags of the game.
+	ScopePlayer      = "player"       // ScopePlayer grants access to the game, e.g. to submit scores.
+)
+
+// Claims represents the claims of the JWT tokens issued for the game server.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"` // Scope is a space-separated list of granted scopes.
+}
+
+// HasScope returns true if the claims grant the given scope.
+// The admin scope grants all scopes.
+func (c Claims) HasScope(scope string) bool {
+	scopes := c.Scopes()
+	return slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
+
+// Scopes returns the granted scopes.
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// getClaims returns the JWT claims of the authenticated request.
+// It returns nil if the request has not been authenticated.
+func getClaims(ctx *gin.Context) *Claims {
+	claims, ok := ctx.Get("claims")
+	if !ok {
+		return nil
+	}
+
+	switch claims := claims.(type) {
+	case *Claims:
+		return claims
+
+	case Claims:
+		return &claims
+
+	default:
+		return nil
+
+	}
+}
+
+// IssueToken signs a JWT token for the given subject and scopes using the most recent RSA key of the key set.
+// If the time to live is not positive, the token does not expire.
+func IssueToken(keys *KeySet, subject string, ttl time.Duration, scopes ...string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   tokenIssuer,
+			Audience: jwt.ClaimStrings{tokenIssuer},
+			IssuedAt: jwt.NewNumericDate(now),
+			Subject:  subject,
+		},
+		Scope: strings.Join(scopes, " "),
+	}
+
+	if ttl > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	}
+
+	return keys.Sign(claims)
+}
+
+// TokenParser returns a function that parses and verifies a JWT token using the key set.
+// It validates the signing method, the key identifier, the issuer, the audience and the time based claims.
+func TokenParser(keys *KeySet) func(string) (*Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(tokenIssuer),
+		jwt.WithIssuedAt(),
+		jwt.WithPaddingAllowed(),
+	)
+
+	validatorFunc := func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing algorithm: %s", token.Method.Alg())
+		}
+
+		return keys.VerificationKey(token)
+	}
+
+	return func(raw string) (*Claims, error) {
+		claims := &Claims{}
+		if _, err := parser.ParseWithClaims(raw, claims, validatorFunc); err != nil {
+			return nil, err
+		}
+
+		return claims, nil
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import "testing"
+
+func TestClaimsHasScope(t *testing.T) {
+	type args struct {
+		granted string
+		scope   string
+	}
+
+	for _, tt := range []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"test#1", args{"", ScopePlayer}, false},
+		{"test#2", args{ScopePlayer, ScopePlayer}, true},
+		{"test#3", args{ScopePlayer, ScopeConfigWrite}, false},
+		{"test#4", args{ScopePlayer + " " + ScopeConfigRead, ScopeConfigRead}, true},
+		{"test#5", args{ScopeAdmin, ScopeConfigWrite}, true},
+		{"test#6", args{"config", ScopeConfigRead}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Claims{Scope: tt.args.granted}).HasScope(tt.args.scope); got != tt.want {
+				t.Errorf("Claims.HasScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
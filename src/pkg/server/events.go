@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	zap "go.uber.org/zap"
+)
+
+const (
+	eventBuffer      = 32               // eventBuffer is the number of events buffered per subscriber.
+	eventKeepAlive   = 15 * time.Second // eventKeepAlive is the interval of the comments keeping idle streams open.
+	eventRetry       = 5 * time.Second  // eventRetry is the reconnection delay suggested to the clients.
+	eventSubscribers = 1000             // eventSubscribers is the maximum number of concurrent streams.
+	eventTopScores   = 10               // eventTopScores is the number of ranks announced when reached by a new best score.
+)
+
+const (
+	eventTypeFlags  = "flags"  // eventTypeFlags announces changes of the feature flags.
+	eventTypeNotice = "notice" // eventTypeNotice carries a notice of the operators.
+	eventTypeScore  = "score"  // eventTypeScore announces a new best score among the top scores.
+)
+
+// serverEvent is an event pushed to the subscribers.
+type serverEvent struct {
+	ID   uint64
+	Type string
+	Data any
+	// visible reports whether the event concerns the subscriber of the given player and session, nil for all subscribers.
+	visible func(player, session string) bool
+}
+
+// EventBroker fans the events out to the subscribers of the event stream.
+// A subscriber which does not keep up with the events is dropped, so that it reconnects and resynchronizes.
+type EventBroker struct {
+	mutex       sync.Mutex
+	lastID      uint64
+	subscribers map[chan serverEvent]struct{}
+	closed      bool
+	logger      *zap.Logger
+}
+
+// Close ends all streams, e.g. once the server shuts down.
+func (b *EventBroker) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	for subscriber := range b.subscribers {
+		delete(b.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// Publish sends the event to all subscribers.
+func (b *EventBroker) Publish(event serverEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			b.logger.Debug("Dropping slow event subscriber", zap.String("type", event.Type))
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the published events and a function to unsubscribe.
+// The channel is closed once the subscriber is dropped or the broker is closed.
+// It fails if the broker has been closed or the maximum number of subscribers is reached.
+func (b *EventBroker) Subscribe() (<-chan serverEvent, func(), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch {
+	case b.closed:
+		return nil, nil, fmt.Errorf("server is shutting down")
+	case len(b.subscribers) >= eventSubscribers:
+		return nil, nil, fmt.Errorf("too many subscribers")
+	}
+
+	subscriber := make(chan serverEvent, eventBuffer)
+	b.subscribers[subscriber] = struct{}{}
+
+	return subscriber, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		if _, ok := b.subscribers[subscriber]; ok {
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}, nil
+}
+
+// Subscribers returns the number of subscribers.
+func (b *EventBroker) Subscribers() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.subscribers)
+}
+
+// Wrap returns the store publishing the changes of the feature flags and the new top scores saved through it.
+func (b *EventBroker) Wrap(store Store) Store {
+	return publishingStore{Store: store, broker: b}
+}
+
+// publishingStore is a store publishing the changes saved through it to the event broker.
+type publishingStore struct {
+	Store
+	broker *EventBroker
+}
+
+// SaveFeatureFlags saves the feature flags and publishes the recorded changes.
+// Each subscriber receives the changes concerning it: global changes and the changes of its player or session.
+func (s publishingStore) SaveFeatureFlags(changes []FeatureFlagChange) ([]FeatureFlagChange, error) {
+	recorded, err := s.Store.SaveFeatureFlags(changes)
+	if err != nil || len(recorded) == 0 {
+		return recorded, err
+	}
+
+	keys := make([]string, 0, len(recorded))
+	for _, change := range recorded {
+		keys = append(keys, change.Key)
+	}
+
+	scope, subject := recorded[0].Scope, recorded[0].Subject
+	s.broker.Publish(serverEvent{
+		Type: eventTypeFlags,
+		Data: gin.H{"keys": keys, "scope": scope},
+		visible: func(player, session string) bool {
+			switch scope {
+			case flagScopePlayer:
+				return player == subject
+			case flagScopeSession:
+				return session == subject
+			default:
+				return true
+			}
+		},
+	})
+
+	return recorded, nil
+}
+
+// SaveRun saves the game run and publishes the best score of the player if it has been improved by the run
+// and ranks among the top scores.
+func (s publishingStore) SaveRun(run ScoreRun) error {
+	previous, err := s.Store.GetScore(run.Name)
+	if err != nil {
+		previous = Score{Score: -1}
+	}
+
+	if err := s.Store.SaveRun(run); err != nil {
+		return err
+	}
+
+	best, err := s.Store.GetScore(run.Name)
+	if err != nil || best.Score <= previous.Score {
+		return nil
+	}
+
+	if rank, err := s.Store.GetRank(run.Name); err == nil && rank <= eventTopScores {
+		s.broker.Publish(serverEvent{Type: eventTypeScore, Data: ScoreResult{Name: best.Name, Score: best.Score, Rank: rank}})
+	}
+
+	return nil
+}
+
+// newEventBroker returns an event broker without subscribers, logging the dropped subscribers.
+func newEventBroker(logger *zap.Logger) *EventBroker {
+	return &EventBroker{subscribers: make(map[chan serverEvent]struct{}), logger: logger}
+}
+
+// writeEvent writes the event in the format of server-sent events.
+func writeEvent(w io.Writer, event serverEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, strings.ReplaceAll(string(data), "\n", "\ndata: "))
+	return err
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+	zap "go.uber.org/zap"
+)
+
+func TestEventBroker(t *testing.T) {
+	broker := newEventBroker(zap.NewNop())
+
+	fast, unsubscribe, err := broker.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	defer unsubscribe()
+
+	slow, _, err := broker.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	for i := 0; i < eventBuffer+1; i++ {
+		broker.Publish(serverEvent{Type: eventTypeNotice})
+		if event := <-fast; event.ID != uint64(i+1) {
+			t.Fatalf("event #%d has ID %d, want %d", i+1, event.ID, i+1)
+		}
+	}
+
+	if got := broker.Subscribers(); got != 1 {
+		t.Errorf("Subscribers() = %d, want the slow subscriber dropped", got)
+	}
+
+	drained := 0
+	for range slow {
+		drained++
+	}
+	if drained != eventBuffer {
+		t.Errorf("slow subscriber received %d events, want %d", drained, eventBuffer)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if got := broker.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() after unsubscribe = %d, want 0", got)
+	}
+
+	broker.Close()
+	if _, _, err := broker.Subscribe(); err == nil {
+		t.Errorf("Subscribe() after Close() succeeded, want error")
+	}
+}
+
+func TestPublishingStore(t *testing.T) {
+	broker := newEventBroker(zap.NewNop())
+	store := broker.Wrap(newTestStore(t, Score{Name: "a", Score: 1_000_000}))
+
+	events, unsubscribe, err := broker.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	defer unsubscribe()
+
+	receive := func() (serverEvent, bool) {
+		select {
+		case event := <-events:
+			return event, true
+		default:
+			return serverEvent{}, false
+		}
+	}
+
+	if err := store.SaveRun(ScoreRun{Name: "b", Level: 3, Duration: time.Second}); err != nil {
+		t.Fatalf("SaveRun() failed: %v", err)
+	}
+	event, ok := receive()
+	if result, isResult := event.Data.(ScoreResult); !ok || event.Type != eventTypeScore || !isResult || result.Name != "b" || result.Rank != 2 {
+		t.Errorf("SaveRun() of a new player published %+v, want score event of b at rank 2", event)
+	}
+
+	if err := store.SaveRun(ScoreRun{Name: "b", Level: 1, Duration: time.Second}); err != nil {
+		t.Fatalf("SaveRun() failed: %v", err)
+	}
+	if event, ok := receive(); ok {
+		t.Errorf("SaveRun() without a new best score published %+v, want nothing", event)
+	}
+
+	value := "1"
+	if _, err := store.SaveFeatureFlags([]FeatureFlagChange{
+		{Key: envVarPrefix + "A", Scope: flagScopePlayer, Subject: "b", Value: &value, ChangedBy: "test"},
+	}); err != nil {
+		t.Fatalf("SaveFeatureFlags() failed: %v", err)
+	}
+
+	event, ok = receive()
+	if !ok || event.Type != eventTypeFlags || event.visible == nil {
+		t.Fatalf("SaveFeatureFlags() published %+v, want flags event", event)
+	}
+
+	for _, tt := range []struct {
+		name            string
+		player, session string
+		want            bool
+	}{
+		{"test#1", "b", "s", true},
+		{"test#2", "a", "s", false},
+		{"test#3", "", "b", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := event.visible(tt.player, tt.session); got != tt.want {
+				t.Errorf("visible(%q, %q) = %t, want %t", tt.player, tt.session, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	keys := newTestKeySet(t, "test")
+	authenticator := AuthenticatorMiddleware(keys, map[string]string{"header": "Authorization"})
+	broker := newEventBroker(zap.NewNop())
+
+	// The subject of the test tokens has claimed the name p.
+	store := newMemoryStore()
+	if err := store.SavePlayer(Player{ID: "test", Name: "p", RecoveryHash: hashRecoveryCode("test")}); err != nil {
+		t.Fatalf("SavePlayer() failed: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(SessionMiddleware(keys, "session", time.Hour))
+	router.GET("/api/v1/events", authenticator, RequireScopesMiddleware(ScopePlayer), StreamEvents(broker, store))
+	router.POST("/api/v1/admin/notices", authenticator, RequireScopesMiddleware(ScopeAdmin), PostNotice(broker))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer broker.Close()
+
+	newClient := func(scope string) *client.Client {
+		c, err := client.New(server.URL, client.WithToken(newTestToken(t, keys, scope)))
+		if err != nil {
+			t.Fatalf("client.New() failed: %v", err)
+		}
+		return c
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan client.Event, 2)
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- newClient(ScopePlayer).StreamEvents(ctx, func(event client.Event) error {
+			received <- event
+			return nil
+		})
+	}()
+
+	for broker.Subscribers() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Only the flags of the player of the subscriber are streamed
+	broker.Publish(serverEvent{Type: eventTypeFlags, Data: gin.H{"keys": []string{"A"}}, visible: func(player, _ string) bool { return player == "q" }})
+	broker.Publish(serverEvent{Type: eventTypeFlags, Data: gin.H{"keys": []string{"B"}}, visible: func(player, _ string) bool { return player == "p" }})
+
+	if _, err := newClient(ScopePlayer).PostNotice(ctx, "hello"); client.StatusCode(err) != http.StatusForbidden {
+		t.Errorf("PostNotice() with player scope = %v, want status %d", err, http.StatusForbidden)
+	}
+
+	subscribers, err := newClient(ScopeAdmin).PostNotice(ctx, "hello")
+	if err != nil || subscribers != 1 {
+		t.Errorf("PostNotice() = (%d, %v), want 1 subscriber", subscribers, err)
+	}
+
+	event := <-received
+	if event.Type != client.EventFlags || event.ID != "2" || !bytes.Contains(event.Data, []byte(`"B"`)) {
+		t.Errorf("first event = %+v, want flags event #2", event)
+	}
+
+	event = <-received
+	var notice client.Notice
+	if event.Type != client.EventNotice || event.Decode(&notice) != nil || notice.Message != "hello" {
+		t.Errorf("second event = %+v, want notice", event)
+	}
+
+	broker.Close()
+	if err := <-streamed; err != nil {
+		t.Errorf("StreamEvents() after Close() = %v, want nil", err)
+	}
+
+	unauthenticated, _ := client.New(server.URL)
+	err = unauthenticated.StreamEvents(ctx, func(client.Event) error { return errors.New("unexpected event") })
+	if client.StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("StreamEvents() without token = %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buffer strings.Builder
+	if err := writeEvent(&buffer, serverEvent{ID: 7, Type: eventTypeNotice, Data: gin.H{"message": "a"}}); err != nil {
+		t.Fatalf("writeEvent() failed: %v", err)
+	}
+
+	if want := "id: 7\nevent: notice\ndata: {\"message\":\"a\"}\n\n"; buffer.String() != want {
+		t.Errorf("writeEvent() wrote %q, want %q", buffer.String(), want)
+	}
+}
@@ -0,0 +1,939 @@
+package server
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	dist "github.com/sarumaj/edu-space-invaders/dist"
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+	config "github.com/sarumaj/edu-space-invaders/src/pkg/config"
+	zap "go.uber.org/zap"
+	zapcore "go.uber.org/zap/zapcore"
+)
+
+// ClaimName reserves the name given in the request body for the player of the session.
+// The player is identified by the subject of the session token, hence each session claims a single name.
+// It returns the identity of the player along with its recovery code as a response, the code is shown only once.
+func ClaimName(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var claim PlayerClaim
+		if err := ctx.ShouldBindJSON(&claim); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := claim.Validate(); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		subject := getSubject(ctx)
+		switch player, err := store.FindPlayer(PlayerQuery{ID: subject}); {
+		case err == nil:
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("session has claimed a name already: %s", player.Name)})
+			return
+
+		case !errors.Is(err, ErrNotFound):
+			getLogger(ctx).Error("Failed to find player", zap.String("subject", subject), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		code, err := newRecoveryCode()
+		if err != nil {
+			getLogger(ctx).Error("Failed to generate recovery code", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery code"})
+			return
+		}
+
+		player := Player{ID: subject, Name: claim.Name, RecoveryHash: hashRecoveryCode(code)}
+		switch err := store.SavePlayer(player); {
+		case errors.Is(err, ErrConflict):
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("name claimed by another player: %s", claim.Name)})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to save player", zap.String("subject", subject), zap.String("name", claim.Name), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		getLogger(ctx).Info("Name claimed", zap.String("subject", subject), zap.String("name", claim.Name))
+		ctx.JSON(http.StatusCreated, PlayerCredentials{ID: player.ID, Name: player.Name, RecoveryCode: code})
+	}
+}
+
+// ExportTable streams the rows of the table given by the path parameter table (metrics or scores) as a response.
+// The query parameter format selects CSV, JSON (default) or NDJSON. The rows are read from the store in batches,
+// so that large tables are not held in memory. A failure after the first rows have been written truncates the response.
+func ExportTable(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		table := ctx.Param("table")
+		format, err := transferFormat(ctx.Query("format"), "")
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The encoder is created before anything is written, so that its failure is reported by the status code.
+		var encoder interface{ Close() error }
+		var export func() error
+		switch table {
+		case "metrics":
+			metrics, encoderErr := RecordEncoder(ctx.Writer, format, metricColumns, metricRow)
+			encoder, export, err = metrics, func() error { return store.ExportMetrics(exportBatchSize, metrics.Encode) }, encoderErr
+
+		case "scores":
+			scores, encoderErr := RecordEncoder(ctx.Writer, format, scoreColumns, scoreRow)
+			encoder, export, err = scores, func() error { return store.ExportScores(exportBatchSize, scores.Encode) }, encoderErr
+
+		default:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown table: %s", table)})
+			return
+
+		}
+
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The export outlives the write timeout of the server.
+		if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Now().Add(exportTimeout)); err != nil {
+			getLogger(ctx).Debug("Failed to extend the write deadline of the export", zap.Error(err))
+		}
+
+		ctx.Header("Content-Type", transferFormats[format])
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, table, format))
+
+		err = export()
+		if err == nil {
+			err = encoder.Close()
+		}
+
+		switch {
+		case err != nil && !ctx.Writer.Written():
+			getLogger(ctx).Error("Failed to export table", zap.String("table", table), zap.Error(err))
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		case err != nil:
+			getLogger(ctx).Error("Export truncated", zap.String("table", table), zap.Error(err))
+
+		}
+	}
+}
+
+// GetAuditEvents returns the events of the audit log as a response, the newest first.
+// The events are filtered by the query parameters actor, action, since and until (RFC 3339),
+// limit gives the number of events (100 by default, at most 1000).
+func GetAuditEvents(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query AuditQuery
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		events, err := store.GetAuditEvents(query)
+		if err != nil {
+			getLogger(ctx).Error("Failed to get audit events", zap.Any("query", query), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"events": events})
+	}
+}
+
+// GetConfig returns the configuration as a response.
+func GetConfig() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.TOML(http.StatusOK, config.Config)
+	}
+}
+
+// GetLeaderboard returns a page of the leaderboard as a response.
+// The page is selected by the query parameters limit, offset, period and around.
+// If the request is authenticated, the best rank of the caller's session is returned as well.
+func GetLeaderboard(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query LeaderboardQuery
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query.Subject = getSubject(ctx)
+		board, err := store.GetLeaderboard(query)
+		if err != nil {
+			getLogger(ctx).Error("Failed to get leaderboard", zap.Any("query", query), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		getLogger(ctx).Debug("Leaderboard retrieved", zap.Any("query", query), zap.Int64("total", board.Total))
+		ctx.JSON(http.StatusOK, board)
+	}
+}
+
+// GetOpenAPI returns the description of the API as a response.
+func GetOpenAPI() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/yaml; charset=utf-8", client.OpenAPI)
+	}
+}
+
+// GetPlayer returns the profile of the player as a response.
+// The player is identified by the path parameter name.
+// It returns the best score, the rank, the run statistics and the most recent runs of the player.
+func GetPlayer(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ctx.Param("name")
+		profile, err := store.GetPlayerProfile(name, 10)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("player not found: %s", name)})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to get player profile", zap.String("name", name), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		getLogger(ctx).Debug("Player profile retrieved", zap.String("name", name))
+		ctx.JSON(http.StatusOK, profile)
+	}
+}
+
+// GetRetention returns the configuration of the retention scheduler and the reports of its most recent runs as a response.
+func GetRetention(scheduler *RetentionScheduler) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		config := scheduler.Config()
+		ctx.JSON(http.StatusOK, gin.H{
+			"config": gin.H{
+				"dry_run":            config.DryRun,
+				"interval":           config.Interval.String(),
+				"keep_scores":        config.KeepScores,
+				"max_size":           config.MaxSize,
+				"metric_age":         config.MetricAge.String(),
+				"unverified_run_age": config.UnverifiedRunAge.String(),
+			},
+			"reports": scheduler.Reports(),
+		})
+	}
+}
+
+// GetScores returns the scores as a response.
+// It returns the scores in descending order of the score.
+// If the scores have the same score, they are ordered in ascending order of the name.
+func GetScores(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scores, err := store.GetScores()
+		if err != nil {
+			getLogger(ctx).Error("Failed to get scores", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		getLogger(ctx).Debug("Scores retrieved", zap.Any("scores", scores))
+		ctx.SecureJSON(http.StatusOK, scores)
+	}
+}
+
+// GetSessionPlayer returns the player of the session as a response.
+// It returns the status not found if the session has not claimed a name yet.
+func GetSessionPlayer(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		subject := getSubject(ctx)
+		player, err := store.FindPlayer(PlayerQuery{ID: subject})
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "session has not claimed a name"})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to find player", zap.String("subject", subject), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		ctx.JSON(http.StatusOK, player)
+	}
+}
+
+// GetFlagHistory returns the most recent changes of the feature flags as a response.
+// The query parameter key selects the changes of a single flag, limit the number of changes (100 by default, at most 1000).
+func GetFlagHistory(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			Key   string `form:"key"`
+			Limit int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes, err := store.GetFeatureFlagHistory(query.Key, selectValue(query.Limit, 100))
+		if err != nil {
+			getLogger(ctx).Error("Failed to get feature flag history", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"changes": changes})
+	}
+}
+
+// HandleEnv handles the feature flags of the game, i.e. the environment variables prefixed with SPACE_INVADERS_.
+// It sets the flags given in the request body, a null value unsets a flag.
+// The flags are set globally or, given by the query parameters scope and subject, for a player or a session.
+// Every change is recorded along with the subject of the caller.
+// It returns the effective flags as a response: the given environment variables (KEY=VALUE) with the prefix,
+// overridden by the global flags, the flags of the player and the flags of the session.
+// The player and the session are those of the caller, unless a caller granted the config:read scope
+// names another player by the query parameter player.
+func HandleEnv(store Store, environ []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var player, session string
+		if ctx.Request.Method == http.MethodPost {
+			body := make(gin.H, 0)
+			if err := ctx.ShouldBindJSON(&body); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Communication from WASM to Go is done through the request body.
+			// Keys without the prefix, e.g. _size and _prefix of a previous response, are ignored.
+			scope, subject := ctx.DefaultQuery("scope", flagScopeGlobal), ctx.Query("subject")
+			var changes []FeatureFlagChange
+			for k, v := range body {
+				if !strings.HasPrefix(k, envVarPrefix) {
+					continue
+				}
+
+				change := FeatureFlagChange{Key: k, Scope: scope, Subject: subject, ChangedBy: getSubject(ctx)}
+				if v != nil {
+					value := fmt.Sprintf("%v", v)
+					change.Value = &value
+				}
+
+				if err := change.Validate(); err != nil {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+
+				changes = append(changes, change)
+			}
+
+			if len(changes) == 0 {
+				getLogger(ctx).Debug("No feature flags updated")
+			} else {
+				recorded, err := store.SaveFeatureFlags(changes)
+				if err != nil {
+					getLogger(ctx).Error("Failed to save feature flags", zap.Error(err))
+					ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				getLogger(ctx).Info("Feature flags updated", zap.Any("changes", recorded))
+
+				before, after := make(map[string]*string, len(recorded)), make(map[string]*string, len(recorded))
+				for _, change := range recorded {
+					before[change.Key], after[change.Key] = change.Previous, change.Value
+				}
+				setAudit(ctx, strings.TrimSuffix(scope+":"+subject, ":"), before, after)
+			}
+
+			// Respond with the effective flags of the target of the changes.
+			switch scope {
+			case flagScopePlayer:
+				player = subject
+			case flagScopeSession:
+				session = subject
+			}
+		} else if claims := getClaims(ctx); ctx.Query("player") != "" && claims != nil && claims.HasScope(ScopeConfigRead) {
+			player = ctx.Query("player")
+		} else {
+			name, err := getPlayerName(ctx, store)
+			if err != nil {
+				getLogger(ctx).Error("Failed to find player", zap.Error(err))
+				ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("database unavailable: %s", err)})
+				return
+			}
+			player, session = name, getSubject(ctx)
+		}
+
+		// Communication from Go to WASM is done through the response body.
+		flags, err := store.GetFeatureFlags(player, session)
+		if err != nil {
+			getLogger(ctx).Error("Failed to get feature flags", zap.Error(err))
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("database unavailable: %s", err)})
+			return
+		}
+
+		body := make(gin.H, 0)
+		for _, pair := range environ {
+			k, v, _ := strings.Cut(pair, "=")
+			if strings.HasPrefix(k, envVarPrefix) {
+				body[k] = v
+			}
+		}
+
+		// The flags of the session override those of the player, which override the global flags.
+		precedence := map[string]int{flagScopeGlobal: 0, flagScopePlayer: 1, flagScopeSession: 2}
+		slices.SortStableFunc(flags, func(a, b FeatureFlag) int { return cmp.Compare(precedence[a.Scope], precedence[b.Scope]) })
+		for _, flag := range flags {
+			body[flag.Key] = flag.Value
+		}
+
+		body["_size"] = len(body)
+		body["_prefix"] = envVarPrefix
+		ctx.JSON(http.StatusOK, body)
+	}
+}
+
+// HandleHealth handles the health check.
+// It returns the boot time, build time, current time, status, and uptime as a response.
+// It also returns the database size, database utilization, and table sizes as metrics.
+// It is read-only, the size limit of the database is enforced in the background (see RetentionScheduler).
+func HandleHealth(store Store) gin.HandlerFunc {
+	bootTime := time.Now()
+
+	return func(ctx *gin.Context) {
+		metrics, err := store.GetMetrics()
+		if err != nil {
+			getLogger(ctx).Error("Failed to get metrics", zap.Error(err))
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("database unavailable: %s", err)})
+			return
+		}
+
+		metricsObject := make(map[string]any)
+		for _, metric := range metrics {
+			metricsObject[metric.Method+" "+metric.Endpoint] = metric.Count
+		}
+
+		size, err := store.GetDatabaseSize()
+		if err != nil {
+			getLogger(ctx).Error("Failed to get database size", zap.Error(err))
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("database unavailable: %s", err)})
+			return
+		}
+
+		metricsObject["STATS /database/limit"] = Size(maximumSize).String()
+		metricsObject["STATS /database/size"] = size.String()
+		metricsObject["STATS /database/utilization"] = fmt.Sprintf("%.2f%%", float64(size)/float64(maximumSize)*100)
+
+		tables, err := store.GetTableSizes()
+		if err != nil {
+			getLogger(ctx).Error("Failed to get table sizes", zap.Error(err))
+		}
+
+		for table, tableSize := range tables {
+			metricsObject["STATS /database/tables/"+table+"/size"] = tableSize.String()
+			metricsObject["STATS /database/tables/"+table+"/utilization"] = fmt.Sprintf("%.2f%%", float64(tableSize)/float64(maximumSize)*100)
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"BootTime":  bootTime.Format(time.RFC3339),
+			"BuildTime": dist.BuildTime(),
+			"Current":   time.Now().Format(time.RFC3339),
+			"Metrics":   metricsObject,
+			"Status":    "ok",
+			"UpTime":    time.Since(bootTime).String(),
+		})
+	}
+}
+
+// ImportScores merges the scores given by the body with the existing ones and returns the report as a response.
+// The query parameter format selects CSV, JSON or NDJSON, otherwise the format is given by the content type (JSON by default).
+// The query parameter mode selects keep-higher (default), overwrite or skip-existing; dry_run reports the changes without saving them.
+// The import is atomic: either all scores are saved or none.
+func ImportScores(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			Format string     `form:"format"`
+			Mode   ImportMode `form:"mode"`
+			DryRun bool       `form:"dry_run"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query.Mode = selectValue(query.Mode, ImportKeepHigher)
+		format, err := transferFormat(query.Format, ctx.ContentType())
+		if err == nil {
+			err = query.Mode.Validate()
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		scores, err := decodeScores(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, importMaxBytes), format)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+
+		case err != nil:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		if err := validateScores(scores); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := store.ImportScores(scores, query.Mode, query.DryRun)
+		if err != nil {
+			getLogger(ctx).Error("Failed to import scores", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		setAudit(ctx, "scores", nil, report)
+		getLogger(ctx).Info("Scores imported", zap.Any("report", report))
+		ctx.JSON(http.StatusOK, report)
+	}
+}
+
+// IssueRecoveryCode replaces the recovery code of the player given by the path parameter name
+// and returns the new code as a response, e.g. for a player who has lost it or whose name has been reserved for its scores.
+// The code is not recorded in the audit log.
+func IssueRecoveryCode(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ctx.Param("name")
+		player, err := store.FindPlayer(PlayerQuery{Name: name})
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("player not found: %s", name)})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to find player", zap.String("name", name), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		setAudit(ctx, "player:"+name, nil, player)
+		credentials, err := renewRecoveryCode(store, player)
+		if err != nil {
+			getLogger(ctx).Error("Failed to renew recovery code", zap.String("name", name), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		getLogger(ctx).Info("Recovery code issued", zap.String("name", name))
+		ctx.JSON(http.StatusOK, credentials)
+	}
+}
+
+// PostNotice publishes the notice given in the request body to all subscribers of the event stream.
+func PostNotice(broker *EventBroker) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var notice struct {
+			Message string `json:"message" binding:"required,max=500"`
+		}
+		if err := ctx.ShouldBindJSON(&notice); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		broker.Publish(serverEvent{Type: eventTypeNotice, Data: notice})
+		setAudit(ctx, "notice", nil, notice)
+		ctx.JSON(http.StatusOK, gin.H{"subscribers": broker.Subscribers()})
+	}
+}
+
+// RecoverPlayer restores the identity of the player of the recovery code given in the request body,
+// e.g. on another device: the session cookie is replaced by a session of the player.
+// It returns the player as a response.
+func RecoverPlayer(store Store, keys *KeySet, sessionName string, sessionDuration time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var recovery PlayerRecovery
+		if err := ctx.ShouldBindJSON(&recovery); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		player, err := store.FindPlayer(PlayerQuery{RecoveryHash: hashRecoveryCode(recovery.RecoveryCode)})
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "unknown recovery code"})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to find player", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		if err := setSessionCookie(ctx, keys, sessionName, player.ID, sessionDuration); err != nil {
+			getLogger(ctx).Error("Failed to issue session", zap.String("name", player.Name), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue session"})
+			return
+		}
+
+		getLogger(ctx).Info("Player recovered", zap.String("name", player.Name))
+		ctx.JSON(http.StatusOK, player)
+	}
+}
+
+// RenewRecoveryCode replaces the recovery code of the player of the session and returns the new code as a response.
+// The previous code is void afterwards.
+func RenewRecoveryCode(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		subject := getSubject(ctx)
+		player, err := store.FindPlayer(PlayerQuery{ID: subject})
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "session has not claimed a name"})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to find player", zap.String("subject", subject), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		credentials, err := renewRecoveryCode(store, player)
+		if err != nil {
+			getLogger(ctx).Error("Failed to renew recovery code", zap.String("subject", subject), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, credentials)
+	}
+}
+
+// RunRetention applies the retention policy immediately and returns the report as a response.
+// The query parameter dry_run reports the rows to be deleted without deleting them.
+func RunRetention(scheduler *RetentionScheduler) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			DryRun bool `form:"dry_run"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := scheduler.Run(query.DryRun)
+		setAudit(ctx, "retention", nil, report)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, report)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, report)
+	}
+}
+
+// ServeFileSystem serves the files from the embedded file system.
+// The versioned assets are served under their content-addressed URL, other paths to them are redirected to it.
+// Paths matching any of the conflicting patterns are served by the associated handlers instead.
+// Named subexpressions of the matching pattern are available to the handlers as path parameters.
+// The route template of the matching pattern is stored in the context under the key "route".
+func ServeFileSystem(conflicting map[*regexp.Regexp]gin.HandlersChain) gin.HandlerFunc {
+	routes := make(map[*regexp.Regexp]string, len(conflicting))
+	for pattern := range conflicting {
+		routes[pattern] = routeTemplate(pattern)
+	}
+
+	return func(ctx *gin.Context) {
+		path := ctx.Param("filepath")
+		for pattern, handlers := range conflicting {
+			if match := pattern.FindStringSubmatch(path); match != nil && len(handlers) > 0 {
+				getLogger(ctx).Debug("Matched conflicting path", zap.String("path", path))
+				ctx.Set("route", routes[pattern])
+				for i, name := range pattern.SubexpNames() {
+					if name != "" {
+						ctx.AddParam(name, match[i])
+					}
+				}
+
+				// Execute the middleware handlers.
+				for _, handler := range handlers[:len(handlers)-1] {
+					if handler(ctx); ctx.IsAborted() {
+						return
+					}
+				}
+				// Execute the last handler.
+				handlers.Last()(ctx)
+				return
+			}
+		}
+
+		// Redirect to the content-addressed URL of the current version of the asset,
+		// unless requested by it, e.g. by the old path or by the URL of a previous version.
+		name := assetName(path)
+		if location, ok := assetURL(name); ok {
+			if !isImmutable(path) {
+				getLogger(ctx).Debug("Redirecting to the content-addressed asset", zap.String("path", path), zap.String("location", location))
+				ctx.Redirect(http.StatusFound, location)
+				return
+			}
+
+			path = name
+		}
+
+		// Serve the precompressed variant of the asset in the encoding preferred by the client, if any.
+		if hasVariants(name, assetExists) && !assetPath.MatchString(path) {
+			ctx.Header("Vary", "Accept-Encoding")
+			if variant, encoding := selectVariant(ctx.GetHeader("Accept-Encoding"), name, assetExists); encoding != "" {
+				getLogger(ctx).Debug("Serving precompressed file", zap.String("path", path), zap.String("encoding", encoding))
+				ctx.Header("Content-Encoding", encoding)
+				ctx.Header("Content-Type", selectValue(mime.TypeByExtension(filepath.Ext(name)), "application/octet-stream"))
+				ctx.FileFromFS("/"+variant, dist.HttpFS)
+				return
+			}
+		}
+
+		getLogger(ctx).Debug("Serving file", zap.String("path", path))
+		ctx.FileFromFS("/"+strings.TrimLeft(path, "/"), dist.HttpFS)
+	}
+}
+
+// StreamEvents streams the events concerning the caller as server-sent events, until the client disconnects
+// or the server shuts down. The player and the session are those of the caller.
+// Idle streams are kept open by comments sent every eventKeepAlive.
+func StreamEvents(broker *EventBroker, store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		player, err := getPlayerName(ctx, store)
+		if err != nil {
+			getLogger(ctx).Error("Failed to find player", zap.Error(err))
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("database unavailable: %s", err)})
+			return
+		}
+
+		session := getSubject(ctx)
+		events, unsubscribe, err := broker.Subscribe()
+		if err != nil {
+			ctx.Header("Retry-After", strconv.Itoa(int(eventRetry.Seconds())))
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		defer unsubscribe()
+
+		// The stream outlives the write timeout of the server.
+		if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			getLogger(ctx).Debug("Failed to clear the write deadline of the event stream", zap.Error(err))
+		}
+
+		ctx.Header("Content-Type", "text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("X-Accel-Buffering", "no")
+		ctx.Status(http.StatusOK)
+		_, _ = fmt.Fprintf(ctx.Writer, "retry: %d\n\n", eventRetry.Milliseconds())
+		ctx.Writer.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-ctx.Request.Context().Done():
+				return
+
+			case <-keepAlive.C:
+				_, _ = io.WriteString(ctx.Writer, ": keep-alive\n\n")
+
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				if event.visible != nil && !event.visible(player, session) {
+					continue
+				}
+
+				if err := writeEvent(ctx.Writer, event); err != nil {
+					getLogger(ctx).Error("Failed to write event", zap.String("type", event.Type), zap.Error(err))
+					return
+				}
+
+			}
+
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// SubmitScore saves the result of a single game run.
+// The level of the run is verified by replaying the run, see ScoreSubmission.Verify,
+// by the replayer, at most the given number of runs at once, further submissions wait for a replay to finish.
+// The name of the submission must be the name claimed by the session, see ClaimName.
+// A verified run is recorded in the history of the player and tied to the subject of the session token.
+// An unverified run is kept apart from the leaderboard, flagged by the reason of the failed verification,
+// and acknowledged with the status accepted.
+// It returns the best score of the player and its rank on the leaderboard.
+func SubmitScore(store Store, replay Replayer, replayLimit time.Duration, replays int) gin.HandlerFunc {
+	slots := make(chan struct{}, max(replays, 1))
+
+	return func(ctx *gin.Context) {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maximumSubmissionSize)
+
+		var submission ScoreSubmission
+		if err := ctx.ShouldBindJSON(&submission); err != nil {
+			status := http.StatusBadRequest
+			if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := submission.Validate(); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The replay is not logged, since it may consist of many thousands of inputs.
+		logged := submission
+		logged.Replay = nil
+
+		subject := getSubject(ctx)
+		fields := []zapcore.Field{zap.Any("submission", logged), zap.String("subject", subject)}
+		player, err := store.FindPlayer(PlayerQuery{ID: subject})
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "session has not claimed a name"})
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to find player", append(fields, zap.Error(err))...)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		case player.Name != submission.Name:
+			ctx.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("session has claimed another name: %s", player.Name)})
+			return
+
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Request.Context().Done():
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "the run has not been replayed in time"})
+			return
+		}
+
+		flag, reason, err := submission.Verify(ctx.Request.Context(), replay, replayLimit)
+		<-slots
+		if err != nil {
+			getLogger(ctx).Error("Failed to replay run", append(fields, zap.Error(err))...)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if flag == "" {
+			if err := store.SaveRun(submission.Run(subject)); err != nil {
+				getLogger(ctx).Error("Failed to save score", append(fields, zap.Error(err))...)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			fields = append(fields, zap.String("flag", string(flag)), zap.String("reason", reason))
+			if err := store.SaveUnverifiedRun(submission.UnverifiedRun(subject, flag, reason)); err != nil {
+				getLogger(ctx).Error("Failed to save unverified run", append(fields, zap.Error(err))...)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		result := ScoreResult{Name: submission.Name, Verified: flag == ""}
+		best, err := store.GetScore(submission.Name)
+		switch {
+		case errors.Is(err, ErrNotFound) && !result.Verified: // The player of an unverified run may have no score yet.
+			getLogger(ctx).Warn("Score not verified", fields...)
+			ctx.JSON(http.StatusAccepted, result)
+			return
+
+		case err != nil:
+			getLogger(ctx).Error("Failed to get score", append(fields, zap.Error(err))...)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+
+		}
+
+		rank, err := store.GetRank(submission.Name)
+		if err != nil {
+			getLogger(ctx).Error("Failed to get rank", append(fields, zap.Error(err))...)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		result.Score, result.Rank = best.Score, rank
+		if !result.Verified {
+			getLogger(ctx).Warn("Score not verified", append(fields, zap.Int64("rank", rank))...)
+			ctx.JSON(http.StatusAccepted, result)
+			return
+		}
+
+		getLogger(ctx).Debug("Score submitted", append(fields, zap.Int64("rank", rank))...)
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// SaveScores saves the scores to the database.
+func SaveScores(store Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var scores []Score
+		if err := ctx.ShouldBind(&scores); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The current scores of the players are recorded in the audit log along with the submitted ones.
+		before := make([]Score, 0, len(scores))
+		for _, score := range scores {
+			switch current, err := store.GetScore(score.Name); {
+			case err == nil:
+				before = append(before, current)
+
+			case !errors.Is(err, ErrNotFound):
+				getLogger(ctx).Error("Failed to get score", zap.String("name", score.Name), zap.Error(err))
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+
+			}
+		}
+
+		setAudit(ctx, "scores", before, scores)
+		if err := store.SaveScores(scores); err != nil {
+			getLogger(ctx).Error("Failed to save scores", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		getLogger(ctx).Debug("Scores saved", zap.Any("scores", scores))
+		ctx.Status(http.StatusOK)
+	}
+}
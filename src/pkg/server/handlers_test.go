@@ -0,0 +1,492 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+	config "github.com/sarumaj/edu-space-invaders/src/pkg/config"
+	handler "github.com/sarumaj/edu-space-invaders/src/pkg/handler"
+)
+
+// replayMutex serializes the replays of replayInProcess, since the game state is global to the process.
+var replayMutex sync.Mutex
+
+// replayInProcess replays the game runs in the test process one at a time, see handler.Replay.
+func replayInProcess(ctx context.Context, replay config.Replay) (int, error) {
+	replayMutex.Lock()
+	defer replayMutex.Unlock()
+
+	highScore, err := handler.Replay(replay)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidReplay, err)
+	}
+
+	return highScore, nil
+}
+
+// newTestReplay returns the record of a game run, in which the spaceship fires from the start until it is destroyed,
+// and the level the spaceship reaches.
+func newTestReplay(t *testing.T) (config.Replay, int64) {
+	t.Helper()
+
+	replay := config.Replay{Seed: 2, Frames: 600, Inputs: []config.Input{
+		{Frame: 0, Device: config.InputDeviceKeyboard, Key: "Space", Pressed: true},
+		{Frame: 1, Device: config.InputDeviceKeyboard, Key: "Space", Pressed: true},
+	}, Flags: []config.GameFlags{
+		{Frame: 0, CollisionDetectionVersion: 3, PlanetChoice: -1},
+	}}
+	level, err := handler.Replay(replay)
+	if err != nil {
+		t.Fatalf("Replay() failed: %v", err)
+	}
+
+	return replay, int64(level)
+}
+
+func newTestRouter(store Store) *gin.Engine {
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		// The subject admin is granted the admin scope, any other subject the player scope.
+		if subject := ctx.GetHeader("X-Subject"); subject != "" {
+			ctx.Set("claims", &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Scope: selectValue(map[string]string{"admin": ScopeAdmin}[subject], ScopePlayer)})
+		}
+	})
+
+	router.POST("/.env", HandleEnv(store, nil))
+	router.POST("/scores", SubmitScore(store, replayInProcess, time.Minute, 1))
+	router.POST("/api/v1/session/player", ClaimName(store))
+	router.POST("/api/v1/session/player/recovery-code", RenewRecoveryCode(store))
+	router.POST("/api/v1/admin/players/:name/recovery-code", IssueRecoveryCode(store))
+	router.Match([]string{http.MethodHead, http.MethodGet}, "/*filepath", ServeFileSystem(map[*regexp.Regexp]gin.HandlersChain{
+		regexp.MustCompile(`^/?\.env/?$`):                          {HandleEnv(store, nil)},
+		regexp.MustCompile(`^/?api/v1/flags/history/?$`):           {GetFlagHistory(store)},
+		regexp.MustCompile(`^/?api/v1/leaderboard/?$`):             {GetLeaderboard(store)},
+		regexp.MustCompile(`^/?api/v1/players/(?P<name>[^/]+)/?$`): {GetPlayer(store)},
+		regexp.MustCompile(`^/?api/v1/session/player/?$`):          {GetSessionPlayer(store)},
+	}))
+
+	return router
+}
+
+func serve(router http.Handler, method, target, subject, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if subject != "" {
+		req.Header.Set("X-Subject", subject)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSubmitScore(t *testing.T) {
+	store := newMemoryStore()
+	router := newTestRouter(store)
+
+	replay, level := newTestReplay(t)
+	raw, err := json.Marshal(replay)
+	if err != nil {
+		t.Fatalf("failed to encode replay: %v", err)
+	}
+
+	// The replays of too many inputs and of too large a body are rejected before they are replayed.
+	inputs := strings.Repeat(`{"f":0,"d":"k","k":"Space"},`, maximumReplayInputs)
+	tooManyInputs := fmt.Sprintf(`{"name":"a","level":0,"duration":1000000000,"replay":{"seed":1,"frames":60,"inputs":[%s{"f":0,"d":"k","k":"Space"}]}}`, inputs)
+	tooLarge := fmt.Sprintf(`{"name":"a","level":0,"duration":1000000000,"cause_of_death":"%s"}`, strings.Repeat("x", maximumSubmissionSize))
+
+	// The sessions s1, s2 and s3 have claimed the names a, b and c, the session s4 has claimed no name.
+	for subject, name := range map[string]string{"s1": "a", "s2": "b", "s3": "c"} {
+		if err := store.SavePlayer(Player{ID: subject, Name: name, RecoveryHash: hashRecoveryCode(subject)}); err != nil {
+			t.Fatalf("SavePlayer(%s) failed: %v", name, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		subject  string
+		args     string
+		want     int
+		wantRank int64
+		wantFlag RunFlag
+	}{
+		{"test#1", "s1", `{`, http.StatusBadRequest, 0, ""},
+		{"test#2", "s1", `{"name":"a","level":-1,"duration":1000000000}`, http.StatusUnprocessableEntity, 0, ""},
+		{"test#3", "s1", fmt.Sprintf(`{"name":"a","level":%d,"duration":1000000000,"discovered_planets":["Neptune"],"replay":%s}`, level, raw), http.StatusOK, 1, ""},
+		{"test#4", "s2", `{"name":"b","level":0,"duration":1000000000,"replay":{"seed":1,"frames":0,"inputs":[],"flags":[{"f":0,"cdv":3,"pc":-1}]}}`, http.StatusOK, 2, ""},
+		{"test#5", "s2", `{"name":"b","level":4,"duration":1000000000}`, http.StatusAccepted, 2, RunFlagMissingReplay},
+		{"test#6", "s3", fmt.Sprintf(`{"name":"c","level":%d,"duration":1000000000,"replay":%s}`, level+1, raw), http.StatusAccepted, 0, RunFlagScoreMismatch},
+		{"test#7", "s3", `{"name":"c","level":0,"duration":1000000000,"replay":{"seed":1,"frames":3601,"inputs":[]}}`, http.StatusAccepted, 0, RunFlagReplayTooLong},
+		{"test#8", "s3", `{"name":"c","level":0,"duration":1000000000,"replay":{"seed":1,"frames":60,"inputs":[{"f":0,"d":"k","k":"Escape"}]}}`, http.StatusAccepted, 0, RunFlagInvalidReplay},
+		{"test#9", "s1", `{"name":"b","level":0,"duration":1000000000,"replay":{"seed":1,"frames":0,"inputs":[]}}`, http.StatusForbidden, 0, ""},
+		{"test#10", "s4", `{"name":"d","level":0,"duration":1000000000,"replay":{"seed":1,"frames":0,"inputs":[]}}`, http.StatusForbidden, 0, ""},
+		{"test#11", "s3", `{"name":"c","level":0,"duration":1000000000,"replay":{"seed":1,"frames":60,"inputs":[],"flags":[{"f":0,"cdv":3,"pc":-1},{"f":30,"cdv":3,"gm":true,"pc":-1}]}}`, http.StatusAccepted, 0, RunFlagUnfairFlags},
+		{"test#12", "s1", tooManyInputs, http.StatusUnprocessableEntity, 0, ""},
+		{"test#13", "s1", tooLarge, http.StatusRequestEntityTooLarge, 0, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodPost, "/scores", tt.subject, tt.args)
+			if rec.Code != tt.want {
+				t.Fatalf("POST /scores = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if tt.want != http.StatusOK && tt.want != http.StatusAccepted {
+				return
+			}
+
+			var got ScoreResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if got.Rank != tt.wantRank || got.Verified != (tt.wantFlag == "") {
+				t.Errorf("POST /scores = %+v, want rank %d and verified %t", got, tt.wantRank, tt.wantFlag == "")
+			}
+
+			if tt.wantFlag == "" {
+				return
+			}
+
+			if runs := store.unverified; len(runs) == 0 || runs[len(runs)-1].Flag != tt.wantFlag {
+				t.Errorf("POST /scores saved unverified runs %+v, want flag %q", runs, tt.wantFlag)
+			}
+		})
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	router := newTestRouter(newTestStore(t,
+		Score{Name: "a", Score: 3, Subject: "s1"},
+		Score{Name: "b", Score: 2, Subject: "s2"},
+		Score{Name: "c", Score: 1, Subject: "s3"},
+	))
+
+	for _, tt := range []struct {
+		name     string
+		args     string
+		subject  string
+		want     int
+		wantSelf string
+	}{
+		{"test#1", "/api/v1/leaderboard?limit=0", "", http.StatusOK, ""},
+		{"test#2", "/api/v1/leaderboard?limit=101", "", http.StatusBadRequest, ""},
+		{"test#3", "/api/v1/leaderboard?period=monthly", "", http.StatusBadRequest, ""},
+		{"test#4", "/api/v1/leaderboard?limit=1", "s2", http.StatusOK, "b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, tt.args, tt.subject, "")
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.args, rec.Code, tt.want, rec.Body.String())
+			}
+
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var got Leaderboard
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if tt.wantSelf != "" && (got.Self == nil || got.Self.Name != tt.wantSelf) {
+				t.Errorf("GET %s self = %v, want %q", tt.args, got.Self, tt.wantSelf)
+			}
+		})
+	}
+}
+
+func TestGetPlayer(t *testing.T) {
+	router := newTestRouter(newTestStore(t, Score{Name: "a", Score: 3}))
+
+	for _, tt := range []struct {
+		name string
+		args string
+		want int
+	}{
+		{"test#1", "/api/v1/players/a", http.StatusOK},
+		{"test#2", "/api/v1/players/b", http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(router, http.MethodGet, tt.args, "", ""); rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", tt.args, rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleEnv(t *testing.T) {
+	store := newMemoryStore()
+	router := newTestRouter(store)
+
+	// The session s3 has claimed the name a.
+	if err := store.SavePlayer(Player{ID: "s3", Name: "a", RecoveryHash: hashRecoveryCode("s3")}); err != nil {
+		t.Fatalf("SavePlayer() failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		method  string
+		target  string
+		subject string
+		body    string
+		want    int
+		wantEnv map[string]any
+	}{
+		{"test#1", http.MethodPost, "/.env", "admin", `{"SPACE_INVADERS_DEBUG":"true","SPACE_INVADERS_GOD_MODE":false,"_size":2}`, http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "true", "SPACE_INVADERS_GOD_MODE": "false"}},
+		{"test#2", http.MethodPost, "/.env?scope=player&subject=a", "admin", `{"SPACE_INVADERS_GOD_MODE":true}`, http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "true", "SPACE_INVADERS_GOD_MODE": "true"}},
+		{"test#3", http.MethodPost, "/.env?scope=session&subject=s1", "admin", `{"SPACE_INVADERS_DEBUG":"false"}`, http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "false", "SPACE_INVADERS_GOD_MODE": "false"}},
+		{"test#4", http.MethodPost, "/.env?scope=player", "admin", `{"SPACE_INVADERS_DEBUG":"false"}`, http.StatusBadRequest, nil},
+		{"test#5", http.MethodPost, "/.env?scope=team&subject=a", "admin", `{"SPACE_INVADERS_DEBUG":"false"}`, http.StatusBadRequest, nil},
+		{"test#6", http.MethodGet, "/.env", "s2", "", http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "true", "SPACE_INVADERS_GOD_MODE": "false"}},
+		{"test#7", http.MethodGet, "/.env?player=a", "s2", "", http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "true", "SPACE_INVADERS_GOD_MODE": "false"}},
+		{"test#8", http.MethodGet, "/.env?player=a", "s1", "", http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "false", "SPACE_INVADERS_GOD_MODE": "false"}},
+		{"test#9", http.MethodGet, "/.env", "s3", "", http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "true", "SPACE_INVADERS_GOD_MODE": "true"}},
+		{"test#10", http.MethodGet, "/.env?player=a", "admin", "", http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": "true", "SPACE_INVADERS_GOD_MODE": "true"}},
+		{"test#11", http.MethodPost, "/.env", "admin", `{"SPACE_INVADERS_DEBUG":null}`, http.StatusOK,
+			map[string]any{"SPACE_INVADERS_DEBUG": nil, "SPACE_INVADERS_GOD_MODE": "false"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, tt.method, tt.target, tt.subject, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.want, rec.Body.String())
+			}
+
+			if tt.wantEnv == nil {
+				return
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			for key, want := range tt.wantEnv {
+				if got[key] != want {
+					t.Errorf("%s %s: %s = %v, want %v", tt.method, tt.target, key, got[key], want)
+				}
+			}
+		})
+	}
+
+	rec := serve(router, http.MethodGet, "/api/v1/flags/history?key=SPACE_INVADERS_DEBUG&limit=2", "", "")
+	var history struct {
+		Changes []FeatureFlagChange `json:"changes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+
+	if changes := history.Changes; len(changes) != 2 ||
+		changes[0].Value != nil || changes[0].Previous == nil || *changes[0].Previous != "true" || changes[0].ChangedBy != "admin" ||
+		changes[1].Scope != flagScopeSession || changes[1].Subject != "s1" || changes[1].Previous != nil {
+		t.Errorf("GET /api/v1/flags/history = %s, want the unset global and the session override of SPACE_INVADERS_DEBUG", rec.Body.String())
+	}
+}
+
+func TestClaimName(t *testing.T) {
+	router := newTestRouter(newTestStore(t, Score{Name: "c", Score: 1}))
+
+	for _, tt := range []struct {
+		name    string
+		subject string
+		args    string
+		want    int
+	}{
+		{"test#1", "s1", `{`, http.StatusBadRequest},
+		{"test#2", "s1", `{"name":" "}`, http.StatusUnprocessableEntity},
+		{"test#3", "s1", `{"name":"abcd-efgh-ijkl-mnop-qrst-uvwx-yz23-4567"}`, http.StatusUnprocessableEntity},
+		{"test#4", "s1", `{"name":" a "}`, http.StatusCreated},
+		{"test#5", "s1", `{"name":"b"}`, http.StatusConflict},
+		{"test#6", "s2", `{"name":"a"}`, http.StatusConflict},
+		{"test#7", "s2", `{"name":"c"}`, http.StatusConflict},
+		{"test#8", "s2", `{"name":"b"}`, http.StatusCreated},
+		{"test#11", "s3", `{"name":"<img src=x onerror=alert(1)>"}`, http.StatusUnprocessableEntity},
+		{"test#12", "s3", `{"name":"-a"}`, http.StatusUnprocessableEntity},
+		{"test#13", "s4", `{"name":"Émile_O. de-la 2"}`, http.StatusCreated},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodPost, "/api/v1/session/player", tt.subject, tt.args)
+			if rec.Code != tt.want {
+				t.Fatalf("POST /api/v1/session/player = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if tt.want != http.StatusCreated {
+				return
+			}
+
+			var got PlayerCredentials
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if got.ID != tt.subject || !client.IsRecoveryCode(got.RecoveryCode) {
+				t.Errorf("POST /api/v1/session/player = %+v, want the player %s with a recovery code", got, tt.subject)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name     string
+		subject  string
+		want     int
+		wantName string
+	}{
+		{"test#9", "s1", http.StatusOK, "a"},
+		{"test#10", "s3", http.StatusNotFound, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, "/api/v1/session/player", tt.subject, "")
+			if rec.Code != tt.want {
+				t.Fatalf("GET /api/v1/session/player = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			var got Player
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if got.Name != tt.wantName || strings.Contains(rec.Body.String(), "recovery") {
+				t.Errorf("GET /api/v1/session/player = %s, want the player %q without its recovery code", rec.Body.String(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRecoverPlayer(t *testing.T) {
+	keys := newTestKeySet(t, "test")
+	store := newTestStore(t, Score{Name: "c", Score: 1})
+	router := newTestRouter(store)
+	router.POST("/api/v1/session/recover", RecoverPlayer(store, keys, "session", time.Hour))
+
+	credentials := func(method, target, subject, body string, want int) (got PlayerCredentials) {
+		t.Helper()
+
+		rec := serve(router, method, target, subject, body)
+		if rec.Code != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, target, rec.Code, want, rec.Body.String())
+		}
+
+		if rec.Code < http.StatusBadRequest {
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+
+		return got
+	}
+
+	// The recovery code of a claimed name is voided by renewing it, the name reserved for the score c is recovered by a code issued by an admin.
+	claimed := credentials(http.MethodPost, "/api/v1/session/player", "s1", `{"name":"a"}`, http.StatusCreated)
+	renewed := credentials(http.MethodPost, "/api/v1/session/player/recovery-code", "s1", "", http.StatusOK)
+	issued := credentials(http.MethodPost, "/api/v1/admin/players/c/recovery-code", "admin", "", http.StatusOK)
+	_ = credentials(http.MethodPost, "/api/v1/session/player/recovery-code", "s2", "", http.StatusNotFound)
+	_ = credentials(http.MethodPost, "/api/v1/admin/players/d/recovery-code", "admin", "", http.StatusNotFound)
+
+	parseToken := TokenParser(keys)
+	for _, tt := range []struct {
+		name   string
+		args   string
+		want   int
+		wantID string
+	}{
+		{"test#1", claimed.RecoveryCode, http.StatusNotFound, ""},
+		{"test#2", strings.ToLower(renewed.RecoveryCode), http.StatusOK, "s1"},
+		{"test#3", issued.RecoveryCode, http.StatusOK, issued.ID},
+		{"test#4", "", http.StatusBadRequest, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodPost, "/api/v1/session/recover", "", fmt.Sprintf(`{"recovery_code":%q}`, tt.args))
+			if rec.Code != tt.want {
+				t.Fatalf("POST /api/v1/session/recover = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			cookies := rec.Result().Cookies()
+			if tt.want != http.StatusOK {
+				if len(cookies) != 0 {
+					t.Errorf("POST /api/v1/session/recover set the cookies %v, want none", cookies)
+				}
+				return
+			}
+
+			if len(cookies) != 1 {
+				t.Fatalf("POST /api/v1/session/recover set %d cookies, want 1", len(cookies))
+			}
+
+			jwtToken, err := keys.Decrypt(cookies[0].Value)
+			if err != nil {
+				t.Fatalf("failed to decrypt cookie: %v", err)
+			}
+
+			claims, err := parseToken(jwtToken)
+			if err != nil {
+				t.Fatalf("failed to parse token: %v", err)
+			}
+
+			if claims.Subject != tt.wantID || tt.wantID == "" || !claims.HasScope(ScopePlayer) {
+				t.Errorf("POST /api/v1/session/recover issued the session %+v, want the player %q", claims, tt.wantID)
+			}
+		})
+	}
+}
+
+// failingExportStore is a store which fails to export the scores.
+type failingExportStore struct {
+	*memoryStore
+}
+
+func (s failingExportStore) ExportScores(int, func([]Score) error) error {
+	return errors.New("database unavailable")
+}
+
+func TestExportTable(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		store           Store
+		args            string
+		want            int
+		wantContentType string
+	}{
+		{"test#1", newTestStore(t, Score{Name: "a", Score: 1}), "/api/v1/admin/export/scores?format=csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"test#2", newTestStore(t), "/api/v1/admin/export/scores?format=xml", http.StatusBadRequest, "application/json; charset=utf-8"},
+		{"test#3", newTestStore(t), "/api/v1/admin/export/runs", http.StatusNotFound, "application/json; charset=utf-8"},
+		{"test#4", failingExportStore{newMemoryStore()}, "/api/v1/admin/export/scores", http.StatusInternalServerError, "application/json; charset=utf-8"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/api/v1/admin/export/:table", ExportTable(tt.store))
+
+			rec := serve(router, http.MethodGet, tt.args, "admin", "")
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.args, rec.Code, tt.want, rec.Body.String())
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("GET %s Content-Type = %q, want %q", tt.args, got, tt.wantContentType)
+			}
+
+			if got := rec.Header().Get("Content-Disposition"); (got != "") != (tt.want == http.StatusOK) {
+				t.Errorf("GET %s Content-Disposition = %q", tt.args, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	collectors "github.com/prometheus/client_golang/prometheus/collectors"
+	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
+	zap "go.uber.org/zap"
+)
+
+const metricsNamespace = "space_invaders" // metricsNamespace is the namespace of the Prometheus metrics.
+
+// instrumentation holds the Prometheus collectors of the game server.
+type instrumentation struct {
+	registry      *prometheus.Registry
+	inFlight      prometheus.Gauge
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	requests      *prometheus.CounterVec
+	requestTime   *prometheus.HistogramVec
+	responseSize  *prometheus.HistogramVec
+	logger        *zap.Logger
+}
+
+// Handler returns the handler serving the metrics in the Prometheus text exposition format.
+// The compression is left to the router.
+func (i *instrumentation) Handler() http.Handler {
+	return promhttp.HandlerFor(i.registry, promhttp.HandlerOpts{Registry: i.registry, DisableCompression: true})
+}
+
+// InstrumentAggregator registers the number of dropped and pending request counts of the metric aggregator.
+func (i *instrumentation) InstrumentAggregator(aggregator *metricAggregator) {
+	i.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "metric_aggregator_dropped_total",
+			Help:      "Number of requests not counted in the database because the buffer of the metric aggregator was full.",
+		}, func() float64 { return float64(aggregator.Dropped()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "metric_aggregator_pending",
+			Help:      "Number of distinct endpoints and methods buffered by the metric aggregator.",
+		}, func() float64 { return float64(aggregator.Pending()) }),
+	)
+}
+
+// InstrumentRateLimiter registers the number of clients tracked by the rate limiter.
+func (i *instrumentation) InstrumentRateLimiter(limiter *rateLimiter) {
+	i.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "rate_limiter_clients",
+		Help:      "Number of token buckets held by the rate limiter.",
+	}, func() float64 { return float64(limiter.Len()) }))
+}
+
+// Instrument returns the store with each query being timed.
+// It registers a collector reporting the database and table sizes of the store on each scrape.
+func (i *instrumentation) Instrument(store Store) Store {
+	instrumented := instrumentedStore{Store: store, instrumentation: i}
+	i.registry.MustRegister(sizeCollector{store: instrumented, logger: i.logger})
+	return instrumented
+}
+
+// Middleware returns a middleware that records the number, the latency, and the response size of the requests.
+// The requests are labeled by route, method and status code.
+func (i *instrumentation) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		i.inFlight.Inc()
+		defer i.inFlight.Dec()
+
+		ctx.Next()
+
+		route, method := getRoute(ctx), ctx.Request.Method
+		i.requests.WithLabelValues(route, method, strconv.Itoa(ctx.Writer.Status())).Inc()
+		i.requestTime.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+		i.responseSize.WithLabelValues(route, method).Observe(float64(max(ctx.Writer.Size(), 0)))
+	}
+}
+
+// observe records the duration and the failure of the store query.
+func (i *instrumentation) observe(operation string, start time.Time, err error) {
+	i.queryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		i.queryErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// instrumentedStore is a store which records the duration of its queries.
+type instrumentedStore struct {
+	Store
+	instrumentation *instrumentation
+}
+
+// CheckSchema records the duration of Store.CheckSchema.
+func (s instrumentedStore) CheckSchema() (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("CheckSchema", start, err) }(time.Now())
+	return s.Store.CheckSchema()
+}
+
+// ExportMetrics records the duration of Store.ExportMetrics, including the time spent by yield.
+func (s instrumentedStore) ExportMetrics(batchSize int, yield func([]Metric) error) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("ExportMetrics", start, err) }(time.Now())
+	return s.Store.ExportMetrics(batchSize, yield)
+}
+
+// ExportScores records the duration of Store.ExportScores, including the time spent by yield.
+func (s instrumentedStore) ExportScores(batchSize int, yield func([]Score) error) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("ExportScores", start, err) }(time.Now())
+	return s.Store.ExportScores(batchSize, yield)
+}
+
+// FindPlayer records the duration of Store.FindPlayer.
+func (s instrumentedStore) FindPlayer(query PlayerQuery) (_ Player, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("FindPlayer", start, err) }(time.Now())
+	return s.Store.FindPlayer(query)
+}
+
+// GetAuditEvents records the duration of Store.GetAuditEvents.
+func (s instrumentedStore) GetAuditEvents(query AuditQuery) (_ []AuditEvent, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetAuditEvents", start, err) }(time.Now())
+	return s.Store.GetAuditEvents(query)
+}
+
+// GetDatabaseSize records the duration of Store.GetDatabaseSize.
+func (s instrumentedStore) GetDatabaseSize() (_ Size, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetDatabaseSize", start, err) }(time.Now())
+	return s.Store.GetDatabaseSize()
+}
+
+// GetFeatureFlagHistory records the duration of Store.GetFeatureFlagHistory.
+func (s instrumentedStore) GetFeatureFlagHistory(key string, limit int) (_ []FeatureFlagChange, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetFeatureFlagHistory", start, err) }(time.Now())
+	return s.Store.GetFeatureFlagHistory(key, limit)
+}
+
+// GetFeatureFlags records the duration of Store.GetFeatureFlags.
+func (s instrumentedStore) GetFeatureFlags(player, session string) (_ []FeatureFlag, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetFeatureFlags", start, err) }(time.Now())
+	return s.Store.GetFeatureFlags(player, session)
+}
+
+// GetLeaderboard records the duration of Store.GetLeaderboard.
+func (s instrumentedStore) GetLeaderboard(query LeaderboardQuery) (_ Leaderboard, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetLeaderboard", start, err) }(time.Now())
+	return s.Store.GetLeaderboard(query)
+}
+
+// GetMetrics records the duration of Store.GetMetrics.
+func (s instrumentedStore) GetMetrics() (_ []Metric, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetMetrics", start, err) }(time.Now())
+	return s.Store.GetMetrics()
+}
+
+// GetPlayerProfile records the duration of Store.GetPlayerProfile.
+func (s instrumentedStore) GetPlayerProfile(name string, recentRuns int) (_ PlayerProfile, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetPlayerProfile", start, err) }(time.Now())
+	return s.Store.GetPlayerProfile(name, recentRuns)
+}
+
+// GetRank records the duration of Store.GetRank.
+func (s instrumentedStore) GetRank(name string) (_ int64, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetRank", start, err) }(time.Now())
+	return s.Store.GetRank(name)
+}
+
+// GetScore records the duration of Store.GetScore.
+func (s instrumentedStore) GetScore(name string) (_ Score, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetScore", start, err) }(time.Now())
+	return s.Store.GetScore(name)
+}
+
+// GetScores records the duration of Store.GetScores.
+func (s instrumentedStore) GetScores() (_ []Score, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetScores", start, err) }(time.Now())
+	return s.Store.GetScores()
+}
+
+// GetTableSizes records the duration of Store.GetTableSizes.
+func (s instrumentedStore) GetTableSizes() (_ map[string]Size, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("GetTableSizes", start, err) }(time.Now())
+	return s.Store.GetTableSizes()
+}
+
+// ImportScores records the duration of Store.ImportScores.
+func (s instrumentedStore) ImportScores(scores []Score, mode ImportMode, dryRun bool) (_ ImportReport, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("ImportScores", start, err) }(time.Now())
+	return s.Store.ImportScores(scores, mode, dryRun)
+}
+
+// Ping records the duration of Store.Ping.
+func (s instrumentedStore) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("Ping", start, err) }(time.Now())
+	return s.Store.Ping(ctx)
+}
+
+// Prune records the duration of Store.Prune.
+func (s instrumentedStore) Prune(policy RetentionPolicy) (_ RetentionReport, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("Prune", start, err) }(time.Now())
+	return s.Store.Prune(policy)
+}
+
+// SaveAuditEvent records the duration of Store.SaveAuditEvent.
+func (s instrumentedStore) SaveAuditEvent(event AuditEvent) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveAuditEvent", start, err) }(time.Now())
+	return s.Store.SaveAuditEvent(event)
+}
+
+// SaveFeatureFlags records the duration of Store.SaveFeatureFlags.
+func (s instrumentedStore) SaveFeatureFlags(changes []FeatureFlagChange) (_ []FeatureFlagChange, err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveFeatureFlags", start, err) }(time.Now())
+	return s.Store.SaveFeatureFlags(changes)
+}
+
+// SaveMetric records the duration of Store.SaveMetric.
+func (s instrumentedStore) SaveMetric(metric Metric) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveMetric", start, err) }(time.Now())
+	return s.Store.SaveMetric(metric)
+}
+
+// SaveMetrics records the duration of Store.SaveMetrics.
+func (s instrumentedStore) SaveMetrics(metrics []Metric) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveMetrics", start, err) }(time.Now())
+	return s.Store.SaveMetrics(metrics)
+}
+
+// SavePlayer records the duration of Store.SavePlayer.
+func (s instrumentedStore) SavePlayer(player Player) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SavePlayer", start, err) }(time.Now())
+	return s.Store.SavePlayer(player)
+}
+
+// SaveRecoveryHash records the duration of Store.SaveRecoveryHash.
+func (s instrumentedStore) SaveRecoveryHash(id, hash string) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveRecoveryHash", start, err) }(time.Now())
+	return s.Store.SaveRecoveryHash(id, hash)
+}
+
+// SaveRun records the duration of Store.SaveRun.
+func (s instrumentedStore) SaveRun(run ScoreRun) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveRun", start, err) }(time.Now())
+	return s.Store.SaveRun(run)
+}
+
+// SaveScores records the duration of Store.SaveScores.
+func (s instrumentedStore) SaveScores(scores []Score) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveScores", start, err) }(time.Now())
+	return s.Store.SaveScores(scores)
+}
+
+// SaveUnverifiedRun records the duration of Store.SaveUnverifiedRun.
+func (s instrumentedStore) SaveUnverifiedRun(run UnverifiedRun) (err error) {
+	defer func(start time.Time) { s.instrumentation.observe("SaveUnverifiedRun", start, err) }(time.Now())
+	return s.Store.SaveUnverifiedRun(run)
+}
+
+// sizeCollector collects the database and table sizes of the store.
+type sizeCollector struct {
+	store  Store
+	logger *zap.Logger
+}
+
+var (
+	databaseLimitDesc = prometheus.NewDesc(metricsNamespace+"_database_limit_bytes", "Maximum size of the database.", nil, nil)
+	databaseSizeDesc  = prometheus.NewDesc(metricsNamespace+"_database_size_bytes", "Size of the database.", nil, nil)
+	tableSizeDesc     = prometheus.NewDesc(metricsNamespace+"_database_table_size_bytes", "Size of the database tables.", []string{"table"}, nil)
+)
+
+// Collect collects the sizes from the store.
+// Failing queries are logged and their metrics omitted.
+func (c sizeCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(databaseLimitDesc, prometheus.GaugeValue, maximumSize)
+
+	if size, err := c.store.GetDatabaseSize(); err != nil {
+		c.logger.Error("Failed to get database size", zap.Error(err))
+	} else {
+		ch <- prometheus.MustNewConstMetric(databaseSizeDesc, prometheus.GaugeValue, float64(size))
+	}
+
+	tables, err := c.store.GetTableSizes()
+	if err != nil {
+		c.logger.Error("Failed to get table sizes", zap.Error(err))
+		return
+	}
+
+	for table, size := range tables {
+		ch <- prometheus.MustNewConstMetric(tableSizeDesc, prometheus.GaugeValue, float64(size), table)
+	}
+}
+
+// Describe describes the collected metrics.
+func (c sizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- databaseLimitDesc
+	ch <- databaseSizeDesc
+	ch <- tableSizeDesc
+}
+
+// Instrumentation returns the Prometheus collectors of the game server.
+// The collectors of the Go runtime and the process are registered as well.
+// Failures to collect the database and table sizes are logged by the logger.
+func Instrumentation(logger *zap.Logger) *instrumentation {
+	i := &instrumentation{
+		logger:   logger,
+		registry: prometheus.NewRegistry(),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of requests currently being served.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "store_query_duration_seconds",
+			Help:      "Duration of the store queries.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to ~4s
+		}, []string{"operation"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "store_query_errors_total",
+			Help:      "Number of failed store queries.",
+		}, []string{"operation"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of served requests.",
+		}, []string{"route", "method", "status"}),
+		requestTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the served requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of the response bodies.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 10), // 64B to 16MiB
+		}, []string{"route", "method"}),
+	}
+
+	i.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		i.inFlight,
+		i.queryDuration,
+		i.queryErrors,
+		i.requests,
+		i.requestTime,
+		i.responseSize,
+	)
+
+	return i
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+	zap "go.uber.org/zap"
+)
+
+func TestRouteTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args string
+		want string
+	}{
+		{"test#1", `^/?health/?$`, "/health"},
+		{"test#2", `^/?config\.ini/?$`, "/config.ini"},
+		{"test#3", `^/?api/v1/players/(?P<name>[^/]+)/?$`, "/api/v1/players/:name"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := routeTemplate(regexp.MustCompile(tt.args)); got != tt.want {
+				t.Errorf("routeTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentation(t *testing.T) {
+	instruments := Instrumentation(zap.NewNop())
+	store := instruments.Instrument(newTestStore(t, Score{Name: "a", Score: 1}))
+
+	router := gin.New()
+	router.Use(instruments.Middleware())
+	router.Match([]string{http.MethodHead, http.MethodGet}, "/*filepath", ServeFileSystem(map[*regexp.Regexp]gin.HandlersChain{
+		regexp.MustCompile(`^/?api/v1/players/(?P<name>[^/]+)/?$`): {GetPlayer(store)},
+		regexp.MustCompile(`^/?metrics/?$`):                        {gin.WrapH(instruments.Handler())},
+	}))
+
+	for _, target := range []string{"/api/v1/players/a", "/api/v1/players/b", "/api/v1/players/b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`space_invaders_http_requests_total{method="GET",route="/api/v1/players/:name",status="200"} 1`,
+		`space_invaders_http_requests_total{method="GET",route="/api/v1/players/:name",status="404"} 2`,
+		`space_invaders_http_request_duration_seconds_count{method="GET",route="/api/v1/players/:name"} 3`,
+		`space_invaders_http_requests_in_flight 1`,
+		`space_invaders_store_query_duration_seconds_count{operation="GetPlayerProfile"} 3`,
+		`space_invaders_store_query_errors_total{operation="GetPlayerProfile"} 2`,
+		`space_invaders_database_limit_bytes`,
+		`space_invaders_database_table_size_bytes{table="scores"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /metrics does not contain %q", want)
+		}
+	}
+}
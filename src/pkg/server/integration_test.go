@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+)
+
+func newTestServer(t *testing.T, store Store, keys *KeySet) *httptest.Server {
+	t.Helper()
+
+	handler, err := New(Options{Store: store, Keys: keys, Replayer: replayInProcess})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		if err := handler.Close(); err != nil {
+			t.Errorf("Close() failed: %v", err)
+		}
+	})
+
+	return server
+}
+
+func TestClientIntegration(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeySet(t, "test")
+	server := newTestServer(t, newMemoryStore(), keys)
+
+	newClient := func(scope string) *client.Client {
+		c, err := client.New(server.URL, client.WithToken(newTestToken(t, keys, scope)))
+		if err != nil {
+			t.Fatalf("client.New() failed: %v", err)
+		}
+		return c
+	}
+
+	player, admin := newClient(ScopePlayer), newClient(ScopeAdmin)
+
+	if _, err := player.GetLiveness(ctx); err != nil {
+		t.Errorf("GetLiveness() failed: %v", err)
+	}
+
+	if spec, err := player.GetOpenAPI(ctx); err != nil || string(spec) != string(client.OpenAPI) {
+		t.Errorf("GetOpenAPI() = %v, want the embedded description", err)
+	}
+
+	// The scores saved and imported below assume the level of the replay to be above 1 and below the score of b.
+	recorded, level := newTestReplay(t)
+	if level < 2 || level >= 5 {
+		t.Fatalf("Replay() = %d, want a level within [2, 5)", level)
+	}
+
+	var replay client.Replay
+	if raw, err := json.Marshal(recorded); err != nil || json.Unmarshal(raw, &replay) != nil {
+		t.Fatalf("failed to convert replay: %v", err)
+	}
+
+	if _, err := player.SubmitScore(ctx, client.ScoreSubmission{Name: "a", Level: level, Duration: time.Second, Replay: &replay}); client.StatusCode(err) != http.StatusForbidden {
+		t.Errorf("SubmitScore() of an unclaimed name = %v, want status %d", err, http.StatusForbidden)
+	}
+
+	credentials, err := player.ClaimName(ctx, "a")
+	if err != nil || credentials.ID != "test" || !client.IsRecoveryCode(credentials.RecoveryCode) {
+		t.Fatalf("ClaimName() = (%+v, %v), want the player of the session with a recovery code", credentials, err)
+	}
+
+	result, err := player.SubmitScore(ctx, client.ScoreSubmission{Name: "a", Level: level, Duration: time.Second, DiscoveredPlanets: []string{"Neptune"}, Replay: &replay})
+	if err != nil || result.Score != level || result.Rank != 1 || !result.Verified {
+		t.Fatalf("SubmitScore() = (%+v, %v), want verified score %d and rank 1", result, err, level)
+	}
+
+	if result, err := player.SubmitScore(ctx, client.ScoreSubmission{Name: "a", Level: 100, Duration: time.Second}); err != nil || result.Score != level || result.Verified {
+		t.Errorf("SubmitScore() without a replay = (%+v, %v), want unverified score %d", result, err, level)
+	}
+
+	if _, err := player.SubmitScore(ctx, client.ScoreSubmission{Name: "a"}); client.StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Errorf("SubmitScore() of an invalid run = %v, want status %d", err, http.StatusUnprocessableEntity)
+	}
+
+	if err := player.SaveScores(ctx, []client.Score{{Name: "b", Score: 5}}); client.StatusCode(err) != http.StatusForbidden {
+		t.Errorf("SaveScores() with the player scope = %v, want status %d", err, http.StatusForbidden)
+	}
+
+	if err := admin.SaveScores(ctx, []client.Score{{Name: "b", Score: 5}}); err != nil {
+		t.Fatalf("SaveScores() failed: %v", err)
+	}
+
+	// The name of the saved score is reserved for a new player, whose identity is recovered by a code issued by an admin.
+	issued, err := admin.IssueRecoveryCode(ctx, "b")
+	if err != nil || issued.ID == credentials.ID {
+		t.Fatalf("IssueRecoveryCode() = (%+v, %v), want the code of another player", issued, err)
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New() failed: %v", err)
+	}
+
+	device, err := client.New(server.URL, client.WithHTTPClient(&http.Client{Jar: jar}))
+	if err != nil {
+		t.Fatalf("client.New() failed: %v", err)
+	}
+
+	if recovered, err := device.RecoverPlayer(ctx, issued.RecoveryCode); err != nil || recovered.ID != issued.ID {
+		t.Errorf("RecoverPlayer() = (%+v, %v), want the player %s", recovered, err, issued.ID)
+	}
+
+	if self, err := device.GetSessionPlayer(ctx); err != nil || self.Name != "b" {
+		t.Errorf("GetSessionPlayer() after the recovery = (%+v, %v), want b", self, err)
+	}
+
+	scores, err := player.GetScores(ctx)
+	if err != nil || len(scores) != 2 || scores[0].Name != "b" {
+		t.Errorf("GetScores() = (%+v, %v), want b ahead of a", scores, err)
+	}
+
+	board, err := player.GetLeaderboard(ctx, client.LeaderboardQuery{Around: "a", Limit: 1})
+	if err != nil || len(board.Entries) != 1 || board.Entries[0].Name != "a" || board.Entries[0].Rank != 2 {
+		t.Errorf("GetLeaderboard() = (%+v, %v), want a ranked second", board, err)
+	}
+
+	profile, err := player.GetPlayer(ctx, "a")
+	if err != nil || profile.RunCount != 1 || profile.BestScore != level {
+		t.Errorf("GetPlayer() = (%+v, %v), want a single run scoring %d", profile, err, level)
+	}
+
+	value := "true"
+	env, err := admin.SetEnv(ctx, map[string]*string{envVarPrefix + "GOD_MODE": &value}, client.FlagScopePlayer, "a")
+	if err != nil || env[envVarPrefix+"GOD_MODE"] != "true" {
+		t.Errorf("SetEnv() = (%v, %v), want GOD_MODE set", env, err)
+	}
+
+	if env, err := player.GetEnv(ctx, ""); err != nil || env[envVarPrefix+"GOD_MODE"] != "true" {
+		t.Errorf("GetEnv() = (%v, %v), want GOD_MODE of the player", env, err)
+	}
+
+	if env, err := device.GetEnv(ctx, "a"); err != nil || env[envVarPrefix+"GOD_MODE"] == "true" {
+		t.Errorf("GetEnv() of another player = (%v, %v), want GOD_MODE unset", env, err)
+	}
+
+	if env, err := admin.GetEnv(ctx, "a"); err != nil || env[envVarPrefix+"GOD_MODE"] != "true" {
+		t.Errorf("GetEnv() of the player by admin = (%v, %v), want GOD_MODE of the player", env, err)
+	}
+
+	changes, err := admin.GetFlagHistory(ctx, "", 0)
+	if err != nil || len(changes) != 1 || changes[0].Subject != "a" {
+		t.Errorf("GetFlagHistory() = (%+v, %v), want the change of a", changes, err)
+	}
+
+	events, err := admin.GetAuditEvents(ctx, client.AuditQuery{Action: "PUT /scores.db"})
+	if err != nil || len(events) != 2 || events[0].Status != http.StatusOK || !strings.Contains(string(events[0].After), `"b"`) {
+		t.Errorf("GetAuditEvents() = (%+v, %v), want the rejected and the accepted replacement", events, err)
+	}
+
+	var export strings.Builder
+	if err := admin.ExportTable(ctx, client.TableScores, client.FormatCSV, &export); err != nil || !strings.HasPrefix(export.String(), fmt.Sprintf("name,score,created_at,updated_at\na,%d,", level)) {
+		t.Errorf("ExportTable() = (%q, %v), want the header followed by a", export.String(), err)
+	}
+
+	if err := admin.ExportTable(ctx, "runs", client.FormatCSV, io.Discard); client.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("ExportTable() of an unknown table = %v, want status %d", err, http.StatusNotFound)
+	}
+
+	if err := player.ExportTable(ctx, client.TableScores, client.FormatCSV, io.Discard); client.StatusCode(err) != http.StatusForbidden {
+		t.Errorf("ExportTable() with the player scope = %v, want status %d", err, http.StatusForbidden)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		input   string
+		options client.ImportOptions
+		status  int
+		want    client.ImportReport
+	}{
+		{"test#1", "name,score\na,1\nb,9\nc,2\n", client.ImportOptions{Format: client.FormatCSV, DryRun: true}, 0, client.ImportReport{Mode: client.ImportKeepHigher, DryRun: true, Total: 3, Created: 1, Updated: 1, Unchanged: 1}},
+		{"test#2", `[{"name":"a","score":1},{"name":"b","score":9},{"name":"c","score":2}]`, client.ImportOptions{}, 0, client.ImportReport{Mode: client.ImportKeepHigher, Total: 3, Created: 1, Updated: 1, Unchanged: 1}},
+		{"test#3", "{\"name\":\"a\",\"score\":0}\n", client.ImportOptions{Format: client.FormatNDJSON, Mode: client.ImportOverwrite}, 0, client.ImportReport{Mode: client.ImportOverwrite, Total: 1, Updated: 1}},
+		{"test#4", `[{"name":"a","score":1},{"name":"a","score":2}]`, client.ImportOptions{}, http.StatusUnprocessableEntity, client.ImportReport{}},
+		{"test#5", `{"name":"a"}`, client.ImportOptions{}, http.StatusBadRequest, client.ImportReport{}},
+		{"test#6", `[]`, client.ImportOptions{Mode: "merge"}, http.StatusBadRequest, client.ImportReport{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := admin.ImportScores(ctx, strings.NewReader(tt.input), tt.options)
+			if client.StatusCode(err) != tt.status || tt.status == 0 && err != nil {
+				t.Fatalf("ImportScores() = %v, want status %d", err, tt.status)
+			}
+
+			report.Changes = nil
+			if !reflect.DeepEqual(report, tt.want) {
+				t.Errorf("ImportScores() = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+
+	export.Reset()
+	if err := admin.ExportTable(ctx, client.TableScores, client.FormatNDJSON, &export); err != nil || strings.Count(export.String(), "\n") != 3 {
+		t.Errorf("ExportTable() = (%q, %v), want the three imported scores", export.String(), err)
+	}
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// keyIDPattern is the pattern of valid key identifiers.
+// Key identifiers must not contain dots, since they are used as a prefix of the encrypted session cookies.
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// keyLoader loads the RSA keys and the AES keys keyed by their key identifiers.
+type keyLoader func() (signers map[string]*rsa.PrivateKey, ciphers map[string]cipher.AEAD, err error)
+
+// KeySet holds the RSA keys to sign and verify JWT tokens and the AES keys to encrypt and decrypt session cookies.
+// The keys with the greatest key identifier are used to sign and encrypt, all keys are used to verify and decrypt.
+// It is safe for concurrent use and can be reloaded at runtime.
+type KeySet struct {
+	load     keyLoader
+	mutex    sync.RWMutex
+	cipherID string // cipherID is the identifier of the AES key used to encrypt.
+	ciphers  map[string]cipher.AEAD
+	signerID string // signerID is the identifier of the RSA key used to sign.
+	signers  map[string]*rsa.PrivateKey
+}
+
+// Decrypt decrypts the session cookie.
+// The cookie is expected to be prefixed with the identifier of the AES key followed by a dot.
+// Cookies without a known key identifier are decrypted by trying all AES keys.
+func (keys *KeySet) Decrypt(value string) (string, error) {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	if kid, encrypted, ok := strings.Cut(value, "."); ok {
+		if cryptKey, ok := keys.ciphers[kid]; ok {
+			return decodeB64AndDecryptWithAES(cryptKey, encrypted)
+		}
+	}
+
+	var errs []error
+	for _, kid := range sortedKeys(keys.ciphers) {
+		plaintext, err := decodeB64AndDecryptWithAES(keys.ciphers[kid], value)
+		if err == nil {
+			return plaintext, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", kid, err))
+	}
+
+	return "", fmt.Errorf("failed to decrypt: %w", errors.Join(errs...))
+}
+
+// Encrypt encrypts the session cookie using the most recent AES key.
+// The encrypted cookie is prefixed with the identifier of the AES key followed by a dot.
+func (keys *KeySet) Encrypt(plaintext string) (string, error) {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	encrypted, err := encryptAndEncodeB64WithAES(keys.ciphers[keys.cipherID], plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return keys.cipherID + "." + encrypted, nil
+}
+
+// IDs returns the identifiers of the RSA keys and of the AES keys.
+func (keys *KeySet) IDs() (signers []string, ciphers []string) {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	return sortedKeys(keys.signers), sortedKeys(keys.ciphers)
+}
+
+// Reload reloads the keys.
+// If loading fails, the previously loaded keys are kept.
+func (keys *KeySet) Reload() error {
+	signers, ciphers, err := keys.load()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(signers) == 0:
+		return fmt.Errorf("no RSA key found")
+	case len(ciphers) == 0:
+		return fmt.Errorf("no AES key found")
+	}
+
+	for kid := range signers {
+		if !keyIDPattern.MatchString(kid) {
+			return fmt.Errorf("invalid key identifier: %q", kid)
+		}
+	}
+
+	for kid := range ciphers {
+		if !keyIDPattern.MatchString(kid) {
+			return fmt.Errorf("invalid key identifier: %q", kid)
+		}
+	}
+
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	keys.signers, keys.signerID = signers, slices.Max(sortedKeys(signers))
+	keys.ciphers, keys.cipherID = ciphers, slices.Max(sortedKeys(ciphers))
+	return nil
+}
+
+// Sign signs the claims using the most recent RSA key.
+// The identifier of the key is stored in the kid header of the token.
+func (keys *KeySet) Sign(claims jwt.Claims) (string, error) {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keys.signerID
+	return token.SignedString(keys.signers[keys.signerID])
+}
+
+// VerificationKey returns the public key to verify the token.
+// The key is selected by the kid header of the token.
+// Tokens without a kid header are verified by trying all RSA keys.
+func (keys *KeySet) VerificationKey(token *jwt.Token) (any, error) {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		set := jwt.VerificationKeySet{}
+		for _, kid := range sortedKeys(keys.signers) {
+			set.Keys = append(set.Keys, &keys.signers[kid].PublicKey)
+		}
+		return set, nil
+	}
+
+	key, ok := keys.signers[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key identifier: %q", kid)
+	}
+
+	return &key.PublicKey, nil
+}
+
+// loadKeyDirectory returns a loader for the keys stored in the PEM files of the given directory.
+// The type of a key is determined by the type of its PEM block, its identifier by the file name without the extension.
+func loadKeyDirectory(dir string) keyLoader {
+	return func() (map[string]*rsa.PrivateKey, map[string]cipher.AEAD, error) {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		signers, ciphers := make(map[string]*rsa.PrivateKey), make(map[string]cipher.AEAD)
+		for _, path := range paths {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read key: %w", err)
+			}
+
+			block, _ := pem.Decode(raw)
+			if block == nil {
+				return nil, nil, fmt.Errorf("failed to decode key: %s", path)
+			}
+
+			kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			switch block.Type {
+			case "AES PRIVATE KEY":
+				if ciphers[kid], err = parseAES2GCMKeyFromPem(raw); err != nil {
+					return nil, nil, fmt.Errorf("failed to parse AES key %s: %w", path, err)
+				}
+
+			default:
+				if signers[kid], err = jwt.ParseRSAPrivateKeyFromPEM(raw); err != nil {
+					return nil, nil, fmt.Errorf("failed to parse RSA key %s: %w", path, err)
+				}
+
+			}
+		}
+
+		return signers, ciphers, nil
+	}
+}
+
+// loadKeyFiles returns a loader for a single RSA key and a single AES key.
+// The identifiers of the keys are the file names without the extensions.
+func loadKeyFiles(rsaKeyPath, aesKeyPath string) keyLoader {
+	kid := func(path string) string { return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) }
+
+	return func() (map[string]*rsa.PrivateKey, map[string]cipher.AEAD, error) {
+		key, err := loadRSAKey(rsaKeyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cryptKey, err := loadAESKey(aesKeyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return map[string]*rsa.PrivateKey{kid(rsaKeyPath): key}, map[string]cipher.AEAD{kid(aesKeyPath): cryptKey}, nil
+	}
+}
+
+// GenerateKeys generates an RSA key of the given size in bits and an AES key of the given size in bytes.
+// The RSA key is written as a PKCS #8 PEM block, the AES key as an AES PRIVATE KEY PEM block.
+// Existing keys are not overwritten unless forced, and no key is written if one of them already exists.
+func GenerateKeys(rsaKeyPath string, rsaKeyBits int, aesKeyPath string, aesKeySize int, force bool) error {
+	switch {
+	case !slices.Contains([]int{16, 24, 32}, aesKeySize):
+		return fmt.Errorf("invalid AES key size: %d", aesKeySize)
+	case rsaKeyBits < 2048:
+		return fmt.Errorf("RSA key size must be at least 2048 bits: %d", rsaKeyBits)
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return fmt.Errorf("failed to generate RSA key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode RSA key: %w", err)
+	}
+
+	secret := make([]byte, aesKeySize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return fmt.Errorf("failed to generate AES key: %w", err)
+	}
+
+	keys := []struct {
+		path  string
+		block *pem.Block
+	}{
+		{rsaKeyPath, &pem.Block{Type: "PRIVATE KEY", Bytes: der}},
+		{aesKeyPath, &pem.Block{Type: "AES PRIVATE KEY", Bytes: secret}},
+	}
+
+	// Do not write any key if one of them already exists.
+	for _, key := range keys {
+		if _, err := os.Stat(key.path); err == nil && !force {
+			return fmt.Errorf("key already exists, use --force to overwrite: %s", key.path)
+		}
+	}
+
+	for _, key := range keys {
+		if err := writePEM(key.path, key.block, force); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// KeyPaths returns the paths of the RSA key and of the AES key with the given identifier in the key directory.
+func KeyPaths(dir, kid string) (rsaKeyPath, aesKeyPath string, err error) {
+	if !keyIDPattern.MatchString(kid) {
+		return "", "", fmt.Errorf("invalid key identifier: %q", kid)
+	}
+
+	return filepath.Join(dir, kid+"-rsa.pem"), filepath.Join(dir, kid+"-aes.pem"), nil
+}
+
+// OpenKeySet loads the keys from the key directory, if given, or from the single RSA and AES key files otherwise.
+func OpenKeySet(dir, rsaKeyPath, aesKeyPath string) (*KeySet, error) {
+	if dir != "" {
+		return newKeySet(loadKeyDirectory(dir))
+	}
+
+	return newKeySet(loadKeyFiles(rsaKeyPath, aesKeyPath))
+}
+
+// StaticKeySet returns a key set of the given RSA key and AES key (16, 24 or 32 bytes), both identified by kid.
+// The keys are held in memory only, e.g. for tests or for a program managing its keys on its own,
+// hence reloading the key set keeps them.
+func StaticKeySet(kid string, rsaKey *rsa.PrivateKey, aesKey []byte) (*KeySet, error) {
+	if rsaKey == nil {
+		return nil, fmt.Errorf("missing RSA key")
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AES key: %w", err)
+	}
+
+	cryptKey, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AES key: %w", err)
+	}
+
+	return newKeySet(func() (map[string]*rsa.PrivateKey, map[string]cipher.AEAD, error) {
+		return map[string]*rsa.PrivateKey{kid: rsaKey}, map[string]cipher.AEAD{kid: cryptKey}, nil
+	})
+}
+
+// writePEM writes the PEM block to the file at the given path.
+// It fails if the file already exists, unless forced.
+func writePEM(path string, block *pem.Block, force bool) error {
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, mode, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	if err := pem.Encode(file, block); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return file.Close()
+}
+
+// sortedKeys returns the keys of the map in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
+
+// newKeySet loads the keys using the given loader.
+func newKeySet(load keyLoader) (*KeySet, error) {
+	keys := &KeySet{load: load}
+	if err := keys.Reload(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func generateTestKeys(t *testing.T, dir, kid string) {
+	t.Helper()
+
+	rsaKeyPath, aesKeyPath, err := KeyPaths(dir, kid)
+	if err != nil {
+		t.Fatalf("invalid key identifier: %v", err)
+	}
+
+	if err := GenerateKeys(rsaKeyPath, 2048, aesKeyPath, 32, false); err != nil {
+		t.Fatalf("failed to generate keys: %v", err)
+	}
+}
+
+func newTestKeySet(t *testing.T, kid string) *KeySet {
+	t.Helper()
+
+	dir := t.TempDir()
+	generateTestKeys(t, dir, kid)
+
+	keys, err := newKeySet(loadKeyDirectory(dir))
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	return keys
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	generate := func(kid string) {
+		t.Helper()
+		generateTestKeys(t, dir, kid)
+	}
+
+	generate("20240101T000000Z")
+	keys, err := newKeySet(loadKeyDirectory(dir))
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	parse := TokenParser(keys)
+	oldToken, _ := IssueToken(keys, "old", 0, ScopePlayer)
+	oldCookie, _ := keys.Encrypt(oldToken)
+
+	// Cookies encrypted before the introduction of key identifiers are not tagged.
+	legacyCookie, _ := encryptAndEncodeB64WithAES(keys.ciphers[keys.cipherID], oldToken)
+
+	generate("20250101T000000Z")
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("failed to reload keys: %v", err)
+	}
+
+	newToken, _ := IssueToken(keys, "new", 0, ScopePlayer)
+	newCookie, _ := keys.Encrypt(newToken)
+
+	if want := "20250101T000000Z-aes."; !strings.HasPrefix(newCookie, want) {
+		t.Errorf("Encrypt() = %q, want prefix %q", newCookie, want)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		cookie      string
+		wantSubject string
+	}{
+		{"test#1", oldCookie, "old"},
+		{"test#2", legacyCookie, "old"},
+		{"test#3", newCookie, "new"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := keys.Decrypt(tt.cookie)
+			if err != nil {
+				t.Fatalf("Decrypt() failed: %v", err)
+			}
+
+			claims, err := parse(token)
+			if err != nil {
+				t.Fatalf("failed to verify token: %v", err)
+			}
+
+			if claims.Subject != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", claims.Subject, tt.wantSubject)
+			}
+		})
+	}
+
+	// Retire the old keys.
+	for _, suffix := range []string{"-rsa.pem", "-aes.pem"} {
+		if err := os.Remove(filepath.Join(dir, "20240101T000000Z"+suffix)); err != nil {
+			t.Fatalf("failed to remove key: %v", err)
+		}
+	}
+
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("failed to reload keys: %v", err)
+	}
+
+	if _, err := parse(oldToken); err == nil {
+		t.Errorf("token signed by a retired key is still valid")
+	}
+
+	if _, err := keys.Decrypt(oldCookie); err == nil {
+		t.Errorf("cookie encrypted by a retired key can still be decrypted")
+	}
+
+	if _, err := parse(newToken); err != nil {
+		t.Errorf("failed to verify token: %v", err)
+	}
+}
+
+func TestKeySetReloadFailure(t *testing.T) {
+	dir := t.TempDir()
+	generateTestKeys(t, dir, "a")
+
+	keys, err := newKeySet(loadKeyDirectory(dir))
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	token, _ := IssueToken(keys, "test", 0)
+	if err := os.Remove(filepath.Join(dir, "a-aes.pem")); err != nil {
+		t.Fatalf("failed to remove key: %v", err)
+	}
+
+	if err := keys.Reload(); err == nil {
+		t.Errorf("Reload() succeeded without an AES key")
+	}
+
+	if _, err := TokenParser(keys)(token); err != nil {
+		t.Errorf("previous keys have not been kept: %v", err)
+	}
+}
+
+func TestStaticKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		kid     string
+		rsaKey  *rsa.PrivateKey
+		aesKey  []byte
+		wantErr bool
+	}{
+		{"test#1", "test", rsaKey, make([]byte, 32), false},
+		{"test#2", "test", rsaKey, make([]byte, 20), true},
+		{"test#3", "test", nil, make([]byte, 32), true},
+		{"test#4", "te.st", rsaKey, make([]byte, 32), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := StaticKeySet(tt.kid, tt.rsaKey, tt.aesKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StaticKeySet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			token, _ := IssueToken(keys, "subject", 0, ScopePlayer)
+			cookie, _ := keys.Encrypt(token)
+			decrypted, err := keys.Decrypt(cookie)
+			if err != nil {
+				t.Fatalf("Decrypt() failed: %v", err)
+			}
+
+			if claims, err := TokenParser(keys)(decrypted); err != nil || claims.Subject != "subject" {
+				t.Errorf("TokenParser() = (%v, %v), want the subject", claims, err)
+			}
+		})
+	}
+}
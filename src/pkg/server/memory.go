@@ -0,0 +1,749 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	planet "github.com/sarumaj/edu-space-invaders/src/pkg/objects/planet"
+)
+
+// memoryStore is a thread-safe in-memory store.
+// It mirrors the behavior of the Postgres database and is meant for development and testing.
+// Its content is lost when the server stops.
+type memoryStore struct {
+	mutex        sync.RWMutex
+	auditEvents  []AuditEvent
+	auditEventID uint64                    // auditEventID is the identifier of the most recently recorded event.
+	flags        map[[3]string]FeatureFlag // flags are keyed by key, scope and subject.
+	flagChanges  []FeatureFlagChange
+	flagChangeID uint64               // flagChangeID is the identifier of the most recently recorded change.
+	metrics      map[[2]string]Metric // metrics are keyed by endpoint and method.
+	players      map[string]Player    // players are keyed by identifier.
+	runs         []ScoreRun
+	runID        uint64            // runID is the identifier of the most recently saved run.
+	scores       map[string]Score  // scores are keyed by the identifier of their player.
+	scoreNames   map[string]string // scoreNames are the identifiers of the players of the scores keyed by name, which is unique.
+	unverified   []UnverifiedRun
+	unverifiedID uint64 // unverifiedID is the identifier of the most recently saved unverified run.
+}
+
+// CheckSchema is a no-op, since the store needs no migration.
+func (store *memoryStore) CheckSchema() error { return nil }
+
+// checkScoreNames returns ErrConflict if the name of a new score is the name of the score of another player,
+// like the unique index of the names of the scores. Nothing is saved if a name conflicts.
+// The caller must hold the read lock.
+func (store *memoryStore) checkScoreNames(scores []Score) error {
+	names := make(map[string]string, len(scores))
+	for _, score := range scores {
+		if _, ok := store.scores[score.PlayerID]; ok {
+			continue
+		}
+
+		id, ok := store.scoreNames[score.Name]
+		if !ok {
+			id, ok = names[score.Name]
+		}
+
+		if ok && id != score.PlayerID {
+			return fmt.Errorf("%w: score %s", ErrConflict, score.Name)
+		}
+		names[score.Name] = score.PlayerID
+	}
+
+	return nil
+}
+
+// Close is a no-op, since the store holds no external resources.
+func (store *memoryStore) Close() error { return nil }
+
+// GetDatabaseSize returns the size of the store.
+// It is the sum of the table sizes.
+func (store *memoryStore) GetDatabaseSize() (Size, error) {
+	tables, err := store.GetTableSizes()
+	if err != nil {
+		return 0, err
+	}
+
+	var size Size
+	for _, tableSize := range tables {
+		size += tableSize
+	}
+
+	return size, nil
+}
+
+// ExportMetrics passes the metrics to yield in batches of the given size, ordered by endpoint and method.
+// The metrics are copied beforehand, so that yield runs without holding the lock.
+func (store *memoryStore) ExportMetrics(batchSize int, yield func([]Metric) error) error {
+	metrics, _ := store.GetMetrics()
+	return exportBatches(metrics, batchSize, yield)
+}
+
+// ExportScores passes the scores to yield in batches of the given size, ordered by name.
+// The scores are copied beforehand, so that yield runs without holding the lock.
+func (store *memoryStore) ExportScores(batchSize int, yield func([]Score) error) error {
+	store.mutex.RLock()
+	scores := make([]Score, 0, len(store.scores))
+	for _, score := range store.scores {
+		scores = append(scores, score)
+	}
+	store.mutex.RUnlock()
+
+	slices.SortFunc(scores, func(a, b Score) int { return cmp.Compare(a.Name, b.Name) })
+	return exportBatches(scores, batchSize, yield)
+}
+
+// FindPlayer returns the player matching all non-empty fields of the query.
+func (store *memoryStore) FindPlayer(query PlayerQuery) (Player, error) {
+	if query == (PlayerQuery{}) {
+		return Player{}, fmt.Errorf("%w: empty query", ErrNotFound)
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, player := range store.players {
+		switch {
+		case query.ID != "" && player.ID != query.ID,
+			query.Name != "" && player.Name != query.Name,
+			query.RecoveryHash != "" && player.RecoveryHash != query.RecoveryHash:
+		default:
+			return player, nil
+		}
+	}
+
+	return Player{}, fmt.Errorf("%w: player", ErrNotFound)
+}
+
+// GetAuditEvents returns the audit events matching the query, the newest first.
+func (store *memoryStore) GetAuditEvents(query AuditQuery) ([]AuditEvent, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	query = query.withDefaults()
+	events := make([]AuditEvent, 0)
+	for _, event := range store.auditEvents {
+		switch {
+		case query.Actor != "" && event.Actor != query.Actor,
+			query.Action != "" && event.Action != query.Action,
+			!query.Since.IsZero() && event.OccurredAt.Before(query.Since),
+			!query.Until.IsZero() && !event.OccurredAt.Before(query.Until):
+		default:
+			events = append(events, event)
+		}
+	}
+
+	// The events are recorded once completed, but sorted by the time they occurred.
+	slices.SortFunc(events, func(a, b AuditEvent) int {
+		return cmp.Or(b.OccurredAt.Compare(a.OccurredAt), cmp.Compare(b.ID, a.ID))
+	})
+
+	return events[:min(query.Limit, len(events))], nil
+}
+
+// GetFeatureFlagHistory returns the most recent changes of the feature flags, the newest first.
+// If key is not empty, only the changes of the given flag are returned.
+func (store *memoryStore) GetFeatureFlagHistory(key string, limit int) ([]FeatureFlagChange, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	changes := make([]FeatureFlagChange, 0, min(limit, len(store.flagChanges)))
+	for i := len(store.flagChanges) - 1; i >= 0 && len(changes) < limit; i-- {
+		if change := store.flagChanges[i]; key == "" || change.Key == key {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// GetFeatureFlags returns the global feature flags and the flags overridden for the player and the session.
+// Empty names of the player or the session match no overrides.
+func (store *memoryStore) GetFeatureFlags(player, session string) ([]FeatureFlag, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var flags []FeatureFlag
+	for _, flag := range store.flags {
+		switch {
+		case flag.Scope == flagScopeGlobal,
+			flag.Scope == flagScopePlayer && player != "" && flag.Subject == player,
+			flag.Scope == flagScopeSession && session != "" && flag.Subject == session:
+			flags = append(flags, flag)
+		}
+	}
+
+	slices.SortFunc(flags, func(a, b FeatureFlag) int { return cmp.Or(cmp.Compare(a.Key, b.Key), cmp.Compare(a.Scope, b.Scope)) })
+	return flags, nil
+}
+
+// GetLeaderboard returns a page of the leaderboard.
+// If the query refers to a player with Around, the page is centered around the player's rank.
+// If the query refers to a session with Subject, the best ranked score of the session is returned as well.
+func (store *memoryStore) GetLeaderboard(query LeaderboardQuery) (Leaderboard, error) {
+	query = query.withDefaults()
+	board := Leaderboard{Entries: make([]LeaderboardEntry, 0), Limit: query.Limit, Offset: query.Offset, Period: query.Period}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	scores := store.sortedScores(query.Since(time.Now()))
+	board.Total = int64(len(scores))
+
+	if query.Around != "" {
+		if i := slices.IndexFunc(scores, func(s Score) bool { return s.Name == query.Around }); i >= 0 {
+			board.Offset = max(i-query.Limit/2, 0)
+		}
+	}
+
+	for i := board.Offset; i < len(scores) && len(board.Entries) < board.Limit; i++ {
+		board.Entries = append(board.Entries, LeaderboardEntry{Score: scores[i], Rank: int64(i + 1)})
+	}
+
+	if query.Subject != "" {
+		if i := slices.IndexFunc(scores, func(s Score) bool { return s.Subject == query.Subject }); i >= 0 {
+			board.Self = &LeaderboardEntry{Score: scores[i], Rank: int64(i + 1)}
+		}
+	}
+
+	return board, nil
+}
+
+// GetMetrics returns the metrics.
+// It returns the metrics sorted by endpoint and method.
+func (store *memoryStore) GetMetrics() ([]Metric, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	metrics := make([]Metric, 0, len(store.metrics))
+	for _, metric := range store.metrics {
+		metrics = append(metrics, metric)
+	}
+
+	slices.SortFunc(metrics, func(a, b Metric) int {
+		return cmp.Or(cmp.Compare(a.Endpoint, b.Endpoint), cmp.Compare(a.Method, b.Method))
+	})
+
+	return metrics, nil
+}
+
+// GetPlayerProfile returns the profile of the player.
+// It aggregates all game runs of the player and returns the most recent runs specified by recentRuns.
+func (store *memoryStore) GetPlayerProfile(name string, recentRuns int) (PlayerProfile, error) {
+	score, err := store.GetScore(name)
+	if err != nil {
+		return PlayerProfile{}, err
+	}
+
+	rank, err := store.GetRank(name)
+	if err != nil {
+		return PlayerProfile{}, err
+	}
+
+	profile := PlayerProfile{
+		Name:              score.Name,
+		BestScore:         score.Score,
+		Rank:              rank,
+		DiscoveredPlanets: make([]string, 0),
+		RecentRuns:        make([]ScoreRun, 0),
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var levels int64
+	discovered := make(map[string]bool)
+	for _, run := range store.runs {
+		if run.PlayerID != score.PlayerID {
+			continue
+		}
+
+		profile.RunCount++
+		levels += run.Level
+		for _, discoveredPlanet := range run.DiscoveredPlanets {
+			discovered[discoveredPlanet] = true
+		}
+	}
+
+	if profile.RunCount > 0 {
+		profile.AverageLevel = float64(levels) / float64(profile.RunCount)
+	}
+
+	for t := planet.PlanetType(0); int(t) < planet.PlanetsCount; t++ {
+		if discovered[t.String()] {
+			profile.DiscoveredPlanets = append(profile.DiscoveredPlanets, t.String())
+		}
+	}
+
+	// The runs are stored in the order of their creation.
+	for i := len(store.runs) - 1; i >= 0 && len(profile.RecentRuns) < recentRuns; i-- {
+		if store.runs[i].PlayerID == score.PlayerID {
+			profile.RecentRuns = append(profile.RecentRuns, store.runs[i])
+		}
+	}
+
+	return profile, nil
+}
+
+// GetRank returns the rank of the player's score.
+// The rank is the position of the score in the leaderboard of all times.
+func (store *memoryStore) GetRank(name string) (int64, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	i := slices.IndexFunc(store.sortedScores(time.Time{}), func(s Score) bool { return s.Name == name })
+	if i < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return int64(i + 1), nil
+}
+
+// GetScore returns the score of the player.
+func (store *memoryStore) GetScore(name string) (Score, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	score, ok := store.scores[store.scoreNames[name]]
+	if !ok {
+		return Score{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return score, nil
+}
+
+// GetScores returns the scores.
+// It returns the scores sorted by score in descending order.
+func (store *memoryStore) GetScores() ([]Score, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.sortedScores(time.Time{}), nil
+}
+
+// GetTableSizes returns the table sizes.
+// The size of a table is approximated by the size of its JSON representation.
+func (store *memoryStore) GetTableSizes() (map[string]Size, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	metrics := make([]Metric, 0, len(store.metrics))
+	for _, metric := range store.metrics {
+		metrics = append(metrics, metric)
+	}
+
+	flags := make([]FeatureFlag, 0, len(store.flags))
+	for _, flag := range store.flags {
+		flags = append(flags, flag)
+	}
+
+	tables := map[string]any{
+		"audit_events":         store.auditEvents,
+		"feature_flag_changes": store.flagChanges,
+		"feature_flags":        flags,
+		"metrics":              metrics,
+		"players":              store.players,
+		"score_runs":           store.runs,
+		"scores":               store.scores,
+		"unverified_runs":      store.unverified,
+	}
+
+	sizes := make(map[string]Size, len(tables))
+	for table, rows := range tables {
+		raw, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		sizes[table] = Size(len(raw))
+	}
+
+	return sizes, nil
+}
+
+// ImportScores merges the scores with the existing ones according to the import mode, or reports the changes in dry-run mode.
+func (store *memoryStore) ImportScores(scores []Score, mode ImportMode, dryRun bool) (ImportReport, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	report := ImportReport{Mode: mode, DryRun: dryRun, Total: len(scores)}
+	now := time.Now()
+	var changed []Score
+	for _, score := range scores {
+		existing, ok := store.scores[store.scoreNames[score.Name]]
+		if saved, change := report.plan(existing, ok, score, now); change {
+			changed = append(changed, saved)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	changed, err := store.reservePlayers(changed)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	if err := store.checkScoreNames(changed); err != nil {
+		return ImportReport{}, err
+	}
+
+	for _, score := range changed {
+		store.scores[score.PlayerID], store.scoreNames[score.Name] = score, score.PlayerID
+	}
+
+	return report, nil
+}
+
+// Ping is a no-op, since the store is always reachable.
+func (store *memoryStore) Ping(context.Context) error { return nil }
+
+// Prune deletes the metrics, the scores and the unverified runs exceeding the retention policy.
+// The runs of the deleted scores are deleted along with them.
+// It returns the number of deleted metrics, scores, runs and unverified runs.
+// In dry-run mode, the rows are counted instead of being deleted.
+func (store *memoryStore) Prune(policy RetentionPolicy) (RetentionReport, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	report := RetentionReport{DryRun: policy.DryRun}
+	if !policy.MetricsUpdatedBefore.IsZero() {
+		for key, metric := range store.metrics {
+			if metric.Timestamp().Before(policy.MetricsUpdatedBefore) {
+				report.Metrics++
+				if !policy.DryRun {
+					delete(store.metrics, key)
+				}
+			}
+		}
+	}
+
+	if policy.KeepTopScores > 0 {
+		scores := store.sortedScores(time.Time{})
+		pruned := make(map[string]string)
+		for _, score := range scores[min(policy.KeepTopScores, len(scores)):] {
+			pruned[score.PlayerID] = score.Name
+		}
+
+		for _, run := range store.runs {
+			if _, ok := pruned[run.PlayerID]; ok {
+				report.Runs++
+			}
+		}
+
+		report.Scores = int64(len(pruned))
+		if !policy.DryRun {
+			for id, name := range pruned {
+				delete(store.scores, id)
+				delete(store.scoreNames, name)
+			}
+			store.runs = slices.DeleteFunc(store.runs, func(run ScoreRun) bool {
+				_, ok := pruned[run.PlayerID]
+				return ok
+			})
+		}
+	}
+
+	if !policy.UnverifiedRunsCreatedBefore.IsZero() {
+		expired := func(run UnverifiedRun) bool { return run.CreatedAt.Before(policy.UnverifiedRunsCreatedBefore) }
+		for _, run := range store.unverified {
+			if expired(run) {
+				report.UnverifiedRuns++
+			}
+		}
+
+		if !policy.DryRun {
+			store.unverified = slices.DeleteFunc(store.unverified, expired)
+		}
+	}
+
+	return report, nil
+}
+
+// SaveAuditEvent appends the event to the audit log.
+func (store *memoryStore) SaveAuditEvent(event AuditEvent) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.auditEventID++
+	event.ID = store.auditEventID
+	store.auditEvents = append(store.auditEvents, event)
+	return nil
+}
+
+// SaveFeatureFlags sets the feature flags, a change without a value unsets the flag.
+// Each change is recorded along with the previous value of the flag.
+// It returns the recorded changes.
+func (store *memoryStore) SaveFeatureFlags(changes []FeatureFlagChange) ([]FeatureFlagChange, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	recorded := slices.Clone(changes)
+	for i := range recorded {
+		change := &recorded[i]
+		target := [3]string{change.Key, change.Scope, change.Subject}
+
+		store.flagChangeID++
+		change.ID, change.ChangedAt, change.Previous = store.flagChangeID, now, nil
+		if existing, ok := store.flags[target]; ok {
+			change.Previous = &existing.Value
+		}
+
+		if change.Value == nil {
+			delete(store.flags, target)
+		} else {
+			store.flags[target] = FeatureFlag{
+				Key:       change.Key,
+				Scope:     change.Scope,
+				Subject:   change.Subject,
+				Value:     *change.Value,
+				UpdatedAt: now,
+				UpdatedBy: change.ChangedBy,
+			}
+		}
+
+		store.flagChanges = append(store.flagChanges, *change)
+	}
+
+	return recorded, nil
+}
+
+// SaveMetric saves the metric.
+// It increments the count if the metric already exists.
+// It updates the updated_at field.
+func (store *memoryStore) SaveMetric(metric Metric) error {
+	return store.SaveMetrics([]Metric{metric})
+}
+
+// SaveMetrics saves the metrics.
+// It increments the counts of the existing metrics by the given counts.
+// It updates the updated_at field.
+func (store *memoryStore) SaveMetrics(metrics []Metric) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	for _, metric := range metrics {
+		key := [2]string{metric.Endpoint, metric.Method}
+		existing, ok := store.metrics[key]
+		if !ok {
+			metric.CreatedAt, metric.UpdatedAt = &now, &now
+			store.metrics[key] = metric
+			continue
+		}
+
+		if existing.Count < math.MaxInt64-metric.Count {
+			existing.Count += metric.Count
+		} else {
+			existing.Count = math.MaxInt64
+		}
+
+		existing.UpdatedAt = &now
+		store.metrics[key] = existing
+	}
+
+	return nil
+}
+
+// SavePlayer saves a new player.
+// It returns ErrConflict if the identifier, the name or the recovery code belongs to another player.
+func (store *memoryStore) SavePlayer(player Player) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, existing := range store.players {
+		if existing.ID == player.ID || existing.Name == player.Name || existing.RecoveryHash == player.RecoveryHash {
+			return fmt.Errorf("%w: player %s", ErrConflict, player.Name)
+		}
+	}
+
+	now := time.Now()
+	player.CreatedAt, player.UpdatedAt = &now, &now
+	store.players[player.ID] = player
+	return nil
+}
+
+// SaveRecoveryHash replaces the hash of the recovery code of the player.
+func (store *memoryStore) SaveRecoveryHash(id, hash string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	player, ok := store.players[id]
+	if !ok {
+		return fmt.Errorf("%w: player", ErrNotFound)
+	}
+
+	now := time.Now()
+	player.RecoveryHash, player.UpdatedAt = hash, &now
+	store.players[id] = player
+	return nil
+}
+
+// SaveRun saves a single game run.
+// It records the run in the history of the player and updates the player's score if the run's level is higher.
+// The subject of the run is the identifier of the player.
+func (store *memoryStore) SaveRun(run ScoreRun) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	scores, err := store.reservePlayers([]Score{{PlayerID: run.Subject, Name: run.Name, Score: run.Level, Subject: run.Subject}})
+	if err != nil {
+		return err
+	}
+
+	if err := store.saveScores(scores); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	store.runID++
+	run.ID, run.CreatedAt, run.PlayerID = store.runID, &now, scores[0].PlayerID
+	store.runs = append(store.runs, run)
+	return nil
+}
+
+// SaveScores saves the scores.
+// It updates the score if the new score is higher.
+// It updates the updated_at field.
+// The scores lacking a player are saved for the player of their name, see reservePlayers.
+func (store *memoryStore) SaveScores(scores []Score) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.saveScores(scores)
+}
+
+// SaveUnverifiedRun saves a game run whose score could not be verified.
+// The run is kept apart from the scores and the history of the player.
+func (store *memoryStore) SaveUnverifiedRun(run UnverifiedRun) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.unverifiedID++
+	run.ID, run.CreatedAt = store.unverifiedID, &now
+	store.unverified = append(store.unverified, run)
+	return nil
+}
+
+// reservePlayers sets the player of the scores lacking one, e.g. imported scores, to the player of their name.
+// The names which have not been claimed yet are reserved for new players.
+// The caller must hold the write lock.
+func (store *memoryStore) reservePlayers(scores []Score) ([]Score, error) {
+	ids := make(map[string]string, len(store.players))
+	for _, player := range store.players {
+		ids[player.Name] = player.ID
+	}
+
+	for i, score := range scores {
+		if score.PlayerID != "" {
+			continue
+		}
+
+		if _, ok := ids[score.Name]; !ok {
+			player, err := newPlayer(score.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			now := time.Now()
+			player.CreatedAt, player.UpdatedAt = &now, &now
+			store.players[player.ID], ids[player.Name] = player, player.ID
+		}
+
+		scores[i].PlayerID = ids[score.Name]
+	}
+
+	return scores, nil
+}
+
+// saveScores saves the scores.
+// The scores of the players with a score update it, keeping its name, like the upsert of the database.
+// The caller must hold the write lock.
+func (store *memoryStore) saveScores(scores []Score) error {
+	scores, err := store.reservePlayers(slices.Clone(scores))
+	if err != nil {
+		return err
+	}
+
+	if err := store.checkScoreNames(scores); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, score := range scores {
+		existing, ok := store.scores[score.PlayerID]
+		switch {
+		case !ok:
+			score.CreatedAt, score.UpdatedAt = &now, &now
+			store.scores[score.PlayerID], store.scoreNames[score.Name] = score, score.PlayerID
+
+		case score.Score > existing.Score:
+			existing.Score = score.Score
+			existing.Subject = selectValue(score.Subject, existing.Subject)
+			existing.UpdatedAt = &now
+			store.scores[score.PlayerID] = existing
+
+		}
+	}
+
+	return nil
+}
+
+// sortedScores returns the best scores of the players achieved since the given time, sorted like the leaderboard.
+// If since is zero, the best scores of all times are returned,
+// otherwise the best run of each player created since the given time.
+// The caller must hold the read lock.
+func (store *memoryStore) sortedScores(since time.Time) []Score {
+	scores := make([]Score, 0, len(store.scores))
+	if since.IsZero() {
+		for _, score := range store.scores {
+			scores = append(scores, score)
+		}
+	} else {
+		best := make(map[string]int)
+		for _, run := range store.runs {
+			if run.CreatedAt == nil || run.CreatedAt.Before(since) {
+				continue
+			}
+
+			// The runs are stored in the order of their creation, hence the earliest best run is kept.
+			score := Score{BaseModel: BaseModel{CreatedAt: run.CreatedAt, UpdatedAt: run.CreatedAt}, PlayerID: run.PlayerID, Name: run.Name, Score: run.Level, Subject: run.Subject}
+			switch i, ok := best[run.PlayerID]; {
+			case !ok:
+				best[run.PlayerID] = len(scores)
+				scores = append(scores, score)
+			case scores[i].Score < score.Score:
+				scores[i] = score
+			}
+		}
+	}
+
+	slices.SortFunc(scores, func(a, b Score) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), a.Timestamp().Compare(b.Timestamp()), cmp.Compare(a.Name, b.Name))
+	})
+
+	return scores
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() Store { return newMemoryStore() }
+
+// newMemoryStore returns an empty in-memory store.
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		flags:      make(map[[3]string]FeatureFlag),
+		metrics:    make(map[[2]string]Metric),
+		players:    make(map[string]Player),
+		scores:     make(map[string]Score),
+		scoreNames: make(map[string]string),
+	}
+}
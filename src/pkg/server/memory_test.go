@@ -0,0 +1,405 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, scores ...Score) *memoryStore {
+	t.Helper()
+
+	store := newMemoryStore()
+	for _, score := range scores {
+		if err := store.SaveScores([]Score{score}); err != nil {
+			t.Fatalf("SaveScores(%v) failed: %v", score, err)
+		}
+	}
+
+	return store
+}
+
+func entryNames(entries []LeaderboardEntry) (names []string) {
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return
+}
+
+func TestMemoryStoreSaveScores(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		args   []Score
+		want   int64
+		wantBy string
+	}{
+		{"test#1", []Score{{Name: "a", Score: 1, Subject: "s1"}}, 1, "s1"},
+		{"test#2", []Score{{Name: "a", Score: 1, Subject: "s1"}, {Name: "a", Score: 2, Subject: "s2"}}, 2, "s2"},
+		{"test#3", []Score{{Name: "a", Score: 2, Subject: "s1"}, {Name: "a", Score: 1, Subject: "s2"}}, 2, "s1"},
+		{"test#4", []Score{{Name: "a", Score: 1, Subject: "s1"}, {Name: "a", Score: 2}}, 2, "s1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestStore(t, tt.args...).GetScore("a")
+			if err != nil {
+				t.Fatalf("GetScore() failed: %v", err)
+			}
+
+			if got.Score != tt.want || got.Subject != tt.wantBy {
+				t.Errorf("GetScore() = (%d, %q), want (%d, %q)", got.Score, got.Subject, tt.want, tt.wantBy)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSaveScoresConflict(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		args      []Score
+		want      Score
+		wantIs    error
+		wantFound bool
+	}{
+		{"test#1", []Score{{PlayerID: "p2", Name: "a", Score: 2}}, Score{PlayerID: "p1", Name: "a", Score: 1}, ErrConflict, false},
+		{"test#2", []Score{{PlayerID: "p1", Name: "a", Score: 2}}, Score{PlayerID: "p1", Name: "a", Score: 2}, nil, false},
+		{"test#3", []Score{{PlayerID: "p1", Name: "b", Score: 2}}, Score{PlayerID: "p1", Name: "a", Score: 2}, nil, false},
+		{"test#4", []Score{{PlayerID: "p2", Name: "b", Score: 2}, {PlayerID: "p3", Name: "b", Score: 3}}, Score{PlayerID: "p1", Name: "a", Score: 1}, ErrConflict, false},
+		{"test#5", []Score{{PlayerID: "p2", Name: "b", Score: 2}}, Score{PlayerID: "p1", Name: "a", Score: 1}, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, Score{PlayerID: "p1", Name: "a", Score: 1})
+
+			if err := store.SaveScores(tt.args); !errors.Is(err, tt.wantIs) {
+				t.Errorf("SaveScores() = %v, want %v", err, tt.wantIs)
+			}
+
+			got, err := store.GetScore("a")
+			if err != nil {
+				t.Fatalf("GetScore() failed: %v", err)
+			}
+
+			if got.PlayerID != tt.want.PlayerID || got.Name != tt.want.Name || got.Score != tt.want.Score {
+				t.Errorf("GetScore() = %+v, want %+v", got, tt.want)
+			}
+
+			if _, err := store.GetScore("b"); (err == nil) != tt.wantFound {
+				t.Errorf("GetScore(b) = %v, want found %t", err, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreGetLeaderboard(t *testing.T) {
+	store := newTestStore(t,
+		Score{Name: "a", Score: 5, Subject: "s1"},
+		Score{Name: "b", Score: 7},
+		Score{Name: "c", Score: 5},
+		Score{Name: "d", Score: 3, Subject: "s1"},
+		Score{Name: "e", Score: 9},
+	)
+
+	for _, tt := range []struct {
+		name      string
+		args      LeaderboardQuery
+		want      []string
+		wantFirst int64
+		wantSelf  string
+	}{
+		{"test#1", LeaderboardQuery{}, []string{"e", "b", "a", "c", "d"}, 1, ""},
+		{"test#2", LeaderboardQuery{Limit: 2, Offset: 1}, []string{"b", "a"}, 2, ""},
+		{"test#3", LeaderboardQuery{Limit: 3, Around: "c"}, []string{"a", "c", "d"}, 3, ""},
+		{"test#4", LeaderboardQuery{Limit: 4, Around: "e"}, []string{"e", "b", "a", "c"}, 1, ""},
+		{"test#5", LeaderboardQuery{Limit: 1, Subject: "s1"}, []string{"e"}, 1, "a"},
+		{"test#6", LeaderboardQuery{Limit: 1, Around: "unknown"}, []string{"e"}, 1, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.GetLeaderboard(tt.args)
+			if err != nil {
+				t.Fatalf("GetLeaderboard() failed: %v", err)
+			}
+
+			if names := entryNames(got.Entries); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("GetLeaderboard() = %v, want %v", names, tt.want)
+			}
+
+			if got.Total != 5 {
+				t.Errorf("GetLeaderboard().Total = %d, want %d", got.Total, 5)
+			}
+
+			if len(got.Entries) > 0 && got.Entries[0].Rank != tt.wantFirst {
+				t.Errorf("GetLeaderboard().Entries[0].Rank = %d, want %d", got.Entries[0].Rank, tt.wantFirst)
+			}
+
+			switch {
+			case tt.wantSelf == "" && got.Self != nil:
+				t.Errorf("GetLeaderboard().Self = %v, want nil", got.Self)
+			case tt.wantSelf != "" && (got.Self == nil || got.Self.Name != tt.wantSelf):
+				t.Errorf("GetLeaderboard().Self = %v, want %q", got.Self, tt.wantSelf)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreGetLeaderboardPeriod(t *testing.T) {
+	store := newMemoryStore()
+	for _, run := range []ScoreRun{
+		{Name: "a", Level: 9, Subject: "p1"},
+		{Name: "a", Level: 2, Subject: "p1"},
+		{Name: "b", Level: 5, Subject: "p2"},
+		{Name: "c", Level: 7, Subject: "p3"},
+	} {
+		if err := store.SaveRun(run); err != nil {
+			t.Fatalf("SaveRun(%v) failed: %v", run, err)
+		}
+	}
+
+	// The best run of a dates back three days, the only run of c eight days.
+	threeDaysAgo, eightDaysAgo := time.Now().AddDate(0, 0, -3), time.Now().AddDate(0, 0, -8)
+	store.runs[0].CreatedAt, store.runs[3].CreatedAt = &threeDaysAgo, &eightDaysAgo
+
+	for _, tt := range []struct {
+		name       string
+		args       string
+		want       []string
+		wantScores []int64
+	}{
+		{"test#1", "daily", []string{"b", "a"}, []int64{5, 2}},
+		{"test#2", "weekly", []string{"a", "b"}, []int64{9, 5}},
+		{"test#3", "all", []string{"a", "c", "b"}, []int64{9, 7, 5}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.GetLeaderboard(LeaderboardQuery{Period: tt.args})
+			if err != nil {
+				t.Fatalf("GetLeaderboard() failed: %v", err)
+			}
+
+			if names := entryNames(got.Entries); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("GetLeaderboard() = %v, want %v", names, tt.want)
+			}
+
+			var scores []int64
+			for _, entry := range got.Entries {
+				scores = append(scores, entry.Score.Score)
+			}
+
+			if !reflect.DeepEqual(scores, tt.wantScores) {
+				t.Errorf("GetLeaderboard() scores = %v, want %v", scores, tt.wantScores)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreGetPlayerProfile(t *testing.T) {
+	store := newMemoryStore()
+	for _, run := range []ScoreRun{
+		{Name: "a", Level: 2, DiscoveredPlanets: []string{"Venus"}},
+		{Name: "b", Level: 5},
+		{Name: "a", Level: 4, DiscoveredPlanets: []string{"Mercury", "Venus"}},
+		{Name: "a", Level: 3},
+	} {
+		if err := store.SaveRun(run); err != nil {
+			t.Fatalf("SaveRun(%v) failed: %v", run, err)
+		}
+	}
+
+	got, err := store.GetPlayerProfile("a", 2)
+	if err != nil {
+		t.Fatalf("GetPlayerProfile() failed: %v", err)
+	}
+
+	if got.BestScore != 4 || got.Rank != 2 || got.RunCount != 3 || got.AverageLevel != 3 {
+		t.Errorf("GetPlayerProfile() = %+v, want best score 4, rank 2, 3 runs and average level 3", got)
+	}
+
+	if want := []string{"Mercury", "Venus"}; !reflect.DeepEqual(got.DiscoveredPlanets, want) {
+		t.Errorf("GetPlayerProfile().DiscoveredPlanets = %v, want %v", got.DiscoveredPlanets, want)
+	}
+
+	if len(got.RecentRuns) != 2 || got.RecentRuns[0].Level != 3 || got.RecentRuns[1].Level != 4 {
+		t.Errorf("GetPlayerProfile().RecentRuns = %v, want the runs of level 3 and 4", got.RecentRuns)
+	}
+
+	if _, err := store.GetPlayerProfile("unknown", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPlayerProfile() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	store := newMemoryStore()
+	for _, run := range []ScoreRun{{Name: "a", Level: 1}, {Name: "b", Level: 3}, {Name: "c", Level: 2}} {
+		if err := store.SaveRun(run); err != nil {
+			t.Fatalf("SaveRun(%v) failed: %v", run, err)
+		}
+	}
+
+	if report, err := store.Prune(RetentionPolicy{KeepTopScores: 2}); err != nil || report.Scores != 1 || report.Runs != 1 {
+		t.Fatalf("Prune() = (%+v, %v), want 1 score and 1 run deleted", report, err)
+	}
+
+	scores, _ := store.GetScores()
+	if len(scores) != 2 || scores[0].Name != "b" || scores[1].Name != "c" {
+		t.Errorf("GetScores() = %v, want b and c", scores)
+	}
+
+	if len(store.runs) != 2 {
+		t.Errorf("len(runs) = %d, want %d", len(store.runs), 2)
+	}
+}
+
+func TestMemoryStoreSaveMetric(t *testing.T) {
+	store := newMemoryStore()
+	for _, metric := range []Metric{
+		{Endpoint: "/b", Method: "GET", Count: 1},
+		{Endpoint: "/a", Method: "GET", Count: 1},
+		{Endpoint: "/b", Method: "GET", Count: 1},
+	} {
+		if err := store.SaveMetric(metric); err != nil {
+			t.Fatalf("SaveMetric(%v) failed: %v", metric, err)
+		}
+	}
+
+	metrics, _ := store.GetMetrics()
+	if len(metrics) != 2 || metrics[0].Endpoint != "/a" || metrics[1].Count != 2 {
+		t.Errorf("GetMetrics() = %v, want /a counted once and /b counted twice", metrics)
+	}
+}
+
+func TestMemoryStoreImportScores(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		mode   ImportMode
+		dryRun bool
+		want   map[string]int64
+		report [3]int // report counts the created, updated and unchanged scores.
+	}{
+		{"test#1", ImportKeepHigher, false, map[string]int64{"a": 5, "b": 4, "c": 1}, [3]int{1, 1, 1}},
+		{"test#2", ImportOverwrite, false, map[string]int64{"a": 2, "b": 4, "c": 1}, [3]int{1, 2, 0}},
+		{"test#3", ImportSkipExisting, false, map[string]int64{"a": 5, "b": 3, "c": 1}, [3]int{1, 0, 2}},
+		{"test#4", ImportOverwrite, true, map[string]int64{"a": 5, "b": 3}, [3]int{1, 2, 0}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, Score{Name: "a", Score: 5}, Score{Name: "b", Score: 3})
+			report, err := store.ImportScores([]Score{{Name: "a", Score: 2}, {Name: "b", Score: 4}, {Name: "c", Score: 1}}, tt.mode, tt.dryRun)
+			if err != nil {
+				t.Fatalf("ImportScores() failed: %v", err)
+			}
+
+			if got := [3]int{report.Created, report.Updated, report.Unchanged}; got != tt.report || report.Total != 3 || len(report.Changes) != got[0]+got[1] {
+				t.Errorf("ImportScores() = %+v, want %v created, updated and unchanged", report, tt.report)
+			}
+
+			scores, _ := store.GetScores()
+			got := make(map[string]int64, len(scores))
+			for _, score := range scores {
+				got[score.Name] = score.Score
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetScores() = %v, want %v", got, tt.want)
+			}
+
+			if _, err := store.FindPlayer(PlayerQuery{Name: "c"}); (err == nil) == tt.dryRun {
+				t.Errorf("FindPlayer(c) = %v, want the name of the imported score reserved %t", err, !tt.dryRun)
+			}
+		})
+	}
+}
+
+func TestMemoryStorePlayers(t *testing.T) {
+	store := newTestStore(t, Score{Name: "c", Score: 1})
+	if err := store.SavePlayer(Player{ID: "p1", Name: "a", RecoveryHash: "h1"}); err != nil {
+		t.Fatalf("SavePlayer() failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args Player
+		want error
+	}{
+		{"test#1", Player{ID: "p1", Name: "b", RecoveryHash: "h2"}, ErrConflict},
+		{"test#2", Player{ID: "p2", Name: "a", RecoveryHash: "h2"}, ErrConflict},
+		{"test#3", Player{ID: "p2", Name: "b", RecoveryHash: "h1"}, ErrConflict},
+		{"test#4", Player{ID: "p2", Name: "c", RecoveryHash: "h2"}, ErrConflict},
+		{"test#5", Player{ID: "p2", Name: "b", RecoveryHash: "h2"}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.SavePlayer(tt.args); !errors.Is(err, tt.want) {
+				t.Errorf("SavePlayer(%+v) = %v, want %v", tt.args, err, tt.want)
+			}
+		})
+	}
+
+	if err := store.SaveRecoveryHash("p2", "h3"); err != nil {
+		t.Fatalf("SaveRecoveryHash() failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		args    PlayerQuery
+		want    string
+		wantErr error
+	}{
+		{"test#6", PlayerQuery{ID: "p1"}, "a", nil},
+		{"test#7", PlayerQuery{Name: "b"}, "b", nil},
+		{"test#8", PlayerQuery{RecoveryHash: "h3"}, "b", nil},
+		{"test#9", PlayerQuery{RecoveryHash: "h2"}, "", ErrNotFound},
+		{"test#10", PlayerQuery{ID: "p1", Name: "b"}, "", ErrNotFound},
+		{"test#11", PlayerQuery{}, "", ErrNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.FindPlayer(tt.args)
+			if !errors.Is(err, tt.wantErr) || got.Name != tt.want {
+				t.Errorf("FindPlayer(%+v) = (%+v, %v), want (%q, %v)", tt.args, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// The scores are saved for the player of their name.
+	if err := store.SaveRun(ScoreRun{Name: "a", Level: 2, Subject: "p1"}); err != nil {
+		t.Fatalf("SaveRun() failed: %v", err)
+	}
+
+	if err := store.SaveScores([]Score{{Name: "b", Score: 3}}); err != nil {
+		t.Fatalf("SaveScores() failed: %v", err)
+	}
+
+	scores, _ := store.GetScores()
+	got := make(map[string]string, len(scores))
+	for _, score := range scores {
+		got[score.Name] = score.PlayerID
+	}
+
+	if reserved, _ := store.FindPlayer(PlayerQuery{Name: "c"}); got["a"] != "p1" || got["b"] != "p2" || got["c"] == "" || got["c"] != reserved.ID {
+		t.Errorf("GetScores() saved the scores for the players %v, want a, b and the reserved player of c", got)
+	}
+
+	// The runs are saved for the player of their score.
+	if profile, err := store.GetPlayerProfile("a", 10); err != nil || len(profile.RecentRuns) != 1 || profile.RecentRuns[0].PlayerID != "p1" {
+		t.Errorf("GetPlayerProfile() = (%+v, %v), want a run of p1", profile, err)
+	}
+}
+
+func TestMemoryStoreExportScores(t *testing.T) {
+	store := newTestStore(t, Score{Name: "c", Score: 1}, Score{Name: "a", Score: 2}, Score{Name: "b", Score: 3})
+
+	var batches [][]string
+	if err := store.ExportScores(2, func(scores []Score) error {
+		var names []string
+		for _, score := range scores {
+			names = append(names, score.Name)
+		}
+		batches = append(batches, names)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportScores() failed: %v", err)
+	}
+
+	if want := [][]string{{"a", "b"}, {"c"}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("ExportScores() yielded %v, want %v", batches, want)
+	}
+
+	stop := errors.New("stop")
+	if err := store.ExportScores(1, func([]Score) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("ExportScores() = %v, want the error of yield", err)
+	}
+}
@@ -1,25 +1,22 @@
-package main
+package server
 
 import (
-	"crypto/cipher"
 	"crypto/rand"
-	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	cors "github.com/gin-contrib/cors"
+	gzip "github.com/gin-contrib/gzip"
 	gin "github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v5"
 	dist "github.com/sarumaj/edu-space-invaders/dist"
 	zap "go.uber.org/zap"
 	zapcore "go.uber.org/zap/zapcore"
-	rate "golang.org/x/time/rate"
-	gorm "gorm.io/gorm"
 )
 
 // nopWriter is a writer that does not write anything.
@@ -51,7 +48,7 @@ func ApplySecurityHeadersMiddleware(enabled bool) gin.HandlerFunc {
 		// Generate a random nonce
 		nonce, err := generateNonce()
 		if err != nil {
-			logger.Error("Failed to generate nonce", zap.Error(err))
+			getLogger(ctx).Error("Failed to generate nonce", zap.Error(err))
 			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to generate nonce"})
 			return
 		}
@@ -87,40 +84,58 @@ func ApplySecurityHeadersMiddleware(enabled bool) gin.HandlerFunc {
 }
 
 // AuthenticatorMiddleware is a middleware that authenticates the request using JWT.
-// It uses the public key to verify the JWT token.
+// It uses the key set to verify the JWT token and to decrypt the session cookie.
 // The sources map contains the sources of the JWT token.
 // The key is the source and the value is the key.
 // The sources can be "cookie", "header", or "query".
 // The key is the name of the cookie, header, or query parameter.
 // If the token is not found, the middleware will return a 401 status code.
 // If the token is invalid, the middleware will return a 401 status code.
-func AuthenticatorMiddleware(publicKey *rsa.PublicKey, cryptKey cipher.AEAD, sources map[string]string) gin.HandlerFunc {
-	parser := jwt.NewParser(
-		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
-		jwt.WithIssuer("space-invaders"),
-		jwt.WithAudience("space-invaders"),
-		jwt.WithIssuedAt(),
-		jwt.WithPaddingAllowed(),
-	)
-
-	validatorFunc := func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing algorithm: %s", token.Method.Alg())
+func AuthenticatorMiddleware(keys *KeySet, sources map[string]string) gin.HandlerFunc {
+	authenticate := newAuthenticator(keys, sources)
+
+	return func(ctx *gin.Context) {
+		claims, err := authenticate(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
 		}
 
-		return publicKey, nil
+		ctx.Set("claims", claims)
+		ctx.Next()
 	}
+}
+
+// OptionalAuthenticatorMiddleware is a middleware that authenticates the request using JWT, if possible.
+// It works like AuthenticatorMiddleware, but it does not reject unauthenticated requests.
+// The claims are available to the subsequent handlers only if the token is valid.
+func OptionalAuthenticatorMiddleware(keys *KeySet, sources map[string]string) gin.HandlerFunc {
+	authenticate := newAuthenticator(keys, sources)
 
 	return func(ctx *gin.Context) {
+		if claims, err := authenticate(ctx); err == nil {
+			ctx.Set("claims", claims)
+		}
+
+		ctx.Next()
+	}
+}
+
+// newAuthenticator returns a function that extracts and verifies the JWT token of the request.
+// It returns the claims of the token or an error if the token is missing or invalid.
+func newAuthenticator(keys *KeySet, sources map[string]string) func(*gin.Context) (*Claims, error) {
+	parseToken := TokenParser(keys)
+
+	return func(ctx *gin.Context) (*Claims, error) {
 		var jwtToken string
 		for source, key := range sources {
 			switch source {
 			case "cookie":
 				if cookie, _ := ctx.Request.Cookie(key); cookie != nil && cookie.Valid() == nil {
 					var err error
-					jwtToken, err = decodeB64AndDecryptWithAES(cryptKey, cookie.Value)
+					jwtToken, err = keys.Decrypt(cookie.Value)
 					if err != nil {
-						logger.Error("Failed to decrypt cookie", zap.String("source", source+":"+key), zap.Error(err))
+						getLogger(ctx).Error("Failed to decrypt cookie", zap.String("source", source+":"+key), zap.Error(err))
 					}
 				}
 
@@ -131,29 +146,21 @@ func AuthenticatorMiddleware(publicKey *rsa.PublicKey, cryptKey cipher.AEAD, sou
 				jwtToken = ctx.Query(key)
 
 			default:
-				logger.Warn("Unknown source", zap.String("source", source))
+				getLogger(ctx).Warn("Unknown source", zap.String("source", source))
 
 			}
 
 			if jwtToken != "" {
-				logger.Debug("Found JWT token", zap.String("source", source+":"+key), zap.String("token", jwtToken))
+				getLogger(ctx).Debug("Found JWT token", zap.String("source", source+":"+key), zap.String("token", jwtToken))
 				break
 			}
 		}
 
 		if jwtToken == "" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
-			return
-		}
-
-		token, err := parser.Parse(jwtToken, validatorFunc)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			return
+			return nil, fmt.Errorf("missing token")
 		}
 
-		ctx.Set("claims", token.Claims)
-		ctx.Next()
+		return parseToken(jwtToken)
 	}
 }
 
@@ -177,32 +184,51 @@ func BeHeadMiddleware() gin.HandlerFunc {
 }
 
 // CacheControlMiddleware is a middleware that sets the cache control headers.
-// It also handles the ETag header.
+// It also handles the ETag header, which is the hash of the precompressed variant served to the client, if any.
+// The assets requested by the content-addressed URL of their current version are cached for a year without revalidation.
 func CacheControlMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		path := strings.Trim(ctx.Request.URL.Path, "/")
-		if path == "/" {
-			path = defaultEndpoint
+		path := assetName(ctx.Request.URL.Path)
+		if hasVariants(path, assetExists) {
+			ctx.Header("Vary", "Accept-Encoding")
+			path, _ = selectVariant(ctx.GetHeader("Accept-Encoding"), path, assetExists)
 		}
 
 		fields := []zapcore.Field{zap.String("path", path)}
 		if eTag, ok := dist.LookupHash(path); ok {
 			fields = append(fields, zap.String("eTag", eTag))
 			ctx.Header("Cache-Control", "public, must-revalidate")
+			if isImmutable(ctx.Request.URL.Path) {
+				ctx.Header("Cache-Control", immutableCacheControl)
+			}
 			ctx.Header("ETag", eTag)
 
 			if strings.Contains(ctx.GetHeader("If-None-Match"), eTag) {
-				logger.Debug("ETag matched", fields...)
-				ctx.Status(http.StatusNotModified)
+				getLogger(ctx).Debug("ETag matched", fields...)
+				ctx.AbortWithStatus(http.StatusNotModified)
 				return
 			}
 		}
 
-		logger.Debug("ETag not matched", fields...)
+		getLogger(ctx).Debug("ETag not matched", fields...)
 		ctx.Next()
 	}
 }
 
+// CompressionMiddleware is a middleware that compresses the responses with gzip,
+// except for the static assets having precompressed variants, which are served by ServeFileSystem.
+func CompressionMiddleware(level int, excludedPaths ...string) gin.HandlerFunc {
+	compress := gzip.Gzip(level, gzip.WithExcludedPaths(excludedPaths))
+	return func(ctx *gin.Context) {
+		if hasVariants(assetName(ctx.Request.URL.Path), assetExists) {
+			ctx.Next()
+			return
+		}
+
+		compress(ctx)
+	}
+}
+
 // CrossOriginResourceSharing is a middleware that handles Cross Origin Resource Sharing (CORS).
 func CrossOriginResourceSharingMiddleware(enabled bool) gin.HandlerFunc {
 	if !enabled {
@@ -240,7 +266,7 @@ func HttpsRedirectMiddleware(enabled bool) gin.HandlerFunc {
 				OmitHost:    false,
 			}
 
-			logger.Debug("Redirecting to HTTPS", zap.String("location", location.String()))
+			getLogger(ctx).Debug("Redirecting to HTTPS", zap.String("location", location.String()))
 			ctx.Redirect(http.StatusMovedPermanently, location.String())
 			return
 		}
@@ -249,12 +275,12 @@ func HttpsRedirectMiddleware(enabled bool) gin.HandlerFunc {
 	}
 }
 
-// LimitMiddleware is a middleware that limits the number of requests per second.
-// It uses a token bucket algorithm to limit the number of requests.
-// The rate is the number of requests per second and the bursts is the number of requests that can be bursted.
-// If the limit is reached, the middleware will return a 429 status code.
-func LimitMiddleware(rps float64, bursts uint, skip gin.Skipper) gin.HandlerFunc {
-	limiter := rate.NewLimiter(rate.Limit(rps), int(bursts))
+// LimitMiddleware is a middleware that limits the number of requests per second of each client.
+// It uses a token bucket per client and group of routes, the group and the client are determined by the given functions.
+// The state of the token bucket is reported by the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// If the limit is reached, the middleware will return a 429 status code with the Retry-After header in seconds.
+func LimitMiddleware(limiter *rateLimiter, group func(*gin.Context) string, client func(*gin.Context, string) string, skip gin.Skipper) gin.HandlerFunc {
+	seconds := func(d time.Duration) string { return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10) }
 
 	return func(ctx *gin.Context) {
 		if skip != nil && skip(ctx) {
@@ -262,13 +288,20 @@ func LimitMiddleware(rps float64, bursts uint, skip gin.Skipper) gin.HandlerFunc
 			return
 		}
 
-		now := time.Now()
-		reservation := limiter.ReserveN(now, 1)
-		defer reservation.Cancel()
+		name := group(ctx)
+		status, limited := limiter.Allow(name, client(ctx, name), time.Now())
+		if !limited {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(status.Limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		ctx.Header("RateLimit-Reset", seconds(status.Reset))
 
-		if delay := reservation.DelayFrom(now); delay > 0 {
-			logger.Debug("Rate limit exceeded", zap.Duration("delay", delay))
-			ctx.Header("Retry-After", now.Add(delay).Format(time.RFC1123))
+		if !status.Allowed {
+			getLogger(ctx).Debug("Rate limit exceeded", zap.String("group", name), zap.Duration("retryAfter", status.RetryAfter))
+			ctx.Header("Retry-After", seconds(status.RetryAfter))
 			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
 			return
 		}
@@ -277,10 +310,17 @@ func LimitMiddleware(rps float64, bursts uint, skip gin.Skipper) gin.HandlerFunc
 	}
 }
 
-// MetricsMiddleware is a middleware that logs metrics.
-func MetricsMiddleware(database *gorm.DB, skip gin.Skipper) gin.HandlerFunc {
-	queueLock := sync.Mutex{}
+// LoggerMiddleware is a middleware that provides the logger to the handlers of the request, see getLogger.
+func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("logger", logger)
+		ctx.Next()
+	}
+}
 
+// MetricsMiddleware is a middleware that counts the requests per endpoint and method.
+// The counts are saved to the store by the aggregator, so that the requests do not wait for the database.
+func MetricsMiddleware(aggregator *metricAggregator, skip gin.Skipper) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Next()
 
@@ -288,66 +328,69 @@ func MetricsMiddleware(database *gorm.DB, skip gin.Skipper) gin.HandlerFunc {
 			return
 		}
 
-		queueLock.Lock()
-		defer queueLock.Unlock()
+		aggregator.Record(ctx.Request.URL.Path, ctx.Request.Method)
+	}
+}
 
-		fields := []zapcore.Field{zap.String("endpoint", ctx.Request.URL.Path), zap.String("method", ctx.Request.Method)}
-		if err := Helper(database).SaveMetric(Metric{
-			Endpoint: ctx.Request.URL.Path,
-			Method:   ctx.Request.Method,
-			Count:    1,
-		}); err != nil {
-			logger.Error("Failed to save metrics", append(fields, zap.Error(err))...)
-			return
+// RequireScopesMiddleware is a middleware that authorizes the request using the scopes of the JWT claims.
+// It must be preceded by the AuthenticatorMiddleware.
+// If any of the given scopes is not granted, the middleware will return a 403 status code naming the missing scope.
+func RequireScopesMiddleware(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims := getClaims(ctx)
+		for _, scope := range scopes {
+			if claims == nil || !claims.HasScope(scope) {
+				getLogger(ctx).Debug("Missing scope", zap.String("scope", scope), zap.String("subject", getSubject(ctx)))
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("missing scope: %s", scope), "scope": scope})
+				return
+			}
 		}
 
-		logger.Debug("Metrics saved", fields...)
+		ctx.Next()
 	}
 }
 
 // SessionMiddleware is a middleware that creates a session cookie.
-// It uses the private key to sign the JWT token.
+// It uses the most recent keys of the key set to sign the JWT token and to encrypt the cookie.
 // The session name is the name of the cookie.
 // The session duration is the duration of the session.
 // If the cookie is not found or invalid, the middleware will create a new session.
+// Each session is identified by a random subject and is granted the player scope only.
+// The subject is the identifier of the player, hence a session expiring within half of its duration is renewed for the same subject.
 // If the token is invalid, the middleware will return a 500 status code.
-func SessionMiddleware(privateKey *rsa.PrivateKey, cryptKey cipher.AEAD, sessionName string, sessionDuration time.Duration) gin.HandlerFunc {
+func SessionMiddleware(keys *KeySet, sessionName string, sessionDuration time.Duration) gin.HandlerFunc {
+	parseToken := TokenParser(keys)
+
 	return func(ctx *gin.Context) {
+		var subject string
 		if cookie, _ := ctx.Request.Cookie(sessionName); cookie != nil && cookie.Valid() == nil {
-			ctx.Next()
-			return
+			if jwtToken, err := keys.Decrypt(cookie.Value); err == nil {
+				if claims, err := parseToken(jwtToken); err == nil {
+					if claims.ExpiresAt == nil || time.Until(claims.ExpiresAt.Time) > sessionDuration/2 {
+						ctx.Next()
+						return
+					}
+
+					subject = claims.Subject
+				}
+			}
 		}
 
-		now := time.Now()
-		jwtToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
-			Issuer:    "space-invaders",
-			Audience:  jwt.ClaimStrings{"space-invaders"},
-			IssuedAt:  jwt.NewNumericDate(now),
-			Subject:   "internal",
-			ExpiresAt: jwt.NewNumericDate(now.Add(sessionDuration)),
-		}).SignedString(privateKey)
-		if err != nil {
-			logger.Error("Failed to sign token", zap.Error(err))
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to sign token"})
-			return
+		if subject == "" {
+			sessionID, err := newSessionID()
+			if err != nil {
+				getLogger(ctx).Error("Failed to generate session ID", zap.Error(err))
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to generate session ID"})
+				return
+			}
+
+			subject = sessionID
 		}
 
-		encrypted, err := encryptAndEncodeB64WithAES(cryptKey, jwtToken)
-		if err != nil {
-			logger.Error("Failed to encrypt token", zap.Error(err))
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to encrypt token"})
+		if err := setSessionCookie(ctx, keys, sessionName, subject, sessionDuration); err != nil {
+			getLogger(ctx).Error("Failed to issue session", zap.Error(err))
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to issue session"})
 			return
 		}
-
-		http.SetCookie(ctx.Writer, &http.Cookie{
-			Name:     sessionName,
-			Value:    encrypted,
-			MaxAge:   int((sessionDuration - time.Since(now)).Seconds()),
-			Path:     "/",
-			Domain:   selectValue(ctx.GetHeader("X-Forwarded-Host"), ctx.Request.URL.Hostname()),
-			Secure:   selectValue(ctx.GetHeader("X-Forwarded-Proto"), ctx.Request.URL.Scheme) == "https",
-			HttpOnly: true,
-			SameSite: http.SameSiteLaxMode,
-		})
 	}
 }
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func newTestToken(t *testing.T, keys *KeySet, scope string) string {
+	t.Helper()
+
+	token, err := IssueToken(keys, "test", time.Minute, scope)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return token
+}
+
+func TestRequireScopesMiddleware(t *testing.T) {
+	keys := newTestKeySet(t, "test")
+	authenticator := AuthenticatorMiddleware(keys, map[string]string{"header": "Authorization", "cookie": "session"})
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+
+	router := gin.New()
+	router.Use(SessionMiddleware(keys, "session", time.Hour))
+	router.GET("/session", ok)
+	router.GET("/player", authenticator, RequireScopesMiddleware(ScopePlayer), ok)
+	router.POST("/config", authenticator, RequireScopesMiddleware(ScopeConfigWrite), ok)
+
+	// Obtain a session cookie.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/session", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("GET /session returned %d cookies, want 1", len(cookies))
+	}
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		target string
+		token  string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"test#1", http.MethodGet, "/player", "", nil, http.StatusUnauthorized},
+		{"test#2", http.MethodGet, "/player", "", cookies[0], http.StatusOK},
+		{"test#3", http.MethodPost, "/config", "", cookies[0], http.StatusForbidden},
+		{"test#4", http.MethodPost, "/config", newTestToken(t, keys, ScopePlayer), nil, http.StatusForbidden},
+		{"test#5", http.MethodPost, "/config", newTestToken(t, keys, ScopeConfigWrite), nil, http.StatusOK},
+		{"test#6", http.MethodPost, "/config", newTestToken(t, keys, ScopeAdmin), nil, http.StatusOK},
+		{"test#7", http.MethodGet, "/player", newTestToken(t, keys, ""), nil, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	keys := newTestKeySet(t, "test")
+	parseToken := TokenParser(keys)
+
+	router := gin.New()
+	router.Use(SessionMiddleware(keys, "session", time.Hour))
+	router.GET("/session", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	newCookie := func(subject string, ttl time.Duration) *http.Cookie {
+		token, err := IssueToken(keys, subject, ttl, ScopePlayer)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+
+		encrypted, err := keys.Encrypt(token)
+		if err != nil {
+			t.Fatalf("failed to encrypt token: %v", err)
+		}
+
+		return &http.Cookie{Name: "session", Value: encrypted}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		cookie      *http.Cookie
+		wantCookie  bool
+		wantSubject string // wantSubject is the subject of the issued session, any subject if empty.
+	}{
+		{"test#1", nil, true, ""},
+		{"test#2", newCookie("p1", time.Hour), false, ""},
+		{"test#3", newCookie("p1", 20*time.Minute), true, "p1"},
+		{"test#4", newCookie("p1", 0), false, ""},
+		{"test#5", &http.Cookie{Name: "session", Value: "invalid"}, true, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/session", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			cookies := rec.Result().Cookies()
+			if (len(cookies) == 1) != tt.wantCookie {
+				t.Fatalf("GET /session set %d cookies, want cookie %t", len(cookies), tt.wantCookie)
+			}
+
+			if !tt.wantCookie {
+				return
+			}
+
+			token, err := keys.Decrypt(cookies[0].Value)
+			if err != nil {
+				t.Fatalf("failed to decrypt cookie: %v", err)
+			}
+
+			claims, err := parseToken(token)
+			if err != nil {
+				t.Fatalf("failed to parse token: %v", err)
+			}
+
+			switch {
+			case tt.wantSubject != "" && claims.Subject != tt.wantSubject,
+				tt.wantSubject == "" && (claims.Subject == "" || claims.Subject == "p1"),
+				time.Until(claims.ExpiresAt.Time) < 59*time.Minute:
+				t.Errorf("GET /session issued the session %+v, want subject %q renewed for an hour", claims, tt.wantSubject)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package server
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	zap "go.uber.org/zap"
+	gorm "gorm.io/gorm"
+)
+
+// migrationLock is the key of the advisory lock serializing the migrations of concurrent replicas.
+const migrationLock = 0x5ace_1a7e
+
+// schemaMigrationsTable creates the table recording the applied migrations.
+const schemaMigrationsTable = `CREATE TABLE IF NOT EXISTS "schema_migrations" (` +
+	`"version" bigint PRIMARY KEY, "name" text NOT NULL, "applied_at" timestamptz NOT NULL DEFAULT now())`
+
+// migrationFiles are the numbered migrations, named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationPattern matches the file names of the migrations.
+var migrationPattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// embeddedMigrations returns the migrations embedded in the binary, sorted by version.
+var embeddedMigrations = sync.OnceValues(func() ([]migration, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return loadMigrations(files)
+})
+
+// migration is a numbered change of the database schema.
+type migration struct {
+	Version uint
+	Name    string
+	Up      string // Up is the SQL applying the migration.
+	Down    string // Down is the SQL reverting the migration.
+}
+
+// MigrationStatus represents the state of a migration in the database.
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"` // AppliedAt is nil if the migration is pending.
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName returns the name of the table recording the applied migrations.
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// schemaMigrator applies and reverts the migrations of the Postgres database.
+// Each migration runs in a transaction together with its record in the schema_migrations table.
+type schemaMigrator struct {
+	database   *gorm.DB
+	migrations []migration
+	logger     *zap.Logger
+}
+
+// Applied returns the applied migrations, sorted by version.
+func (m *schemaMigrator) Applied() ([]SchemaMigration, error) {
+	if !m.database.Migrator().HasTable(&SchemaMigration{}) {
+		return nil, nil
+	}
+
+	var applied []SchemaMigration
+	if err := m.database.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Down reverts the given number of the most recently applied migrations.
+// It returns the reverted migrations.
+func (m *schemaMigrator) Down(steps int) ([]migration, error) {
+	if err := m.database.Exec(schemaMigrationsTable).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var reverted []migration
+	for ; steps > 0; steps-- {
+		var target *migration
+		err := m.database.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+				return err
+			}
+
+			var latest SchemaMigration
+			switch err := tx.Order("version DESC").Take(&latest).Error; {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return nil
+			case err != nil:
+				return err
+			}
+
+			index := slices.IndexFunc(m.migrations, func(candidate migration) bool { return candidate.Version == latest.Version })
+			if index < 0 {
+				return fmt.Errorf("migration %d is unknown to this binary", latest.Version)
+			}
+
+			target = &m.migrations[index]
+			if err := tx.Exec(target.Down).Error; err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", target.Version, target.Name, err)
+			}
+
+			return tx.Delete(&latest).Error
+		})
+
+		switch {
+		case err != nil:
+			return reverted, err
+		case target == nil:
+			return reverted, nil
+		}
+
+		m.logger.Info("Reverted migration", zap.Uint("version", target.Version), zap.String("name", target.Name))
+		reverted = append(reverted, *target)
+	}
+
+	return reverted, nil
+}
+
+// Latest returns the version of the most recent migration known to the binary.
+func (m *schemaMigrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns the state of the migrations known to the binary and of the applied migrations unknown to it.
+func (m *schemaMigrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status = append(status, MigrationStatus{Version: migration.Version, Name: migration.Name})
+	}
+
+	for _, record := range applied {
+		index := slices.IndexFunc(status, func(candidate MigrationStatus) bool { return candidate.Version == record.Version })
+		if index < 0 {
+			index = len(status)
+			status = append(status, MigrationStatus{Version: record.Version, Name: record.Name})
+		}
+		status[index].AppliedAt = &record.AppliedAt
+	}
+
+	slices.SortFunc(status, func(a, b MigrationStatus) int { return int(a.Version) - int(b.Version) })
+	return status, nil
+}
+
+// Up applies the pending migrations up to the target version, all of them if the target is zero.
+// It returns the applied migrations.
+func (m *schemaMigrator) Up(target uint) ([]migration, error) {
+	if err := m.database.Exec(schemaMigrationsTable).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []migration
+	for _, migration := range m.migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
+
+		done := false
+		err := m.database.Transaction(func(tx *gorm.DB) error {
+			// Another replica may have applied the migration while waiting for the lock.
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+
+			if done = count > 0; done {
+				return nil
+			}
+
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+
+		switch {
+		case err != nil:
+			return applied, err
+		case done:
+			continue
+		}
+
+		m.logger.Info("Applied migration", zap.Uint("version", migration.Version), zap.String("name", migration.Name))
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Version returns the version of the most recently applied migration, zero if none has been applied.
+func (m *schemaMigrator) Version() (uint, error) {
+	applied, err := m.Applied()
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+
+	return applied[len(applied)-1].Version, nil
+}
+
+// MigrateDown reverts the given number of the most recently applied migrations of the database given by the configuration.
+// It returns the status of the migrations, even if the migration failed part way.
+func MigrateDown(config DatabaseConfig, steps int, logger *zap.Logger) ([]MigrationStatus, error) {
+	return runMigrator(config, logger, func(migrator *schemaMigrator) error {
+		_, err := migrator.Down(steps)
+		return err
+	})
+}
+
+// MigrateUp applies the pending migrations of the database given by the configuration up to the target version,
+// all of them if the target is zero. It returns the status of the migrations, even if the migration failed part way.
+func MigrateUp(config DatabaseConfig, target uint, logger *zap.Logger) ([]MigrationStatus, error) {
+	return runMigrator(config, logger, func(migrator *schemaMigrator) error {
+		_, err := migrator.Up(target)
+		return err
+	})
+}
+
+// MigrationStatuses returns the status of the migrations of the database given by the configuration.
+func MigrationStatuses(config DatabaseConfig) ([]MigrationStatus, error) {
+	return runMigrator(config, zap.NewNop(), func(*schemaMigrator) error { return nil })
+}
+
+// SchemaMigrator returns the migrator of the database with the migrations embedded in the binary.
+// The applied and reverted migrations are logged by the logger.
+func SchemaMigrator(database *gorm.DB, logger *zap.Logger) (*schemaMigrator, error) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &schemaMigrator{database: database, migrations: migrations, logger: logger}, nil
+}
+
+// loadMigrations loads the migrations from the file system, sorted by version.
+// Each version must be given by an up and a down migration, and the versions must be consecutive starting at 1.
+func loadMigrations(files fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*migration)
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of migration %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		current, ok := byVersion[uint(version)]
+		switch {
+		case !ok:
+			current = &migration{Version: uint(version), Name: match[2]}
+			byVersion[current.Version] = current
+		case current.Name != match[2]:
+			return nil, fmt.Errorf("conflicting names of migration %d: %s and %s", version, current.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			current.Up = string(content)
+		} else {
+			current.Down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for version := uint(1); version <= uint(len(byVersion)); version++ {
+		current, ok := byVersion[version]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("missing migration %d", version)
+		case current.Up == "" || current.Down == "":
+			return nil, fmt.Errorf("migration %d_%s lacks its up or down file", current.Version, current.Name)
+		}
+
+		migrations = append(migrations, *current)
+	}
+
+	return migrations, nil
+}
+
+// runMigrator runs the migration on the database given by the configuration and returns the status of the migrations,
+// even if the migration failed part way. The status is nil if the database could not be opened.
+func runMigrator(config DatabaseConfig, logger *zap.Logger, migrate func(*schemaMigrator) error) ([]MigrationStatus, error) {
+	if address, err := url.Parse(config.URL); err == nil && address.Scheme == "memory" {
+		return nil, fmt.Errorf("the in-memory store needs no migrations")
+	}
+
+	database, err := openDatabase(config)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = Helper(database).Close() }()
+
+	migrator, err := SchemaMigrator(database, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateErr := migrate(migrator)
+	status, err := migrator.Status()
+	return status, errors.Join(migrateErr, err)
+}
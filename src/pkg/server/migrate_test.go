@@ -0,0 +1,108 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	zap "go.uber.org/zap"
+	schema "gorm.io/gorm/schema"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(content string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(content)} }
+
+	for _, tt := range []struct {
+		name     string
+		files    fstest.MapFS
+		versions []uint
+		wantErr  bool
+	}{
+		{"test#1", fstest.MapFS{
+			"0002_b.up.sql": file("B"), "0002_b.down.sql": file("-B"),
+			"0001_a.up.sql": file("A"), "0001_a.down.sql": file("-A"),
+		}, []uint{1, 2}, false},
+		{"test#2", fstest.MapFS{}, nil, false},
+		{"test#3", fstest.MapFS{"0001_a.up.sql": file("A")}, nil, true},
+		{"test#4", fstest.MapFS{
+			"0001_a.up.sql": file("A"), "0001_a.down.sql": file("-A"),
+			"0003_c.up.sql": file("C"), "0003_c.down.sql": file("-C"),
+		}, nil, true},
+		{"test#5", fstest.MapFS{"0001_a.up.sql": file("A"), "0001_b.down.sql": file("-B")}, nil, true},
+		{"test#6", fstest.MapFS{"0001_a.sql": file("A")}, nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			var versions []uint
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+				if migration.Up == "" || migration.Down == "" {
+					t.Errorf("migration %d lacks its SQL: %+v", migration.Version, migration)
+				}
+			}
+
+			if !slices.Equal(versions, tt.versions) {
+				t.Errorf("loadMigrations() returned versions %v, want %v", versions, tt.versions)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		t.Fatalf("embeddedMigrations() failed: %v", err)
+	}
+
+	var up, down strings.Builder
+	for _, migration := range migrations {
+		up.WriteString(migration.Up)
+		down.WriteString(migration.Down)
+	}
+
+	// Every table of the models is created by the migrations and dropped by reverting them.
+	for _, model := range []any{&AuditEvent{}, &FeatureFlag{}, &FeatureFlagChange{}, &Metric{}, &Player{}, &Score{}, &ScoreRun{}, &UnverifiedRun{}} {
+		parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("failed to parse %T: %v", model, err)
+		}
+
+		table := `"` + parsed.Table + `"`
+		if !strings.Contains(up.String(), "CREATE TABLE IF NOT EXISTS "+table) {
+			t.Errorf("no migration creates the table %s", table)
+		}
+		if !strings.Contains(down.String(), "DROP TABLE IF EXISTS "+table) {
+			t.Errorf("no migration drops the table %s", table)
+		}
+
+		for _, field := range parsed.Fields {
+			if field.DBName != "" && !strings.Contains(up.String(), `"`+field.DBName+`" `) {
+				t.Errorf("no migration creates the column %s.%s", table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrateMemoryStore(t *testing.T) {
+	config := DatabaseConfig{URL: "memory://"}
+	for _, tt := range []struct {
+		name    string
+		migrate func() ([]MigrationStatus, error)
+	}{
+		{"test#1", func() ([]MigrationStatus, error) { return MigrateUp(config, 0, zap.NewNop()) }},
+		{"test#2", func() ([]MigrationStatus, error) { return MigrateDown(config, 1, zap.NewNop()) }},
+		{"test#3", func() ([]MigrationStatus, error) { return MigrationStatuses(config) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, err := tt.migrate(); err == nil || status != nil {
+				t.Errorf("migration of the in-memory store = %v, %v, want error", status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "score_runs";
+DROP TABLE IF EXISTS "scores";
+DROP TABLE IF EXISTS "metrics";
+DROP TABLE IF EXISTS "feature_flag_changes";
+DROP TABLE IF EXISTS "feature_flags";
+DROP TABLE IF EXISTS "audit_events";
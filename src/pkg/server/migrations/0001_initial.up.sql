@@ -0,0 +1,21 @@
+-- The tables as created by the automatic migration of the models preceding the versioned migrations,
+-- hence an existing database adopts them unchanged.
+CREATE TABLE IF NOT EXISTS "audit_events" ("id" bigserial,"occurred_at" timestamptz,"actor" text,"action" text,"target" text,"before" jsonb,"after" jsonb,"status" bigint,"client_ip" text,"request_id" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_audit_events_actor" ON "audit_events" ("actor");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_occurred_at" ON "audit_events" ("occurred_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_request_id" ON "audit_events" ("request_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_action" ON "audit_events" ("action");
+
+CREATE TABLE IF NOT EXISTS "feature_flags" ("key" text,"scope" text,"subject" text,"value" text,"updated_at" timestamptz,"updated_by" text,PRIMARY KEY ("key","scope","subject"));
+
+CREATE TABLE IF NOT EXISTS "feature_flag_changes" ("id" bigserial,"key" text,"scope" text,"subject" text,"value" text,"previous" text,"changed_at" timestamptz,"changed_by" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_feature_flag_changes_changed_at" ON "feature_flag_changes" ("changed_at");
+CREATE INDEX IF NOT EXISTS "idx_feature_flag_changes_key" ON "feature_flag_changes" ("key");
+
+CREATE TABLE IF NOT EXISTS "metrics" ("created_at" timestamptz,"updated_at" timestamptz,"endpoint" text,"method" text,"count" bigint,PRIMARY KEY ("endpoint","method"));
+
+CREATE TABLE IF NOT EXISTS "scores" ("created_at" timestamptz,"updated_at" timestamptz,"name" text,"score" bigint,"subject" text,PRIMARY KEY ("name"));
+
+CREATE TABLE IF NOT EXISTS "score_runs" ("id" bigserial,"created_at" timestamptz,"name" text,"level" bigint,"discovered_planets" text,"duration" bigint,"enemy_kills" bigint,"cause_of_death" text,"subject" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_score_runs_name" ON "score_runs" ("name");
+CREATE INDEX IF NOT EXISTS "idx_score_runs_created_at" ON "score_runs" ("created_at");
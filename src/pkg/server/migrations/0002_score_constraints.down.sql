@@ -0,0 +1,2 @@
+ALTER TABLE "score_runs" DROP CONSTRAINT IF EXISTS "fk_score_runs_player";
+DROP INDEX IF EXISTS "idx_scores_score";
@@ -0,0 +1,7 @@
+-- The leaderboard and the retention policy order the scores by score.
+CREATE INDEX IF NOT EXISTS "idx_scores_score" ON "scores" ("score" DESC);
+
+-- The runs were meant to be deleted together with the score of their player,
+-- but the automatic migration never created the foreign key, so orphaned runs are removed first.
+DELETE FROM "score_runs" WHERE "name" NOT IN (SELECT "name" FROM "scores");
+ALTER TABLE "score_runs" ADD CONSTRAINT "fk_score_runs_player" FOREIGN KEY ("name") REFERENCES "scores" ("name") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,5 @@
+-- The game runs whose score could not be verified by replaying them are kept apart from the leaderboard.
+CREATE TABLE IF NOT EXISTS "unverified_runs" ("id" bigserial,"created_at" timestamptz,"name" text,"level" bigint,"discovered_planets" text,"duration" bigint,"enemy_kills" bigint,"cause_of_death" text,"subject" text,"flag" text,"reason" text,"replay" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_unverified_runs_created_at" ON "unverified_runs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_unverified_runs_name" ON "unverified_runs" ("name");
+CREATE INDEX IF NOT EXISTS "idx_unverified_runs_flag" ON "unverified_runs" ("flag");
@@ -0,0 +1,10 @@
+ALTER TABLE "score_runs" DROP CONSTRAINT IF EXISTS "fk_score_runs_player";
+DROP INDEX IF EXISTS "idx_score_runs_player_id";
+ALTER TABLE "score_runs" DROP COLUMN IF EXISTS "player_id";
+ALTER TABLE "scores" DROP CONSTRAINT IF EXISTS "fk_scores_player";
+ALTER TABLE "scores" DROP CONSTRAINT IF EXISTS "scores_pkey";
+DROP INDEX IF EXISTS "idx_scores_name";
+ALTER TABLE "scores" ADD PRIMARY KEY ("name");
+ALTER TABLE "scores" DROP COLUMN IF EXISTS "player_id";
+ALTER TABLE "score_runs" ADD CONSTRAINT "fk_score_runs_player" FOREIGN KEY ("name") REFERENCES "scores" ("name") ON DELETE CASCADE ON UPDATE CASCADE;
+DROP TABLE IF EXISTS "players";
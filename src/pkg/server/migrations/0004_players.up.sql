@@ -0,0 +1,26 @@
+-- The players are identified by the subject of their sessions, their names are reserved once claimed.
+CREATE TABLE IF NOT EXISTS "players" ("created_at" timestamptz,"updated_at" timestamptz,"id" text,"name" text,"recovery_hash" text,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_players_name" ON "players" ("name");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_players_recovery_hash" ON "players" ("recovery_hash");
+
+-- The names of the existing scores are reserved for new players, whose identity is restored by a recovery code issued by an admin.
+-- The hash of the recovery code is random, hence no code matches it until one is issued.
+INSERT INTO "players" ("created_at", "updated_at", "id", "name", "recovery_hash")
+SELECT "created_at", "updated_at", md5('player:' || "name"), "name", md5(random()::text || clock_timestamp()::text) || md5("name")
+FROM "scores"
+ON CONFLICT DO NOTHING;
+
+-- The scores are keyed by the player instead of the name.
+ALTER TABLE "scores" ADD COLUMN IF NOT EXISTS "player_id" text;
+UPDATE "scores" SET "player_id" = "players"."id" FROM "players" WHERE "players"."name" = "scores"."name" AND "scores"."player_id" IS NULL;
+ALTER TABLE "score_runs" DROP CONSTRAINT IF EXISTS "fk_score_runs_player";
+ALTER TABLE "scores" DROP CONSTRAINT IF EXISTS "scores_pkey";
+ALTER TABLE "scores" ADD PRIMARY KEY ("player_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_scores_name" ON "scores" ("name");
+ALTER TABLE "scores" ADD CONSTRAINT "fk_scores_player" FOREIGN KEY ("player_id") REFERENCES "players" ("id") ON DELETE CASCADE;
+
+-- The runs are keyed by the player as well, so that they belong to the score of the player.
+ALTER TABLE "score_runs" ADD COLUMN IF NOT EXISTS "player_id" text;
+UPDATE "score_runs" SET "player_id" = "scores"."player_id" FROM "scores" WHERE "scores"."name" = "score_runs"."name" AND "score_runs"."player_id" IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_score_runs_player_id" ON "score_runs" ("player_id");
+ALTER TABLE "score_runs" ADD CONSTRAINT "fk_score_runs_player" FOREIGN KEY ("player_id") REFERENCES "scores" ("player_id") ON DELETE CASCADE;
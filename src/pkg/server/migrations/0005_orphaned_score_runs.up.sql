@@ -0,0 +1,9 @@
+-- The runs of players without a score are moved to a quarantine table rather than deleted,
+-- so that an operator decides whether to restore their scores or to drop them.
+-- Migration 0002 has deleted such runs before adding the foreign key, those cannot be restored.
+CREATE TABLE IF NOT EXISTS "orphaned_score_runs" (LIKE "score_runs");
+ALTER TABLE "orphaned_score_runs" ADD COLUMN IF NOT EXISTS "player_id" text;
+INSERT INTO "orphaned_score_runs" ("id","created_at","name","level","discovered_planets","duration","enemy_kills","cause_of_death","subject","player_id")
+SELECT "id","created_at","name","level","discovered_planets","duration","enemy_kills","cause_of_death","subject","player_id" FROM "score_runs"
+WHERE "player_id" IS NULL OR "player_id" NOT IN (SELECT "player_id" FROM "scores");
+DELETE FROM "score_runs" WHERE "id" IN (SELECT "id" FROM "orphaned_score_runs");
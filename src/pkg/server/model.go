@@ -0,0 +1,1330 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+	config "github.com/sarumaj/edu-space-invaders/src/pkg/config"
+	planet "github.com/sarumaj/edu-space-invaders/src/pkg/objects/planet"
+	zap "go.uber.org/zap"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+const maximumSize = 1 << 30         // 1 GiB
+const sizeThreshold = 1_000_000_000 // 1 GB, approximately 93% of the maximum size
+
+const importBatchSize = 1000 // importBatchSize is the number of scores locked and saved at once by an import.
+const importChanges = 100    // importChanges is the maximum number of changes listed by an import report.
+
+const maximumCauseLength = 256            // maximumCauseLength is the maximum length of the cause of death.
+const maximumNameLength = 64              // maximumNameLength is the maximum length of a player's name.
+const maximumRunDuration = 24 * time.Hour // maximumRunDuration is the maximum plausible duration of a single game run.
+const maximumReplayFlags = 1 << 10        // maximumReplayFlags is the maximum number of recorded changes of the game flags of a game run.
+const maximumReplayInputs = 1 << 17       // maximumReplayInputs is the maximum number of recorded inputs of a game run, about one per frame of the replay limit.
+const maximumSubmissionSize = 8 << 20     // maximumSubmissionSize is the maximum size of the body of a score submission, including the replay of maximumReplayInputs.
+
+// namePattern matches the names the players may claim, letters and digits along with spaces, dots, hyphens and underscores,
+// so that the names are safe to be shown by the game.
+var namePattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} ._-]*$`)
+
+// lastModified is the SQL expression for the time of the most recent modification of a row.
+const lastModified = "CASE WHEN updated_at > created_at THEN updated_at ELSE created_at END"
+
+// Scopes of the feature flags.
+const (
+	flagScopeGlobal  = "global"  // flagScopeGlobal applies the value of a feature flag to all players.
+	flagScopePlayer  = "player"  // flagScopePlayer overrides the global value for a player.
+	flagScopeSession = "session" // flagScopeSession overrides the global and the player's value for a session.
+)
+
+const databaseSizeQuery = "SELECT pg_database_size(current_database())"
+const tableSizeQuery = "SELECT pg_total_relation_size(?)"
+
+// helper is a helper for the database.
+type helper struct{ *gorm.DB }
+
+// CheckSchema checks that all migrations embedded in the binary have been applied.
+func (database helper) CheckSchema() error {
+	migrator, err := SchemaMigrator(database.DB, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	if latest := migrator.Latest(); version < latest {
+		return fmt.Errorf("schema version %d is behind %d, run migrate up", version, latest)
+	}
+
+	return nil
+}
+
+// Close closes the database connection pool.
+func (database helper) Close() error {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+// ExportMetrics passes the metrics to yield in batches of the given size, ordered by endpoint and method.
+// The batches are read by keyset pagination, so that no transaction is held open while yield writes them.
+func (database helper) ExportMetrics(batchSize int, yield func([]Metric) error) error {
+	var last *Metric
+	for {
+		query := database.Order("endpoint").Order("method").Limit(batchSize)
+		if last != nil {
+			query = query.Where("(endpoint, method) > (?, ?)", last.Endpoint, last.Method)
+		}
+
+		var metrics []Metric
+		if err := query.Find(&metrics).Error; err != nil {
+			return err
+		}
+
+		if len(metrics) == 0 {
+			return nil
+		}
+
+		if err := yield(metrics); err != nil {
+			return err
+		}
+
+		last = &metrics[len(metrics)-1]
+	}
+}
+
+// ExportScores passes the scores to yield in batches of the given size, ordered by name.
+// The batches are read by keyset pagination, so that no transaction is held open while yield writes them.
+func (database helper) ExportScores(batchSize int, yield func([]Score) error) error {
+	var last *Score
+	for {
+		query := database.Order("name").Limit(batchSize)
+		if last != nil {
+			query = query.Where("name > ?", last.Name)
+		}
+
+		var scores []Score
+		if err := query.Find(&scores).Error; err != nil {
+			return err
+		}
+
+		if len(scores) == 0 {
+			return nil
+		}
+
+		if err := yield(scores); err != nil {
+			return err
+		}
+
+		last = &scores[len(scores)-1]
+	}
+}
+
+// FindPlayer returns the player matching all non-empty fields of the query.
+func (database helper) FindPlayer(query PlayerQuery) (Player, error) {
+	if query == (PlayerQuery{}) {
+		return Player{}, fmt.Errorf("%w: empty query", ErrNotFound)
+	}
+
+	var player Player
+	switch err := database.Where(&Player{ID: query.ID, Name: query.Name, RecoveryHash: query.RecoveryHash}).Take(&player).Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return Player{}, fmt.Errorf("%w: player", ErrNotFound)
+	case err != nil:
+		return Player{}, err
+	}
+
+	return player, nil
+}
+
+// GetAuditEvents returns the audit events matching the query, the newest first.
+func (database helper) GetAuditEvents(query AuditQuery) ([]AuditEvent, error) {
+	query = query.withDefaults()
+	statement := database.Order("occurred_at DESC, id DESC").Limit(query.Limit)
+	if query.Actor != "" {
+		statement = statement.Where("actor = ?", query.Actor)
+	}
+
+	if query.Action != "" {
+		statement = statement.Where("action = ?", query.Action)
+	}
+
+	if !query.Since.IsZero() {
+		statement = statement.Where("occurred_at >= ?", query.Since)
+	}
+
+	if !query.Until.IsZero() {
+		statement = statement.Where("occurred_at < ?", query.Until)
+	}
+
+	var events []AuditEvent
+	if err := statement.Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetDatabaseSize returns the database size.
+func (database helper) GetDatabaseSize() (Size, error) {
+	var size int64
+	if err := database.Raw(databaseSizeQuery).Scan(&size).Error; err != nil {
+		return 0, err
+	}
+
+	return Size(size), nil
+}
+
+// GetFeatureFlagHistory returns the most recent changes of the feature flags, the newest first.
+// If key is not empty, only the changes of the given flag are returned.
+func (database helper) GetFeatureFlagHistory(key string, limit int) ([]FeatureFlagChange, error) {
+	query := database.Order("changed_at DESC, id DESC").Limit(limit)
+	if key != "" {
+		query = query.Where(`"key" = ?`, key)
+	}
+
+	var changes []FeatureFlagChange
+	if err := query.Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// GetFeatureFlags returns the global feature flags and the flags overridden for the player and the session.
+// Empty names of the player or the session match no overrides.
+func (database helper) GetFeatureFlags(player, session string) ([]FeatureFlag, error) {
+	var flags []FeatureFlag
+	if err := database.
+		Where("scope = ?", flagScopeGlobal).
+		Or("scope = ? AND subject = ? AND subject <> ''", flagScopePlayer, player).
+		Or("scope = ? AND subject = ? AND subject <> ''", flagScopeSession, session).
+		Order(`"key", scope`).
+		Find(&flags).
+		Error; err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+// GetTableSizes returns the table sizes.
+// It returns the table sizes as a map of table names to sizes.
+func (database helper) GetTableSizes() (map[string]Size, error) {
+	tables, err := database.Migrator().GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]Size, len(tables))
+	for _, table := range tables {
+		var size int64
+		if err := database.Raw(tableSizeQuery, table).Scan(&size).Error; err != nil {
+			return nil, err
+		}
+		sizes[table] = Size(size)
+	}
+
+	return sizes, nil
+}
+
+// GetScore returns the score of the player.
+func (database helper) GetScore(name string) (Score, error) {
+	var score Score
+	switch err := database.Where("name = ?", name).Take(&score).Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return Score{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case err != nil:
+		return Score{}, err
+	}
+
+	return score, nil
+}
+
+// GetLeaderboard returns a page of the leaderboard.
+// The ranks are computed by the database using a window function over the best scores of the requested period.
+// If the query refers to a player with Around, the page is centered around the player's rank.
+// If the query refers to a session with Subject, the best ranked score of the session is returned as well.
+func (database helper) GetLeaderboard(query LeaderboardQuery) (Leaderboard, error) {
+	query = query.withDefaults()
+	board := Leaderboard{Entries: make([]LeaderboardEntry, 0), Limit: query.Limit, Offset: query.Offset, Period: query.Period}
+
+	ranked := database.rankedScores(query.Since(time.Now()))
+	if err := database.Table("(?) AS ranked", ranked).Count(&board.Total).Error; err != nil {
+		return Leaderboard{}, err
+	}
+
+	if query.Around != "" {
+		var entry LeaderboardEntry
+		switch err := database.Table("(?) AS ranked", ranked).Where("name = ?", query.Around).Take(&entry).Error; {
+		case err == nil:
+			board.Offset = int(max(entry.Rank-1-int64(query.Limit/2), 0))
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return Leaderboard{}, err
+		}
+	}
+
+	if err := database.
+		Table("(?) AS ranked", ranked).
+		Where("rank > ?", board.Offset).
+		Order("rank").
+		Limit(board.Limit).
+		Find(&board.Entries).
+		Error; err != nil {
+
+		return Leaderboard{}, err
+	}
+
+	if query.Subject != "" {
+		var entry LeaderboardEntry
+		switch err := database.Table("(?) AS ranked", ranked).Where("subject = ?", query.Subject).Order("rank").Take(&entry).Error; {
+		case err == nil:
+			board.Self = &entry
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return Leaderboard{}, err
+		}
+	}
+
+	return board, nil
+}
+
+// GetMetrics returns the metrics.
+// It returns the metrics sorted by endpoint and method.
+func (database helper) GetMetrics() ([]Metric, error) {
+	metrics := make([]Metric, 0)
+	if err := database.Order("endpoint").Order("method").Find(&metrics).Error; err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// GetPlayerProfile returns the profile of the player.
+// It aggregates all game runs of the player and returns the most recent runs specified by recentRuns.
+func (database helper) GetPlayerProfile(name string, recentRuns int) (PlayerProfile, error) {
+	score, err := database.GetScore(name)
+	if err != nil {
+		return PlayerProfile{}, err
+	}
+
+	rank, err := database.GetRank(name)
+	if err != nil {
+		return PlayerProfile{}, err
+	}
+
+	profile := PlayerProfile{
+		Name:              score.Name,
+		BestScore:         score.Score,
+		Rank:              rank,
+		DiscoveredPlanets: make([]string, 0),
+		RecentRuns:        make([]ScoreRun, 0),
+	}
+
+	if err := database.
+		Model(&ScoreRun{}).
+		Select("COUNT(*) AS run_count, COALESCE(AVG(level), 0) AS average_level").
+		Where("player_id = ?", score.PlayerID).
+		Scan(&profile).
+		Error; err != nil {
+
+		return PlayerProfile{}, err
+	}
+
+	var runs []ScoreRun
+	if err := database.Select("discovered_planets").Where("player_id = ?", score.PlayerID).Find(&runs).Error; err != nil {
+		return PlayerProfile{}, err
+	}
+
+	discovered := make(map[string]bool)
+	for _, run := range runs {
+		for _, discoveredPlanet := range run.DiscoveredPlanets {
+			discovered[discoveredPlanet] = true
+		}
+	}
+
+	for t := planet.PlanetType(0); int(t) < planet.PlanetsCount; t++ {
+		if discovered[t.String()] {
+			profile.DiscoveredPlanets = append(profile.DiscoveredPlanets, t.String())
+		}
+	}
+
+	if err := database.
+		Where("player_id = ?", score.PlayerID).
+		Order("created_at DESC").
+		Limit(recentRuns).
+		Find(&profile.RecentRuns).
+		Error; err != nil {
+
+		return PlayerProfile{}, err
+	}
+
+	return profile, nil
+}
+
+// GetRank returns the rank of the player's score.
+// The rank is the position of the score in the leaderboard of all times.
+func (database helper) GetRank(name string) (int64, error) {
+	var entry LeaderboardEntry
+	switch err := database.Table("(?) AS ranked", database.rankedScores(time.Time{})).Where("name = ?", name).Take(&entry).Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case err != nil:
+		return 0, err
+	}
+
+	return entry.Rank, nil
+}
+
+// GetScores returns the scores.
+// It returns the scores sorted by score in descending order.
+func (database helper) GetScores() ([]Score, error) {
+	scores := make([]Score, 0)
+	if err := database.
+		Order(clause.OrderBy{
+			Columns: []clause.OrderByColumn{
+				{Column: clause.Column{Name: "score"}, Desc: true},
+				{Column: clause.Column{Name: lastModified, Raw: true}},
+			},
+		}).
+		Find(&scores).
+		Error; err != nil {
+
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// ImportScores imports the scores according to the import mode in a single transaction.
+// The existing scores are locked batch by batch, so that concurrent submissions are not lost.
+// In dry-run mode, the changes are reported without being saved.
+func (database helper) ImportScores(scores []Score, mode ImportMode, dryRun bool) (ImportReport, error) {
+	report := ImportReport{Mode: mode, DryRun: dryRun, Total: len(scores)}
+	now := time.Now()
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(scores); start += importBatchSize {
+			batch := scores[start:min(start+importBatchSize, len(scores))]
+			names := make([]string, 0, len(batch))
+			for _, score := range batch {
+				names = append(names, score.Name)
+			}
+
+			var existing []Score
+			if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("name IN ?", names).Find(&existing).Error; err != nil {
+				return err
+			}
+
+			current := make(map[string]Score, len(existing))
+			for _, score := range existing {
+				current[score.Name] = score
+			}
+
+			var changed []Score
+			for _, score := range batch {
+				previous, ok := current[score.Name]
+				if saved, change := report.plan(previous, ok, score, now); change {
+					changed = append(changed, saved)
+				}
+			}
+
+			if dryRun || len(changed) == 0 {
+				continue
+			}
+
+			changed, err := Helper(tx).reservePlayers(changed)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.
+				Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "player_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"score", "updated_at"}),
+				}).
+				Create(&changed).
+				Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return report, err
+}
+
+// Ping checks that the database is reachable.
+func (database helper) Ping(ctx context.Context) error {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// Prune deletes the metrics, the scores and the unverified runs exceeding the retention policy.
+// The runs of the deleted scores are deleted along with them.
+// It returns the number of deleted metrics, scores, runs and unverified runs.
+// In dry-run mode, the rows are counted instead of being deleted.
+func (database helper) Prune(policy RetentionPolicy) (report RetentionReport, err error) {
+	report.DryRun = policy.DryRun
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if !policy.MetricsUpdatedBefore.IsZero() {
+			condition := lastModified + " < ?"
+			if policy.DryRun {
+				if err := tx.Model(&Metric{}).Where(condition, policy.MetricsUpdatedBefore).Count(&report.Metrics).Error; err != nil {
+					return err
+				}
+			} else {
+				result := tx.Where(condition, policy.MetricsUpdatedBefore).Delete(&Metric{})
+				if result.Error != nil {
+					return result.Error
+				}
+				report.Metrics = result.RowsAffected
+			}
+		}
+
+		if policy.KeepTopScores > 0 {
+			kept := tx.
+				Model(&Score{}).
+				Select("player_id").
+				Order(clause.OrderBy{
+					Columns: []clause.OrderByColumn{
+						{Column: clause.Column{Name: "score"}, Desc: true},
+						{Column: clause.Column{Name: lastModified, Raw: true}},
+					},
+				}).
+				Limit(policy.KeepTopScores)
+
+			// The runs are deleted by the foreign key constraint, hence they are counted beforehand.
+			if err := tx.Model(&ScoreRun{}).Where("player_id NOT IN (?)", kept).Count(&report.Runs).Error; err != nil {
+				return err
+			}
+
+			if policy.DryRun {
+				if err := tx.Model(&Score{}).Where("player_id NOT IN (?)", kept).Count(&report.Scores).Error; err != nil {
+					return err
+				}
+			} else {
+				result := tx.Where("player_id NOT IN (?)", kept).Delete(&Score{})
+				if result.Error != nil {
+					return result.Error
+				}
+				report.Scores = result.RowsAffected
+			}
+		}
+
+		if !policy.UnverifiedRunsCreatedBefore.IsZero() {
+			if policy.DryRun {
+				if err := tx.Model(&UnverifiedRun{}).Where("created_at < ?", policy.UnverifiedRunsCreatedBefore).Count(&report.UnverifiedRuns).Error; err != nil {
+					return err
+				}
+			} else {
+				result := tx.Where("created_at < ?", policy.UnverifiedRunsCreatedBefore).Delete(&UnverifiedRun{})
+				if result.Error != nil {
+					return result.Error
+				}
+				report.UnverifiedRuns = result.RowsAffected
+			}
+		}
+
+		return nil
+	})
+
+	return report, err
+}
+
+// rankedScores returns a query ranking the best scores of the players achieved since the given time.
+// The scores are ranked by score in descending order, then by modification time and name in ascending order.
+// If since is zero, the best scores of all times are ranked,
+// otherwise the best run of each player created since the given time is ranked.
+func (database helper) rankedScores(since time.Time) *gorm.DB {
+	if since.IsZero() {
+		return database.
+			Model(&Score{}).
+			Select("*, ROW_NUMBER() OVER (ORDER BY score DESC, " + lastModified + " ASC, name ASC) AS rank")
+	}
+
+	best := database.
+		Model(&ScoreRun{}).
+		Select("DISTINCT ON (player_id) player_id, name, level AS score, subject, created_at, created_at AS updated_at").
+		Where("created_at >= ?", since).
+		Order("player_id, level DESC, created_at ASC")
+
+	return database.
+		Table("(?) AS best", best).
+		Select("*, ROW_NUMBER() OVER (ORDER BY score DESC, created_at ASC, name ASC) AS rank")
+}
+
+// reservePlayers sets the player of the scores lacking one, e.g. imported scores, to the player of their name.
+// The names which have not been claimed yet are reserved for new players,
+// whose identity is restored by a recovery code issued by an admin.
+func (database helper) reservePlayers(scores []Score) ([]Score, error) {
+	var players []Player
+	for _, score := range scores {
+		if score.PlayerID != "" {
+			continue
+		}
+
+		player, err := newPlayer(score.Name)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, player)
+	}
+
+	if len(players) == 0 {
+		return scores, nil
+	}
+
+	if err := database.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&players).Error; err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(players))
+	for _, player := range players {
+		names = append(names, player.Name)
+	}
+
+	var reserved []Player
+	if err := database.Select("id", "name").Where("name IN ?", names).Find(&reserved).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string, len(reserved))
+	for _, player := range reserved {
+		ids[player.Name] = player.ID
+	}
+
+	for i := range scores {
+		scores[i].PlayerID = cmp.Or(scores[i].PlayerID, ids[scores[i].Name])
+	}
+
+	return scores, nil
+}
+
+// SaveAuditEvent appends the event to the audit log.
+func (database helper) SaveAuditEvent(event AuditEvent) error {
+	event.ID = 0
+	return database.Create(&event).Error
+}
+
+// SaveFeatureFlags sets the feature flags, a change without a value unsets the flag.
+// Each change is recorded along with the previous value of the flag.
+// It returns the recorded changes.
+func (database helper) SaveFeatureFlags(changes []FeatureFlagChange) ([]FeatureFlagChange, error) {
+	now := time.Now()
+	recorded := slices.Clone(changes)
+	err := database.Transaction(func(tx *gorm.DB) error {
+		for i := range recorded {
+			change := &recorded[i]
+			change.ID, change.ChangedAt, change.Previous = 0, now, nil
+
+			target := map[string]any{"key": change.Key, "scope": change.Scope, "subject": change.Subject}
+			var existing FeatureFlag
+			switch err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where(target).Take(&existing).Error; {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+			case err != nil:
+				return err
+			default:
+				change.Previous = &existing.Value
+			}
+
+			if change.Value == nil {
+				if err := tx.Where(target).Delete(&FeatureFlag{}).Error; err != nil {
+					return err
+				}
+			} else if err := tx.
+				Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "key"}, {Name: "scope"}, {Name: "subject"}},
+					DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at", "updated_by"}),
+				}).
+				Create(&FeatureFlag{
+					Key:       change.Key,
+					Scope:     change.Scope,
+					Subject:   change.Subject,
+					Value:     *change.Value,
+					UpdatedAt: now,
+					UpdatedBy: change.ChangedBy,
+				}).
+				Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(change).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded, nil
+}
+
+// SaveMetric saves the metric.
+// It increments the count if the metric already exists.
+// It updates the updated_at field.
+func (database helper) SaveMetric(metric Metric) error {
+	return database.SaveMetrics([]Metric{metric})
+}
+
+// SaveMetrics saves the metrics.
+// It increments the counts of the existing metrics by the given counts.
+// It updates the updated_at field.
+// An empty list of metrics is a no-op.
+func (database helper) SaveMetrics(metrics []Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return database.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "endpoint"}, {Name: "method"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"count":      gorm.Expr("CASE WHEN metrics.count < ? - EXCLUDED.count THEN metrics.count + EXCLUDED.count ELSE ? END", int64(math.MaxInt64), int64(math.MaxInt64)), // Increment the count.
+				"updated_at": gorm.Expr("?", time.Now()),
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				gorm.Expr("EXCLUDED.endpoint = metrics.endpoint"),
+				gorm.Expr("EXCLUDED.method = metrics.method"),
+			}},
+		}).
+		Create(metrics).
+		Error
+}
+
+// SavePlayer saves a new player.
+// It returns ErrConflict if the identifier, the name or the recovery code belongs to another player.
+func (database helper) SavePlayer(player Player) error {
+	result := database.Clauses(clause.OnConflict{DoNothing: true}).Create(&player)
+	switch {
+	case result.Error != nil:
+		return result.Error
+	case result.RowsAffected == 0:
+		return fmt.Errorf("%w: player %s", ErrConflict, player.Name)
+	}
+
+	return nil
+}
+
+// SaveRecoveryHash replaces the hash of the recovery code of the player.
+func (database helper) SaveRecoveryHash(id, hash string) error {
+	result := database.Model(&Player{}).Where("id = ?", id).Updates(map[string]any{"recovery_hash": hash, "updated_at": time.Now()})
+	switch {
+	case result.Error != nil:
+		return result.Error
+	case result.RowsAffected == 0:
+		return fmt.Errorf("%w: player", ErrNotFound)
+	}
+
+	return nil
+}
+
+// SaveRun saves a single game run.
+// It records the run in the history of the player and updates the player's score if the run's level is higher.
+// The subject of the run is the identifier of the player.
+func (database helper) SaveRun(run ScoreRun) error {
+	return database.Transaction(func(tx *gorm.DB) error {
+		scores, err := Helper(tx).reservePlayers([]Score{{PlayerID: run.Subject, Name: run.Name, Score: run.Level, Subject: run.Subject}})
+		if err != nil {
+			return err
+		}
+
+		if err := Helper(tx).SaveScores(scores); err != nil {
+			return err
+		}
+
+		run.PlayerID = scores[0].PlayerID
+		return tx.Create(&run).Error
+	})
+}
+
+// SaveScores saves the scores.
+// It updates the score if the new score is higher.
+// It updates the updated_at field.
+// The scores lacking a player are saved for the player of their name, see reservePlayers.
+// It returns ErrConflict if the name of a new score is the name of the score of another player.
+// An empty list of scores is a no-op.
+func (database helper) SaveScores(scores []Score) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		scores, err := Helper(tx).reservePlayers(scores)
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+			Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "player_id"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"score":      gorm.Expr("CASE WHEN EXCLUDED.score < ? THEN EXCLUDED.score ELSE scores.score END", math.MaxInt64),
+					"subject":    gorm.Expr("COALESCE(NULLIF(EXCLUDED.subject, ''), scores.subject)"),
+					"updated_at": gorm.Expr("?", time.Now()),
+				}),
+				Where: clause.Where{Exprs: []clause.Expression{gorm.Expr("EXCLUDED.score > scores.score")}},
+			}).
+			Create(scores).
+			Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: score", ErrConflict)
+		}
+
+		return err
+	})
+}
+
+// SaveUnverifiedRun saves a game run whose score could not be verified.
+// The run is kept apart from the scores and the history of the player.
+func (database helper) SaveUnverifiedRun(run UnverifiedRun) error {
+	return database.Create(&run).Error
+}
+
+// AuditEvent represents a privileged action recorded in the audit log.
+// The audit log is append-only, the events are never updated nor deleted.
+type AuditEvent struct {
+	ID         uint64          `yaml:"id" json:"id" gorm:"primaryKey"`
+	OccurredAt time.Time       `yaml:"occurred_at" json:"occurred_at" gorm:"index"`
+	Actor      string          `yaml:"actor" json:"actor" gorm:"index"`   // Actor is the subject of the token, or system for background tasks.
+	Action     string          `yaml:"action" json:"action" gorm:"index"` // Action is the method and the route of the request, e.g. POST /.env.
+	Target     string          `yaml:"target" json:"target"`              // Target is the object of the action, e.g. the scope of the feature flags.
+	Before     json.RawMessage `yaml:"before,omitempty" json:"before,omitempty" gorm:"type:jsonb"`
+	After      json.RawMessage `yaml:"after,omitempty" json:"after,omitempty" gorm:"type:jsonb"`
+	Status     int             `yaml:"status" json:"status"` // Status is the HTTP status of the response.
+	ClientIP   string          `yaml:"client_ip" json:"client_ip"`
+	RequestID  string          `yaml:"request_id" json:"request_id" gorm:"index"`
+}
+
+// AuditQuery represents the query parameters of the audit log.
+// The time range includes since and excludes until, a zero time leaves the range open.
+type AuditQuery struct {
+	Action string    `form:"action"`
+	Actor  string    `form:"actor"`
+	Limit  int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// withDefaults returns the query with the default values applied.
+func (q AuditQuery) withDefaults() AuditQuery {
+	q.Limit = selectValue(q.Limit, 100)
+	return q
+}
+
+// BaseModel is the base model for the database models.
+type BaseModel struct {
+	CreatedAt *time.Time `yaml:"created_at,omitempty" json:"created_at,omitempty" gorm:"autoCreateTime"`
+	UpdatedAt *time.Time `yaml:"updated_at,omitempty" json:"updated_at,omitempty" gorm:"autoUpdateTime"`
+}
+
+// Timestamp returns the time of the most recent modification.
+func (m BaseModel) Timestamp() (ts time.Time) {
+	if m.CreatedAt != nil {
+		ts = *m.CreatedAt
+	}
+
+	if m.UpdatedAt != nil && m.UpdatedAt.After(ts) {
+		ts = *m.UpdatedAt
+	}
+
+	return
+}
+
+// FeatureFlag represents the value of a game toggle, i.e. of an environment variable prefixed with SPACE_INVADERS_.
+// The global value applies to all players unless overridden for a player or for a session.
+type FeatureFlag struct {
+	Key       string    `yaml:"key" json:"key" gorm:"primaryKey"`
+	Scope     string    `yaml:"scope" json:"scope" gorm:"primaryKey"`                         // Scope is global, player or session.
+	Subject   string    `yaml:"subject,omitempty" json:"subject,omitempty" gorm:"primaryKey"` // Subject is the player's name or the session's subject, empty for the global value.
+	Value     string    `yaml:"value" json:"value"`
+	UpdatedAt time.Time `yaml:"updated_at" json:"updated_at"`
+	UpdatedBy string    `yaml:"updated_by" json:"updated_by"` // UpdatedBy is the subject of the token which set the value.
+}
+
+// FeatureFlagChange represents a change of a feature flag.
+type FeatureFlagChange struct {
+	ID        uint64    `yaml:"id" json:"id" gorm:"primaryKey"`
+	Key       string    `yaml:"key" json:"key" gorm:"index"`
+	Scope     string    `yaml:"scope" json:"scope"`
+	Subject   string    `yaml:"subject,omitempty" json:"subject,omitempty"`
+	Value     *string   `yaml:"value" json:"value"`       // Value is the new value, nil if the flag has been unset.
+	Previous  *string   `yaml:"previous" json:"previous"` // Previous is the previous value, nil if the flag has not been set.
+	ChangedAt time.Time `yaml:"changed_at" json:"changed_at" gorm:"index"`
+	ChangedBy string    `yaml:"changed_by" json:"changed_by"` // ChangedBy is the subject of the token which made the change.
+}
+
+// Validate validates the target of the change.
+func (c FeatureFlagChange) Validate() error {
+	switch {
+	case !strings.HasPrefix(c.Key, envVarPrefix) || len(c.Key) == len(envVarPrefix):
+		return fmt.Errorf("feature flag must be prefixed with %s: %q", envVarPrefix, c.Key)
+	case c.Scope == flagScopeGlobal && c.Subject != "":
+		return fmt.Errorf("global feature flag must not have a subject: %q", c.Subject)
+	case c.Scope != flagScopeGlobal && c.Scope != flagScopePlayer && c.Scope != flagScopeSession:
+		return fmt.Errorf("unknown scope of feature flag: %q", c.Scope)
+	case c.Scope != flagScopeGlobal && strings.TrimSpace(c.Subject) == "":
+		return fmt.Errorf("%s feature flag must have a subject", c.Scope)
+	}
+
+	return nil
+}
+
+// ImportMode selects how imported scores are merged with the existing scores of the same players.
+type ImportMode string
+
+// Import modes.
+const (
+	ImportKeepHigher   ImportMode = "keep-higher"   // ImportKeepHigher replaces existing scores by higher ones, like SaveScores.
+	ImportOverwrite    ImportMode = "overwrite"     // ImportOverwrite replaces existing scores by different ones.
+	ImportSkipExisting ImportMode = "skip-existing" // ImportSkipExisting imports the scores of new players only.
+)
+
+// Validate checks that the import mode is known.
+func (m ImportMode) Validate() error {
+	switch m {
+	case ImportKeepHigher, ImportOverwrite, ImportSkipExisting:
+		return nil
+	default:
+		return fmt.Errorf("unknown import mode: %q", m)
+	}
+}
+
+// ImportReport represents the outcome of an import of scores.
+type ImportReport struct {
+	Mode      ImportMode    `yaml:"mode" json:"mode"`
+	DryRun    bool          `yaml:"dry_run" json:"dry_run"`
+	Total     int           `yaml:"total" json:"total"`
+	Created   int           `yaml:"created" json:"created"`
+	Updated   int           `yaml:"updated" json:"updated"`
+	Unchanged int           `yaml:"unchanged" json:"unchanged"`
+	Changes   []ScoreChange `yaml:"changes" json:"changes"` // Changes are the first changes, at most importChanges.
+}
+
+// plan decides the change of the existing score, if any, by the imported score and counts it.
+// It returns the score to save and whether it has to be saved.
+// The timestamps of the imported score are kept, missing ones default to now.
+func (r *ImportReport) plan(existing Score, exists bool, imported Score, now time.Time) (Score, bool) {
+	switch {
+	case !exists:
+		imported.CreatedAt = cmp.Or(imported.CreatedAt, &now)
+		imported.UpdatedAt = cmp.Or(imported.UpdatedAt, imported.CreatedAt)
+		imported.Subject = ""
+		r.Created++
+		r.record(ScoreChange{Name: imported.Name, After: imported.Score})
+		return imported, true
+
+	case r.Mode == ImportKeepHigher && imported.Score > existing.Score,
+		r.Mode == ImportOverwrite && imported.Score != existing.Score:
+		before := existing.Score
+		r.Updated++
+		r.record(ScoreChange{Name: imported.Name, Before: &before, After: imported.Score})
+		existing.Score, existing.UpdatedAt = imported.Score, cmp.Or(imported.UpdatedAt, &now)
+		return existing, true
+
+	default:
+		r.Unchanged++
+		return existing, false
+	}
+}
+
+// record appends the change to the report, unless the report lists importChanges changes already.
+func (r *ImportReport) record(change ScoreChange) {
+	if len(r.Changes) < importChanges {
+		r.Changes = append(r.Changes, change)
+	}
+}
+
+// Leaderboard represents a page of the leaderboard.
+type Leaderboard struct {
+	Entries []LeaderboardEntry `yaml:"entries" json:"entries"`
+	Limit   int                `yaml:"limit" json:"limit"`
+	Offset  int                `yaml:"offset" json:"offset"`
+	Period  string             `yaml:"period" json:"period"`
+	Self    *LeaderboardEntry  `yaml:"self,omitempty" json:"self,omitempty"` // Self is the best ranked entry of the caller.
+	Total   int64              `yaml:"total" json:"total"`                   // Total is the number of ranked entries in the period.
+}
+
+// LeaderboardEntry represents a ranked score.
+type LeaderboardEntry struct {
+	Score
+	Rank int64 `yaml:"rank" json:"rank"`
+}
+
+// LeaderboardQuery represents the query parameters of the leaderboard.
+type LeaderboardQuery struct {
+	Around  string `form:"around"`
+	Limit   int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset  int    `form:"offset" binding:"omitempty,min=0"`
+	Period  string `form:"period" binding:"omitempty,oneof=daily weekly all"`
+	Subject string `form:"-"`
+}
+
+// Since returns the beginning of the period relative to the given time.
+// It returns the zero time for the period of all times.
+func (q LeaderboardQuery) Since(now time.Time) time.Time {
+	switch q.Period {
+	case "daily":
+		return now.AddDate(0, 0, -1)
+	case "weekly":
+		return now.AddDate(0, 0, -7)
+	default:
+		return time.Time{}
+	}
+}
+
+// withDefaults returns the query with the default values applied.
+func (q LeaderboardQuery) withDefaults() LeaderboardQuery {
+	q.Limit = selectValue(q.Limit, 10)
+	q.Period = selectValue(q.Period, "all")
+	return q
+}
+
+// Metric represents a metrics entry.
+type Metric struct {
+	BaseModel
+	Endpoint string `yaml:"endpoint" json:"endpoint" gorm:"primaryKey"`
+	Method   string `yaml:"method" json:"method" gorm:"primaryKey"`
+	Count    int64  `yaml:"count" json:"count"`
+}
+
+// Player represents the identity of a player.
+// The identifier is the subject of the sessions of the player, the name is reserved for the player once claimed.
+// The identity is restored on another device by the recovery code of the player, of which only the hash is kept.
+type Player struct {
+	BaseModel
+	ID           string `yaml:"id" json:"id" gorm:"primaryKey"`
+	Name         string `yaml:"name" json:"name" gorm:"uniqueIndex"`
+	RecoveryHash string `yaml:"-" json:"-" gorm:"uniqueIndex"`
+}
+
+// PlayerClaim represents the claim of a name by the player of the session.
+type PlayerClaim struct {
+	Name string `yaml:"name" json:"name" binding:"required"`
+}
+
+// Validate validates the claim.
+func (c *PlayerClaim) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	switch length := utf8.RuneCountInString(c.Name); {
+	case length == 0:
+		return fmt.Errorf("name must not be empty")
+	case length > maximumNameLength:
+		return fmt.Errorf("name must not be longer than %d characters", maximumNameLength)
+	case client.IsRecoveryCode(c.Name):
+		return fmt.Errorf("name must not look like a recovery code")
+	case !namePattern.MatchString(c.Name):
+		return fmt.Errorf("name must start with a letter or a digit and consist of letters, digits, spaces, dots, hyphens and underscores only")
+	}
+
+	return nil
+}
+
+// PlayerCredentials represents the identity of a player along with a new recovery code.
+// The recovery code is shown only once, since only its hash is kept.
+type PlayerCredentials struct {
+	ID           string `yaml:"id" json:"id"`
+	Name         string `yaml:"name" json:"name"`
+	RecoveryCode string `yaml:"recovery_code" json:"recovery_code"`
+}
+
+// PlayerProfile represents the statistics of a player.
+type PlayerProfile struct {
+	Name              string     `yaml:"name" json:"name"`
+	AverageLevel      float64    `yaml:"average_level" json:"average_level"`
+	BestScore         int64      `yaml:"best_score" json:"best_score"`
+	DiscoveredPlanets []string   `yaml:"discovered_planets" json:"discovered_planets"` // DiscoveredPlanets are the planets discovered over all runs.
+	Rank              int64      `yaml:"rank" json:"rank"`
+	RecentRuns        []ScoreRun `yaml:"recent_runs" json:"recent_runs"`
+	RunCount          int64      `yaml:"run_count" json:"run_count"`
+}
+
+// RunFlag flags a game run whose score could not be verified.
+type RunFlag string
+
+const (
+	RunFlagInvalidReplay RunFlag = "invalid_replay"  // RunFlagInvalidReplay flags a run whose replay has failed.
+	RunFlagMissingReplay RunFlag = "missing_replay"  // RunFlagMissingReplay flags a run submitted without a replay.
+	RunFlagReplayTooLong RunFlag = "replay_too_long" // RunFlagReplayTooLong flags a run whose replay exceeds the limit of the replayed frames.
+	RunFlagScoreMismatch RunFlag = "score_mismatch"  // RunFlagScoreMismatch flags a run whose replay has reached another level.
+	RunFlagUnfairFlags   RunFlag = "unfair_flags"    // RunFlagUnfairFlags flags a run played with game flags giving the player an advantage.
+)
+
+// PlayerQuery selects a player by its identifier, its name or the hash of its recovery code.
+type PlayerQuery struct {
+	ID           string
+	Name         string
+	RecoveryHash string
+}
+
+// PlayerRecovery represents the recovery code of a player.
+type PlayerRecovery struct {
+	RecoveryCode string `yaml:"recovery_code" json:"recovery_code" binding:"required"`
+}
+
+// RetentionPolicy represents the rows to be deleted by the retention job.
+type RetentionPolicy struct {
+	DryRun                      bool      // DryRun counts the rows instead of deleting them.
+	KeepTopScores               int       // KeepTopScores is the number of highest scores to keep, all scores are kept if not positive.
+	MetricsUpdatedBefore        time.Time // MetricsUpdatedBefore is the time before which metrics are deleted, no metrics are deleted if zero.
+	UnverifiedRunsCreatedBefore time.Time // UnverifiedRunsCreatedBefore is the time before which unverified runs are deleted, no unverified runs are deleted if zero.
+}
+
+// RetentionReport represents the outcome of a run of the retention job.
+type RetentionReport struct {
+	StartedAt      time.Time `yaml:"started_at" json:"started_at"`
+	Duration       string    `yaml:"duration" json:"duration"`
+	DryRun         bool      `yaml:"dry_run" json:"dry_run"`
+	Size           Size      `yaml:"size" json:"size"`                       // Size is the size of the database before the run in bytes.
+	SizeExceeded   bool      `yaml:"size_exceeded" json:"size_exceeded"`     // SizeExceeded is true if the scores have been pruned because the database exceeded its size limit.
+	Metrics        int64     `yaml:"metrics" json:"metrics"`                 // Metrics is the number of deleted metrics.
+	Scores         int64     `yaml:"scores" json:"scores"`                   // Scores is the number of deleted scores.
+	Runs           int64     `yaml:"runs" json:"runs"`                       // Runs is the number of deleted runs.
+	UnverifiedRuns int64     `yaml:"unverified_runs" json:"unverified_runs"` // UnverifiedRuns is the number of deleted unverified runs.
+	Error          string    `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// Score represents a player's score.
+// It is keyed by the player, the name is the name of the player.
+type Score struct {
+	BaseModel
+	PlayerID string `yaml:"-" json:"-" gorm:"primaryKey"`
+	Name     string `yaml:"name" json:"name" gorm:"uniqueIndex"`
+	Score    int64  `yaml:"score" json:"score"`
+	Subject  string `yaml:"-" json:"-"` // Subject is the subject of the session which submitted the score.
+}
+
+// ScoreChange represents the change of the score of a player by an import.
+type ScoreChange struct {
+	Name   string `yaml:"name" json:"name"`
+	Before *int64 `yaml:"before" json:"before"` // Before is nil if the player is new.
+	After  int64  `yaml:"after" json:"after"`
+}
+
+// ScoreResult represents the outcome of a score submission.
+type ScoreResult struct {
+	Name     string `yaml:"name" json:"name"`
+	Score    int64  `yaml:"score" json:"score"`       // Score is the best score of the player.
+	Rank     int64  `yaml:"rank" json:"rank"`         // Rank is the position of the best score on the leaderboard.
+	Verified bool   `yaml:"verified" json:"verified"` // Verified is false if the score has not been accepted, since it could not be verified.
+}
+
+// ScoreRun represents a single game run of a player.
+// It belongs to the score of the player and is deleted along with it.
+type ScoreRun struct {
+	ID                uint64        `yaml:"id" json:"id" gorm:"primaryKey"`
+	CreatedAt         *time.Time    `yaml:"created_at,omitempty" json:"created_at,omitempty" gorm:"autoCreateTime;index"`
+	PlayerID          string        `yaml:"-" json:"-" gorm:"index"`
+	Player            *Score        `yaml:"-" json:"-" gorm:"foreignKey:PlayerID;references:PlayerID;constraint:OnDelete:CASCADE"`
+	Name              string        `yaml:"name" json:"name" gorm:"index"`
+	Level             int64         `yaml:"level" json:"level"`
+	DiscoveredPlanets []string      `yaml:"discovered_planets" json:"discovered_planets" gorm:"serializer:json"`
+	Duration          time.Duration `yaml:"duration" json:"duration"`
+	EnemyKills        int64         `yaml:"enemy_kills" json:"enemy_kills"`
+	CauseOfDeath      string        `yaml:"cause_of_death" json:"cause_of_death"`
+	Subject           string        `yaml:"-" json:"-"` // Subject is the subject of the session which submitted the run.
+}
+
+// ScoreSubmission represents the result of a single game run.
+type ScoreSubmission struct {
+	Name              string         `yaml:"name" json:"name" binding:"required"`
+	Level             int64          `yaml:"level" json:"level"`
+	DiscoveredPlanets []string       `yaml:"discovered_planets" json:"discovered_planets"`
+	Duration          time.Duration  `yaml:"duration" json:"duration"`
+	EnemyKills        int64          `yaml:"enemy_kills" json:"enemy_kills"`
+	CauseOfDeath      string         `yaml:"cause_of_death" json:"cause_of_death"`
+	Replay            *config.Replay `yaml:"replay,omitempty" json:"replay,omitempty"` // Replay is the record of the game run, which verifies the level.
+}
+
+// Run returns the game run of the submission.
+func (s ScoreSubmission) Run(subject string) ScoreRun {
+	return ScoreRun{
+		Name:              s.Name,
+		Level:             s.Level,
+		DiscoveredPlanets: s.DiscoveredPlanets,
+		Duration:          s.Duration,
+		EnemyKills:        s.EnemyKills,
+		CauseOfDeath:      s.CauseOfDeath,
+		Subject:           subject,
+	}
+}
+
+// UnverifiedRun returns the unverified game run of the submission.
+func (s ScoreSubmission) UnverifiedRun(subject string, flag RunFlag, reason string) UnverifiedRun {
+	return UnverifiedRun{
+		Name:              s.Name,
+		Level:             s.Level,
+		DiscoveredPlanets: s.DiscoveredPlanets,
+		Duration:          s.Duration,
+		EnemyKills:        s.EnemyKills,
+		CauseOfDeath:      s.CauseOfDeath,
+		Subject:           subject,
+		Flag:              flag,
+		Reason:            reason,
+		Replay:            s.Replay,
+	}
+}
+
+// Verify verifies the level of the submission by replaying the game run headlessly with the recorded game flags, see Replayer.
+// Runs longer than the given game time and runs played with unfair game flags, see config.GameFlags.Unfair, are not replayed.
+// It returns the flag and the reason of the failed verification, the flag is empty if the level has been verified.
+// An error is returned if the replay could not be run, e.g. because the context is done.
+func (s ScoreSubmission) Verify(ctx context.Context, replay Replayer, maxDuration time.Duration) (RunFlag, string, error) {
+	if s.Replay == nil {
+		return RunFlagMissingReplay, "the run has been submitted without a replay", nil
+	}
+
+	if frames := int64(maxDuration.Seconds() * config.Config.Control.DesiredFramesPerSecondRate); s.Replay.Frames > frames {
+		return RunFlagReplayTooLong, fmt.Sprintf("the replay of %d frames exceeds the limit of %d frames", s.Replay.Frames, frames), nil
+	}
+
+	for _, flags := range s.Replay.Flags {
+		if flags.Unfair() {
+			return RunFlagUnfairFlags, fmt.Sprintf("the run has been played with god mode or repelled enemies at frame %d", flags.Frame), nil
+		}
+	}
+
+	highScore, err := replay(ctx, *s.Replay)
+	switch {
+	case errors.Is(err, ErrInvalidReplay):
+		return RunFlagInvalidReplay, err.Error(), nil
+
+	case err != nil:
+		return "", "", err
+
+	case int64(highScore) != s.Level:
+		return RunFlagScoreMismatch, fmt.Sprintf("the replay has reached level %d instead of %d", highScore, s.Level), nil
+
+	}
+
+	return "", "", nil
+}
+
+// Validate validates the submission.
+// It reports all violations at once.
+func (s *ScoreSubmission) Validate() error {
+	var errs []error
+
+	s.Name = strings.TrimSpace(s.Name)
+	switch length := utf8.RuneCountInString(s.Name); {
+	case length == 0:
+		errs = append(errs, fmt.Errorf("name must not be empty"))
+	case length > maximumNameLength:
+		errs = append(errs, fmt.Errorf("name must not be longer than %d characters", maximumNameLength))
+	}
+
+	if s.Level < 0 {
+		errs = append(errs, fmt.Errorf("level must not be negative"))
+	}
+
+	if s.Duration <= 0 || s.Duration > maximumRunDuration {
+		errs = append(errs, fmt.Errorf("duration must be within (0, %s]", maximumRunDuration))
+	}
+
+	if s.EnemyKills < 0 {
+		errs = append(errs, fmt.Errorf("enemy kills must not be negative"))
+	}
+
+	if s.Replay != nil {
+		if s.Replay.Frames < 0 {
+			errs = append(errs, fmt.Errorf("replay frames must not be negative"))
+		}
+
+		if len(s.Replay.Inputs) > maximumReplayInputs {
+			errs = append(errs, fmt.Errorf("replay must not record more than %d inputs", maximumReplayInputs))
+		}
+
+		if len(s.Replay.Flags) > maximumReplayFlags {
+			errs = append(errs, fmt.Errorf("replay must not record more than %d changes of the game flags", maximumReplayFlags))
+		}
+	}
+
+	s.CauseOfDeath = strings.TrimSpace(s.CauseOfDeath)
+	if utf8.RuneCountInString(s.CauseOfDeath) > maximumCauseLength {
+		errs = append(errs, fmt.Errorf("cause of death must not be longer than %d characters", maximumCauseLength))
+	}
+
+	var planets []string
+	for t := planet.PlanetType(0); int(t) < planet.PlanetsCount; t++ {
+		planets = append(planets, t.String())
+	}
+
+	seen := make(map[string]bool, len(s.DiscoveredPlanets))
+	for _, name := range s.DiscoveredPlanets {
+		switch {
+		case !slices.Contains(planets, name):
+			errs = append(errs, fmt.Errorf("unknown planet: %q", name))
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("duplicate planet: %q", name))
+		}
+		seen[name] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// Size represents a raw byte size.
+type Size int64
+
+// String returns the size as a human-readable string.
+func (s Size) String() string {
+	const unit = 1024
+	if s < unit {
+		return fmt.Sprintf("%d B", s)
+	}
+
+	div, exp := int64(unit), 0
+	for n := s / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(s)/float64(div), "KMGTPE"[exp])
+}
+
+// UnverifiedRun represents a game run whose score could not be verified by replaying it.
+// It is kept apart from the leaderboard, flagged by the reason of the failed verification.
+type UnverifiedRun struct {
+	ID                uint64         `yaml:"id" json:"id" gorm:"primaryKey"`
+	CreatedAt         *time.Time     `yaml:"created_at,omitempty" json:"created_at,omitempty" gorm:"autoCreateTime;index"`
+	Name              string         `yaml:"name" json:"name" gorm:"index"`
+	Level             int64          `yaml:"level" json:"level"` // Level is the submitted level.
+	DiscoveredPlanets []string       `yaml:"discovered_planets" json:"discovered_planets" gorm:"serializer:json"`
+	Duration          time.Duration  `yaml:"duration" json:"duration"`
+	EnemyKills        int64          `yaml:"enemy_kills" json:"enemy_kills"`
+	CauseOfDeath      string         `yaml:"cause_of_death" json:"cause_of_death"`
+	Subject           string         `yaml:"-" json:"-"` // Subject is the subject of the session which submitted the run.
+	Flag              RunFlag        `yaml:"flag" json:"flag" gorm:"index"`
+	Reason            string         `yaml:"reason" json:"reason"`                                            // Reason describes the failed verification.
+	Replay            *config.Replay `yaml:"replay,omitempty" json:"replay,omitempty" gorm:"serializer:json"` // Replay is the submitted record of the game run, if any.
+}
+
+// Helper returns a helper for the database.
+func Helper(database *gorm.DB) helper {
+	return helper{database}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+)
+
+// contentEncodings are the encodings of the precompressed variants of the static assets in order of preference,
+// together with the file extension of the variants, see cmd/precompress.
+var contentEncodings = []struct{ name, extension string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// hasVariants reports whether the asset has a precompressed variant.
+func hasVariants(name string, exists func(string) bool) bool {
+	for _, encoding := range contentEncodings {
+		if exists(name + encoding.extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseAcceptEncoding returns the quality values of the encodings listed by the Accept-Encoding header.
+// Encodings without a quality value have the quality 1.
+func parseAcceptEncoding(header string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		quality := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				quality = parsed
+			}
+		}
+
+		qualities[name] = quality
+	}
+
+	return qualities
+}
+
+// selectVariant returns the variant of the asset to serve to a client sending the given Accept-Encoding header,
+// together with its content encoding.
+// The variant with the highest quality value is selected, ties are broken by the order of preference of the encodings.
+// The asset itself is returned with an empty encoding if the client accepts none of the variants,
+// or if it prefers the identity encoding.
+func selectVariant(acceptEncoding, name string, exists func(string) bool) (variant, encoding string) {
+	qualities := parseAcceptEncoding(acceptEncoding)
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+
+		return qualities["*"]
+	}
+
+	variant, best := name, 0.0
+	for _, candidate := range contentEncodings {
+		if q := quality(candidate.name); q > best && exists(name+candidate.extension) {
+			variant, encoding, best = name+candidate.extension, candidate.name, q
+		}
+	}
+
+	if q, ok := qualities["identity"]; ok && q > best {
+		return name, ""
+	}
+
+	return variant, encoding
+}
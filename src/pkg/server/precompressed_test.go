@@ -0,0 +1,138 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	brotli "github.com/andybalholm/brotli"
+	gzipmiddleware "github.com/gin-contrib/gzip"
+	gin "github.com/gin-gonic/gin"
+	dist "github.com/sarumaj/edu-space-invaders/dist"
+)
+
+func TestSelectVariant(t *testing.T) {
+	exists := func(name string) bool { return slices.Contains([]string{"a.js.br", "a.js.gz", "b.css.gz"}, name) }
+
+	for _, tt := range []struct {
+		name         string
+		accept       string
+		asset        string
+		wantVariant  string
+		wantEncoding string
+	}{
+		{"test#1", "gzip, deflate, br", "a.js", "a.js.br", "br"},
+		{"test#2", "gzip, deflate", "a.js", "a.js.gz", "gzip"},
+		{"test#3", "br;q=0.5, gzip;q=0.8", "a.js", "a.js.gz", "gzip"},
+		{"test#4", "br;q=0, gzip;q=0", "a.js", "a.js", ""},
+		{"test#5", "", "a.js", "a.js", ""},
+		{"test#6", "*", "a.js", "a.js.br", "br"},
+		{"test#7", "br", "b.css", "b.css", ""},
+		{"test#8", "BR, GZIP", "b.css", "b.css.gz", "gzip"},
+		{"test#9", "identity;q=1, gzip;q=0.5", "a.js", "a.js", ""},
+		{"test#10", "br", "c.html", "c.html", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			variant, encoding := selectVariant(tt.accept, tt.asset, exists)
+			if variant != tt.wantVariant || encoding != tt.wantEncoding {
+				t.Errorf("selectVariant(%q, %q) = (%q, %q), want (%q, %q)", tt.accept, tt.asset, variant, encoding, tt.wantVariant, tt.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	router := gin.New()
+	router.Use(CompressionMiddleware(gzipmiddleware.BestCompression), CacheControlMiddleware())
+	router.Match([]string{http.MethodHead, http.MethodGet}, "/*filepath", BeHeadMiddleware(), ServeFileSystem(nil))
+
+	hash := func(name string) string {
+		hash, ok := dist.LookupHash(name)
+		if !ok {
+			t.Fatalf("missing asset %s", name)
+		}
+		return hash
+	}
+
+	for _, tt := range []struct {
+		name         string
+		target       string
+		accept       string
+		ifNoneMatch  string
+		want         int
+		wantEncoding string
+		wantETag     string
+		wantVary     bool
+		wantAsset    string
+	}{
+		{"test#1", "/", "gzip, deflate, br", "", http.StatusOK, "br", hash("index.html.br"), true, "index.html"},
+		{"test#2", "/", "gzip", "", http.StatusOK, "gzip", hash("index.html.gz"), true, "index.html"},
+		{"test#3", "/", "", "", http.StatusOK, "", hash("index.html"), true, "index.html"},
+		{"test#4", "/a/" + hash("style.css") + "/style.css", "br", hash("style.css.br"), http.StatusNotModified, "", hash("style.css.br"), true, ""},
+		{"test#5", "/a/" + hash("style.css") + "/style.css", "br", hash("style.css"), http.StatusOK, "br", hash("style.css.br"), true, "style.css"},
+		{"test#6", "/a/" + hash("favicon.ico") + "/favicon.ico", "gzip", "", http.StatusOK, "gzip", hash("favicon.ico"), true, "favicon.ico"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.target, rec.Code, tt.want)
+			}
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("GET %s: Content-Encoding = %q, want %q", tt.target, got, tt.wantEncoding)
+			}
+
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("GET %s: ETag = %q, want %q", tt.target, got, tt.wantETag)
+			}
+
+			if got := rec.Header().Get("Vary"); (got == "Accept-Encoding") != tt.wantVary {
+				t.Errorf("GET %s: Vary = %q, want Accept-Encoding %v", tt.target, got, tt.wantVary)
+			}
+
+			if got := rec.Header().Get("Content-Type"); tt.want == http.StatusOK && strings.Contains(got, "octet-stream") {
+				t.Errorf("GET %s: Content-Type = %q, want the type of the asset", tt.target, got)
+			}
+
+			if tt.wantAsset == "" {
+				return
+			}
+
+			body := io.Reader(rec.Body)
+			switch tt.wantEncoding {
+			case "br":
+				body = brotli.NewReader(body)
+			case "gzip":
+				reader, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatalf("GET %s: invalid gzip body: %v", tt.target, err)
+				}
+				body = reader
+			}
+
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("GET %s: failed to read body: %v", tt.target, err)
+			}
+
+			want, err := fs.ReadFile(dist.HttpFS.FS(), tt.wantAsset)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != string(want) {
+				t.Errorf("GET %s: body differs from %s", tt.target, tt.wantAsset)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	zap "go.uber.org/zap"
+)
+
+const probeTimeout = 2 * time.Second // probeTimeout is the maximum duration of the checks of a probe.
+
+// probeCheck is a named check of a probe.
+type probeCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// probeResult is the outcome of a single check.
+type probeResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HandleProbe returns a handler running the checks concurrently and reporting the status of each check.
+// It responds with 200 if all checks pass and with 503 otherwise.
+// A probe without checks reports the liveness of the process.
+func HandleProbe(checks ...probeCheck) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), probeTimeout)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		results := make([]probeResult, len(checks))
+		for i, c := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				start := time.Now()
+				results[i] = probeResult{Status: "ok"}
+				if err := c.check(checkCtx); err != nil {
+					results[i] = probeResult{Status: "fail", Error: err.Error()}
+				}
+				results[i].Duration = time.Since(start).String()
+			}()
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		report := make(map[string]probeResult, len(checks))
+		for i, c := range checks {
+			report[c.name] = results[i]
+			if results[i].Status != "ok" {
+				status, code = "fail", http.StatusServiceUnavailable
+				getLogger(ctx).Warn("Probe check failed", zap.String("path", ctx.Request.URL.Path), zap.String("check", c.name), zap.String("error", results[i].Error))
+			}
+		}
+
+		ctx.JSON(code, gin.H{"status": status, "checks": report})
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestHandleProbe(t *testing.T) {
+	store := newMemoryStore()
+	failing := func(context.Context) error { return errors.New("database unreachable") }
+
+	for _, tt := range []struct {
+		name       string
+		args       []probeCheck
+		want       int
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{"test#1", nil, http.StatusOK, "ok", map[string]string{}},
+		{"test#2", []probeCheck{{"database", store.Ping}}, http.StatusOK, "ok", map[string]string{"database": "ok"}},
+		{"test#3", []probeCheck{{"database", failing}, {"keys", store.Ping}}, http.StatusServiceUnavailable, "fail", map[string]string{"database": "fail", "keys": "ok"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/readyz", HandleProbe(tt.args...))
+
+			rec := serve(router, http.MethodGet, "/readyz", "", "")
+			if rec.Code != tt.want {
+				t.Errorf("GET /readyz = %d, want %d", rec.Code, tt.want)
+			}
+
+			var got struct {
+				Status string                 `json:"status"`
+				Checks map[string]probeResult `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal() failed: %v", err)
+			}
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+
+			if len(got.Checks) != len(tt.wantChecks) {
+				t.Errorf("checks = %v, want %v", got.Checks, tt.wantChecks)
+			}
+
+			for name, status := range tt.wantChecks {
+				if got.Checks[name].Status != status {
+					t.Errorf("checks[%q] = %v, want status %q", name, got.Checks[name], status)
+				}
+
+				if status == "fail" && got.Checks[name].Error == "" {
+					t.Errorf("checks[%q] has no error", name)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHealthReadOnly(t *testing.T) {
+	store := newTestStore(t, Score{Name: "a", Score: 1})
+	router := gin.New()
+	router.GET("/health", HandleHealth(store))
+
+	if rec := serve(router, http.MethodGet, "/health", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /health = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if scores, _ := store.GetScores(); len(scores) != 1 {
+		t.Errorf("GetScores() = %v, want the score to be kept", scores)
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+
+	rate "golang.org/x/time/rate"
+)
+
+// RateLimit represents the token bucket of a client.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`  // Rate is the number of requests per second, requests are not limited if not positive.
+	Burst int     `yaml:"burst"` // Burst is the number of requests a client can make at once.
+}
+
+// LimitsConfig configures the rate limits per client and group of routes.
+type LimitsConfig struct {
+	API     RateLimit     `yaml:"api"`     // API is the limit of the API routes not covered by another group.
+	Admin   RateLimit     `yaml:"admin"`   // Admin is the limit of the admin endpoints.
+	Assets  RateLimit     `yaml:"assets"`  // Assets is the limit of the static assets.
+	Scores  RateLimit     `yaml:"scores"`  // Scores is the limit of the score submissions.
+	Clients int           `yaml:"clients"` // Clients is the maximum number of clients tracked.
+	TTL     time.Duration `yaml:"ttl"`     // TTL is the time after which idle clients are evicted.
+	Replay  time.Duration `yaml:"replay"`  // Replay is the maximum game time of the runs verified by replaying them.
+	Replays int           `yaml:"replays"` // Replays is the maximum number of runs replayed at once, each in a process of its own, the number of CPUs if zero.
+}
+
+// rateLimitStatus represents the state of the token bucket of a client after a request.
+type rateLimitStatus struct {
+	Allowed    bool
+	Limit      int           // Limit is the size of the token bucket.
+	Remaining  int           // Remaining is the number of requests which can be made immediately.
+	Reset      time.Duration // Reset is the time until the token bucket is full again.
+	RetryAfter time.Duration // RetryAfter is the time until the next request is allowed, if the request has been rejected.
+}
+
+// clientLimiter is the token bucket of a client within a group of routes.
+type clientLimiter struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	refill   time.Duration // refill is the time to refill the empty token bucket.
+}
+
+// rateLimiter holds a token bucket per client and group of routes.
+// The least recently used buckets are evicted if there are more buckets than the capacity
+// or if they have not been used for longer than the time to live.
+// Buckets are not evicted by the time to live before they could have been refilled, so that eviction does not reset a limit.
+type rateLimiter struct {
+	limits   map[string]RateLimit
+	capacity int
+	ttl      time.Duration
+
+	mutex   sync.Mutex
+	clients map[string]*list.Element
+	order   *list.List // order holds the buckets, the most recently used first.
+}
+
+// Allow takes a token from the bucket of the client within the group of routes.
+// Groups without a positive rate are not limited.
+func (l *rateLimiter) Allow(group, client string, now time.Time) (rateLimitStatus, bool) {
+	limit, ok := l.limits[group]
+	if !ok || limit.Rate <= 0 {
+		return rateLimitStatus{Allowed: true}, false
+	}
+
+	limiter := l.get(group+"\x00"+client, limit, now)
+	status := rateLimitStatus{Limit: limit.Burst}
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+		reservation.CancelAt(now)
+		status.RetryAfter = delay
+	} else {
+		status.Allowed = true
+	}
+
+	tokens := max(limiter.TokensAt(now), 0)
+	status.Remaining = int(math.Floor(tokens))
+	status.Reset = time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second))
+	return status, true
+}
+
+// Len returns the number of token buckets.
+func (l *rateLimiter) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.order.Len()
+}
+
+// get returns the token bucket for the key, creating it if necessary.
+func (l *rateLimiter) get(key string, limit RateLimit, now time.Time) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	defer l.evict(now)
+
+	if element, ok := l.clients[key]; ok {
+		client := element.Value.(*clientLimiter)
+		client.lastSeen = now
+		l.order.MoveToFront(element)
+		return client.limiter
+	}
+
+	client := &clientLimiter{
+		key:      key,
+		limiter:  rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
+		lastSeen: now,
+		refill:   time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second)),
+	}
+	l.clients[key] = l.order.PushFront(client)
+	return client.limiter
+}
+
+// evict removes the least recently used token buckets exceeding the capacity or the time to live.
+func (l *rateLimiter) evict(now time.Time) {
+	for element := l.order.Back(); element != nil; element = l.order.Back() {
+		client := element.Value.(*clientLimiter)
+		if l.order.Len() <= l.capacity && now.Sub(client.lastSeen) <= max(l.ttl, client.refill) {
+			return
+		}
+
+		l.order.Remove(element)
+		delete(l.clients, client.key)
+	}
+}
+
+// RateLimiter returns a rate limiter with the given limits per group of routes.
+// The burst of a limit is at least one.
+// At most capacity token buckets are held, buckets unused for longer than the time to live are evicted.
+func RateLimiter(limits map[string]RateLimit, capacity int, ttl time.Duration) *rateLimiter {
+	// A bucket must hold at least one token to allow any request.
+	buckets := make(map[string]RateLimit, len(limits))
+	for group, limit := range limits {
+		buckets[group] = RateLimit{Rate: limit.Rate, Burst: max(limit.Burst, 1)}
+	}
+
+	return &rateLimiter{
+		limits:   buckets,
+		capacity: max(capacity, 1),
+		ttl:      ttl,
+		clients:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := RateLimiter(map[string]RateLimit{
+		"api":      {Rate: 1, Burst: 2},
+		"disabled": {Rate: 0, Burst: 1},
+	}, 10, time.Minute)
+	now := time.Now()
+
+	for _, tt := range []struct {
+		name        string
+		group       string
+		client      string
+		at          time.Duration
+		want        bool
+		wantLimited bool
+		wantStatus  rateLimitStatus
+	}{
+		{"test#1", "api", "a", 0, true, true, rateLimitStatus{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+		{"test#2", "api", "a", 0, true, true, rateLimitStatus{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+		{"test#3", "api", "a", 0, false, true, rateLimitStatus{Limit: 2, Remaining: 0, Reset: 2 * time.Second, RetryAfter: time.Second}},
+		{"test#4", "api", "b", 0, true, true, rateLimitStatus{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+		{"test#5", "api", "a", time.Second, true, true, rateLimitStatus{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+		{"test#6", "disabled", "a", 0, true, false, rateLimitStatus{Allowed: true}},
+		{"test#7", "unknown", "a", 0, true, false, rateLimitStatus{Allowed: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, limited := limiter.Allow(tt.group, tt.client, now.Add(tt.at))
+			if got.Allowed != tt.want || limited != tt.wantLimited {
+				t.Errorf("Allow() = %t, %t, want %t, %t", got.Allowed, limited, tt.want, tt.wantLimited)
+			}
+
+			if got != tt.wantStatus {
+				t.Errorf("Allow() = %+v, want %+v", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		capacity int
+		ttl      time.Duration
+		clients  []string
+		at       time.Duration
+		want     int
+	}{
+		{"test#1", 10, time.Minute, []string{"a", "b", "c"}, 0, 4},
+		{"test#2", 2, time.Minute, []string{"a", "b", "c"}, 0, 2},
+		{"test#3", 10, time.Minute, []string{"a", "b", "c"}, 2 * time.Minute, 1},
+		{"test#4", 10, time.Millisecond, []string{"a", "b", "c"}, time.Second, 4}, // The buckets are not refilled before 10s.
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := RateLimiter(map[string]RateLimit{"api": {Rate: 1, Burst: 10}}, tt.capacity, tt.ttl)
+			now := time.Now()
+			for _, client := range tt.clients {
+				limiter.Allow("api", client, now)
+			}
+
+			limiter.Allow("api", "d", now.Add(tt.at))
+			if got := limiter.Len(); got != tt.want {
+				t.Errorf("Len() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitMiddleware(t *testing.T) {
+	limiter := RateLimiter(map[string]RateLimit{"api": {Rate: 0.5, Burst: 1}}, 10, time.Minute)
+
+	router := gin.New()
+	router.Use(LimitMiddleware(limiter,
+		func(ctx *gin.Context) string { return ctx.GetHeader("X-Group") },
+		func(ctx *gin.Context, _ string) string { return ctx.GetHeader("X-Subject") },
+		func(ctx *gin.Context) bool { return ctx.Request.URL.Path == "/livez" },
+	))
+	router.GET("/*path", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	request := func(target, group, client string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Group", group)
+		req.Header.Set("X-Subject", client)
+		return req
+	}
+
+	for _, tt := range []struct {
+		name       string
+		args       *http.Request
+		want       int
+		wantHeader map[string]string
+	}{
+		{"test#1", request("/api", "api", "a"), http.StatusNoContent, map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "2", "Retry-After": ""}},
+		{"test#2", request("/api", "api", "a"), http.StatusTooManyRequests, map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "2", "Retry-After": "2"}},
+		{"test#3", request("/api", "api", "b"), http.StatusNoContent, map[string]string{"RateLimit-Remaining": "0"}},
+		{"test#4", request("/livez", "api", "a"), http.StatusNoContent, map[string]string{"RateLimit-Limit": ""}},
+		{"test#5", request("/index.html", "assets", "a"), http.StatusNoContent, map[string]string{"RateLimit-Limit": ""}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, tt.args)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.args.URL, rec.Code, tt.want)
+			}
+
+			for key, value := range tt.wantHeader {
+				if got := rec.Header().Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+		})
+	}
+}
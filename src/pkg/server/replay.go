@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	config "github.com/sarumaj/edu-space-invaders/src/pkg/config"
+)
+
+// ErrInvalidReplay is returned by a Replayer if the replay is invalid, e.g. its inputs are not in the order of their frames.
+var ErrInvalidReplay = errors.New("invalid replay")
+
+// Replayer replays a game run headlessly and returns the high score the spaceship has reached, see ScoreSubmission.Verify.
+// It returns an error wrapping ErrInvalidReplay if the replay is invalid.
+// The replays of concurrent submissions run concurrently, see LimitsConfig.Replays.
+type Replayer func(ctx context.Context, replay config.Replay) (highScore int, err error)
+
+// ReplayResult is the outcome of a replay, written by the command of CommandReplayer.
+type ReplayResult struct {
+	HighScore int    `json:"high_score"`
+	Error     string `json:"error,omitempty"` // Error is the reason why the replay is invalid.
+}
+
+// CommandReplayer returns a Replayer running the command once per replay, e.g. the replay run subcommand of the server binary,
+// so that the replays run concurrently without sharing the game state of a process.
+// The replay is written as JSON to the standard input of the command, which writes the ReplayResult as JSON to its standard output.
+// The command runs without the environment variables of the server and is killed once the context is done.
+func CommandReplayer(name string, args ...string) Replayer {
+	return func(ctx context.Context, replay config.Replay) (int, error) {
+		input, err := json.Marshal(replay)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode the replay: %w", err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Env = []string{} // The game flags of the server must not affect the replay.
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &stdout, &stderr
+
+		if err := cmd.Run(); err != nil {
+			return 0, fmt.Errorf("failed to run the replay command: %w: %s", errors.Join(err, context.Cause(ctx)), strings.TrimSpace(stderr.String()))
+		}
+
+		var result ReplayResult
+		if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+			return 0, fmt.Errorf("failed to read the result of the replay command: %w", err)
+		}
+
+		if result.Error != "" {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidReplay, result.Error)
+		}
+
+		return result.HighScore, nil
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	config "github.com/sarumaj/edu-space-invaders/src/pkg/config"
+)
+
+func TestCommandReplayer(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name    string
+		ctx     context.Context
+		script  string
+		want    int
+		wantErr bool
+		wantIs  error
+	}{
+		{"test#1", context.Background(), `cat > /dev/null; echo '{"high_score": 3}'`, 3, false, nil},
+		{"test#2", context.Background(), `cat > /dev/null; echo '{"error": "input at frame 2 is out of order"}'`, 0, true, ErrInvalidReplay},
+		{"test#3", context.Background(), `grep -q '"seed":2' && test -z "$SPACE_INVADERS_TEST" && echo '{"high_score": 1}'`, 1, false, nil},
+		{"test#4", context.Background(), `echo failure >&2; exit 1`, 0, true, nil},
+		{"test#5", context.Background(), `echo '{'`, 0, true, nil},
+		{"test#6", canceled, `sleep 10`, 0, true, context.Canceled},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SPACE_INVADERS_TEST", "1")
+
+			ctx, cancel := context.WithTimeout(tt.ctx, 5*time.Second)
+			defer cancel()
+
+			got, err := CommandReplayer("sh", "-c", tt.script)(ctx, config.Replay{Seed: 2})
+			if (err != nil) != tt.wantErr || (tt.wantIs != nil && !errors.Is(err, tt.wantIs)) || got != tt.want {
+				t.Errorf("CommandReplayer() = (%d, %v), want (%d, error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	zap "go.uber.org/zap"
+)
+
+const retentionHistory = 20 // retentionHistory is the number of reports kept by the retention scheduler.
+
+// RetentionConfig configures the retention scheduler.
+type RetentionConfig struct {
+	DryRun           bool          `yaml:"dry_run"`            // DryRun reports the rows to be deleted without deleting them.
+	Interval         time.Duration `yaml:"interval"`           // Interval is the interval between two runs.
+	KeepScores       int           `yaml:"keep_scores"`        // KeepScores is the number of highest scores kept once the database exceeds its size limit.
+	MaxSize          Size          `yaml:"max_size"`           // MaxSize is the size limit of the database in bytes.
+	MetricAge        time.Duration `yaml:"metric_age"`         // MetricAge is the age after which metrics which have not been updated are deleted, metrics are kept forever if zero.
+	UnverifiedRunAge time.Duration `yaml:"unverified_run_age"` // UnverifiedRunAge is the age after which unverified runs are deleted, they are kept until the database exceeds its size limit if zero.
+}
+
+// Validate validates the configuration.
+// It reports all invalid settings at once.
+func (c RetentionConfig) Validate() error {
+	var errs []error
+	if c.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("retention interval must be positive: %s", c.Interval))
+	}
+
+	if c.KeepScores < 1 {
+		errs = append(errs, fmt.Errorf("number of scores to keep must be positive: %d", c.KeepScores))
+	}
+
+	if c.MaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("maximum database size must be positive: %d", c.MaxSize))
+	}
+
+	if c.MetricAge < 0 {
+		errs = append(errs, fmt.Errorf("metric age must not be negative: %s", c.MetricAge))
+	}
+
+	if c.UnverifiedRunAge < 0 {
+		errs = append(errs, fmt.Errorf("unverified run age must not be negative: %s", c.UnverifiedRunAge))
+	}
+
+	return errors.Join(errs...)
+}
+
+// RetentionScheduler applies the retention policy to the store in the background.
+// Metrics not updated within the metric age and unverified runs older than the unverified run age are deleted on every run.
+// Scores below the highest scores to keep and all unverified runs are deleted only when the database exceeds its size limit.
+type RetentionScheduler struct {
+	store  Store
+	config RetentionConfig
+	logger *zap.Logger
+
+	running sync.Mutex // running serializes the runs.
+	mutex   sync.RWMutex
+	reports []RetentionReport // reports are the most recent reports, the newest first.
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Close stops the scheduler.
+// It waits for a running run to complete.
+func (s *RetentionScheduler) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
+
+// Config returns the configuration of the scheduler.
+func (s *RetentionScheduler) Config() RetentionConfig { return s.config }
+
+// Reports returns the reports of the most recent runs, the newest first.
+func (s *RetentionScheduler) Reports() []RetentionReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return slices.Clone(s.reports)
+}
+
+// Run applies the retention policy once.
+// If the size of the database cannot be determined, the policy is applied without the size limit and the error is reported.
+// If dryRun is true, the rows to be deleted are reported without deleting them regardless of the configuration.
+// The report is logged and kept for the admin endpoint.
+func (s *RetentionScheduler) Run(dryRun bool) (RetentionReport, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	start := time.Now()
+	policy := RetentionPolicy{DryRun: dryRun || s.config.DryRun}
+	if s.config.MetricAge > 0 {
+		policy.MetricsUpdatedBefore = start.Add(-s.config.MetricAge)
+	}
+
+	if s.config.UnverifiedRunAge > 0 {
+		policy.UnverifiedRunsCreatedBefore = start.Add(-s.config.UnverifiedRunAge)
+	}
+
+	// The rows past their age are deleted even if the size of the database is unknown.
+	size, sizeErr := s.store.GetDatabaseSize()
+	if sizeErr == nil && size >= s.config.MaxSize {
+		policy.KeepTopScores = s.config.KeepScores
+		policy.UnverifiedRunsCreatedBefore = start
+	}
+
+	report, err := s.store.Prune(policy)
+	if sizeErr != nil {
+		err = errors.Join(fmt.Errorf("failed to get the database size: %w", sizeErr), err)
+	}
+
+	report.StartedAt, report.Duration = start, time.Since(start).String()
+	report.Size, report.SizeExceeded = size, policy.KeepTopScores > 0
+
+	fields := []zap.Field{
+		zap.Bool("dryRun", report.DryRun),
+		zap.Stringer("size", report.Size),
+		zap.Bool("sizeExceeded", report.SizeExceeded),
+		zap.Int64("metrics", report.Metrics),
+		zap.Int64("scores", report.Scores),
+		zap.Int64("runs", report.Runs),
+		zap.Int64("unverifiedRuns", report.UnverifiedRuns),
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+		s.logger.Error("Failed to apply retention policy", append(fields, zap.Error(err))...)
+	} else {
+		s.logger.Info("Retention policy applied", fields...)
+	}
+
+	s.mutex.Lock()
+	s.reports = append([]RetentionReport{report}, s.reports[:min(len(s.reports), retentionHistory-1)]...)
+	s.mutex.Unlock()
+
+	return report, err
+}
+
+// run applies the retention policy every interval until the scheduler is closed.
+func (s *RetentionScheduler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Scheduled runs deleting rows are recorded in the audit log, manual runs are recorded by the AuditMiddleware.
+			report, err := s.Run(false)
+			if !report.DryRun && (err != nil || report.Metrics+report.Scores+report.Runs+report.UnverifiedRuns > 0) {
+				auditor{store: s.store, logger: s.logger}.Record(AuditEvent{
+					OccurredAt: report.StartedAt,
+					Actor:      auditActorSystem,
+					Action:     auditActionRetention,
+					Target:     "retention",
+				}, nil, report)
+			}
+
+		case <-s.stop:
+			return
+
+		}
+	}
+}
+
+// newRetentionScheduler starts a scheduler applying the retention policy to the store every interval.
+// The first run takes place after the first interval has elapsed. The runs are logged by the logger.
+func newRetentionScheduler(store Store, config RetentionConfig, logger *zap.Logger) (*RetentionScheduler, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &RetentionScheduler{
+		store:  store,
+		config: config,
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go s.run()
+	return s, nil
+}
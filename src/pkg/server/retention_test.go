@@ -0,0 +1,207 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	zap "go.uber.org/zap"
+)
+
+func newTestRetentionScheduler(t *testing.T, store Store, config RetentionConfig) *RetentionScheduler {
+	t.Helper()
+
+	scheduler, err := newRetentionScheduler(store, config, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newRetentionScheduler() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = scheduler.Close() })
+
+	return scheduler
+}
+
+func TestRetentionConfigValidate(t *testing.T) {
+	valid := RetentionConfig{Interval: time.Minute, KeepScores: 10, MaxSize: 1, MetricAge: time.Hour}
+
+	for _, tt := range []struct {
+		name    string
+		args    func(RetentionConfig) RetentionConfig
+		wantErr bool
+	}{
+		{"test#1", func(c RetentionConfig) RetentionConfig { return c }, false},
+		{"test#2", func(c RetentionConfig) RetentionConfig { c.MetricAge = 0; return c }, false},
+		{"test#3", func(c RetentionConfig) RetentionConfig { c.Interval = 0; return c }, true},
+		{"test#4", func(c RetentionConfig) RetentionConfig { c.KeepScores = 0; return c }, true},
+		{"test#5", func(c RetentionConfig) RetentionConfig { c.MaxSize = 0; return c }, true},
+		{"test#6", func(c RetentionConfig) RetentionConfig { c.MetricAge = -time.Second; return c }, true},
+		{"test#7", func(c RetentionConfig) RetentionConfig { c.UnverifiedRunAge = -time.Second; return c }, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.args(valid).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetentionSchedulerRun(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		config         RetentionConfig
+		dryRun         bool
+		want           RetentionReport
+		wantScores     int
+		wantMetrics    int
+		wantUnverified int
+	}{
+		{"test#1", RetentionConfig{Interval: time.Hour, KeepScores: 1, MaxSize: 1 << 30, MetricAge: time.Hour},
+			false, RetentionReport{}, 3, 2, 1},
+		{"test#2", RetentionConfig{Interval: time.Hour, KeepScores: 1, MaxSize: 1, MetricAge: time.Nanosecond},
+			false, RetentionReport{SizeExceeded: true, Metrics: 2, Scores: 2, Runs: 1, UnverifiedRuns: 1}, 1, 0, 0},
+		{"test#3", RetentionConfig{Interval: time.Hour, KeepScores: 1, MaxSize: 1, MetricAge: time.Nanosecond},
+			true, RetentionReport{DryRun: true, SizeExceeded: true, Metrics: 2, Scores: 2, Runs: 1, UnverifiedRuns: 1}, 3, 2, 1},
+		{"test#4", RetentionConfig{DryRun: true, Interval: time.Hour, KeepScores: 2, MaxSize: 1},
+			false, RetentionReport{DryRun: true, SizeExceeded: true, Scores: 1, Runs: 1, UnverifiedRuns: 1}, 3, 2, 1},
+		{"test#5", RetentionConfig{Interval: time.Hour, KeepScores: 1, MaxSize: 1 << 30, UnverifiedRunAge: time.Nanosecond},
+			false, RetentionReport{UnverifiedRuns: 1}, 3, 2, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, Score{Name: "a", Score: 3}, Score{Name: "b", Score: 2})
+			if err := store.SaveRun(ScoreRun{Name: "c", Level: 1}); err != nil {
+				t.Fatalf("SaveRun() failed: %v", err)
+			}
+
+			if err := store.SaveUnverifiedRun(UnverifiedRun{Name: "d", Level: 1, Flag: RunFlagMissingReplay}); err != nil {
+				t.Fatalf("SaveUnverifiedRun() failed: %v", err)
+			}
+
+			if err := store.SaveMetrics([]Metric{{Endpoint: "/a", Method: "GET", Count: 1}, {Endpoint: "/b", Method: "GET", Count: 1}}); err != nil {
+				t.Fatalf("SaveMetrics() failed: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+
+			scheduler := newTestRetentionScheduler(t, store, tt.config)
+			got, err := scheduler.Run(tt.dryRun)
+			if err != nil {
+				t.Fatalf("Run() failed: %v", err)
+			}
+
+			if got.DryRun != tt.want.DryRun || got.SizeExceeded != tt.want.SizeExceeded ||
+				got.Metrics != tt.want.Metrics || got.Scores != tt.want.Scores || got.Runs != tt.want.Runs ||
+				got.UnverifiedRuns != tt.want.UnverifiedRuns {
+				t.Errorf("Run() = %+v, want %+v", got, tt.want)
+			}
+
+			if scores, _ := store.GetScores(); len(scores) != tt.wantScores {
+				t.Errorf("GetScores() = %v, want %d scores", scores, tt.wantScores)
+			}
+
+			if metrics, _ := store.GetMetrics(); len(metrics) != tt.wantMetrics {
+				t.Errorf("GetMetrics() = %v, want %d metrics", metrics, tt.wantMetrics)
+			}
+
+			if len(store.unverified) != tt.wantUnverified {
+				t.Errorf("unverified runs = %v, want %d runs", store.unverified, tt.wantUnverified)
+			}
+
+			if reports := scheduler.Reports(); len(reports) != 1 || reports[0].Scores != got.Scores {
+				t.Errorf("Reports() = %v, want the report of the run", reports)
+			}
+		})
+	}
+}
+
+// sizelessStore is a store which fails to determine its size.
+type sizelessStore struct {
+	*memoryStore
+}
+
+func (sizelessStore) GetDatabaseSize() (Size, error) { return 0, errors.New("size unknown") }
+
+func TestRetentionSchedulerRunSizeError(t *testing.T) {
+	store := newTestStore(t, Score{Name: "a", Score: 2}, Score{Name: "b", Score: 1})
+	if err := store.SaveUnverifiedRun(UnverifiedRun{Name: "c", Level: 1, Flag: RunFlagMissingReplay}); err != nil {
+		t.Fatalf("SaveUnverifiedRun() failed: %v", err)
+	}
+
+	if err := store.SaveMetrics([]Metric{{Endpoint: "/a", Method: "GET", Count: 1}}); err != nil {
+		t.Fatalf("SaveMetrics() failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	scheduler := newTestRetentionScheduler(t, sizelessStore{store}, RetentionConfig{
+		Interval: time.Hour, KeepScores: 1, MaxSize: 1, MetricAge: time.Nanosecond, UnverifiedRunAge: time.Nanosecond,
+	})
+
+	got, err := scheduler.Run(false)
+	if err == nil || !strings.Contains(got.Error, "size unknown") {
+		t.Errorf("Run() error = %v, report error %q, want the size error", err, got.Error)
+	}
+
+	if got.SizeExceeded || got.Metrics != 1 || got.Scores != 0 || got.UnverifiedRuns != 1 {
+		t.Errorf("Run() = %+v, want the metric and the unverified run deleted by age only", got)
+	}
+
+	if scores, _ := store.GetScores(); len(scores) != 2 {
+		t.Errorf("GetScores() = %v, want 2 scores", scores)
+	}
+}
+
+func TestRetentionSchedulerAudit(t *testing.T) {
+	store := newTestStore(t, Score{Name: "a", Score: 1})
+	if err := store.SaveUnverifiedRun(UnverifiedRun{Name: "b", Level: 1, Flag: RunFlagMissingReplay}); err != nil {
+		t.Fatalf("SaveUnverifiedRun() failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	// The scheduled run deletes the unverified run only.
+	newTestRetentionScheduler(t, store, RetentionConfig{Interval: 10 * time.Millisecond, KeepScores: 1, MaxSize: 1 << 30, UnverifiedRunAge: time.Nanosecond})
+
+	var events []AuditEvent
+	for deadline := time.Now().Add(2 * time.Second); len(events) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		events, _ = store.GetAuditEvents(AuditQuery{Action: auditActionRetention})
+	}
+
+	if len(events) == 0 || events[0].Actor != auditActorSystem || !strings.Contains(string(events[0].After), `"unverified_runs":1`) {
+		t.Errorf("GetAuditEvents() = %+v, want the scheduled run deleting the unverified run", events)
+	}
+}
+
+func TestRetentionHandlers(t *testing.T) {
+	store := newTestStore(t, Score{Name: "a", Score: 2}, Score{Name: "b", Score: 1})
+	scheduler := newTestRetentionScheduler(t, store, RetentionConfig{Interval: time.Hour, KeepScores: 1, MaxSize: 1})
+
+	router := gin.New()
+	router.POST("/api/v1/admin/retention", RunRetention(scheduler))
+	router.GET("/api/v1/admin/retention", GetRetention(scheduler))
+
+	for _, tt := range []struct {
+		name       string
+		method     string
+		target     string
+		want       int
+		wantScores int
+	}{
+		{"test#1", http.MethodPost, "/api/v1/admin/retention?dry_run=true", http.StatusOK, 2},
+		{"test#2", http.MethodPost, "/api/v1/admin/retention?dry_run=maybe", http.StatusBadRequest, 2},
+		{"test#3", http.MethodPost, "/api/v1/admin/retention", http.StatusOK, 1},
+		{"test#4", http.MethodGet, "/api/v1/admin/retention", http.StatusOK, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(router, tt.method, tt.target, "", ""); rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+			}
+
+			if scores, _ := store.GetScores(); len(scores) != tt.wantScores {
+				t.Errorf("GetScores() = %v, want %d scores", scores, tt.wantScores)
+			}
+		})
+	}
+
+	if reports := scheduler.Reports(); len(reports) != 2 || reports[0].DryRun || !reports[1].DryRun {
+		t.Errorf("Reports() = %v, want the real run before the dry run", reports)
+	}
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	gzip "github.com/gin-contrib/gzip"
+	ginzap "github.com/gin-contrib/zap"
+	gin "github.com/gin-gonic/gin"
+	zap "go.uber.org/zap"
+	zapcore "go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultEndpoint = "index.html"      // defaultEndpoint is the default endpoint.
+	envVarPrefix    = "SPACE_INVADERS_" // envVarPrefix is the prefix for the environment variables.
+	sessionCookie   = "session"         // sessionCookie is the name of the session cookie.
+)
+
+// sessionDuration is the duration of the sessions, which are renewed while in use.
+// The subject of a session is the identifier of its player, see ClaimName.
+const sessionDuration = 30 * 24 * time.Hour
+
+// errShutdown is the cause of the readiness check failing once the server is shutting down.
+var errShutdown = errors.New("server is shutting down")
+
+// Options configures the game server.
+// Zero settings which would be invalid, e.g. the flush interval of the request counts,
+// are replaced by those of DefaultOptions. Zero rate limits disable the limits.
+type Options struct {
+	Store     Store           // Store persists the game data, see OpenStore. It is not closed by the server.
+	Keys      *KeySet         // Keys sign and verify the JWT tokens and encrypt the session cookies, see OpenKeySet and StaticKeySet.
+	Replayer  Replayer        // Replayer replays the submitted game runs to verify their scores, see CommandReplayer.
+	Logger    *zap.Logger     // Logger logs the requests and the failures, nothing is logged if nil.
+	Environ   []string        // Environ are the environment variables (KEY=VALUE), those prefixed with SPACE_INVADERS_ are the default feature flags.
+	Limits    LimitsConfig    // Limits are the rate limits per client and group of routes.
+	Metrics   MetricsConfig   // Metrics configures the request counts and the Prometheus metrics.
+	Retention RetentionConfig // Retention configures the retention scheduler.
+	Secure    bool            // Secure redirects to HTTPS and enables HSTS.
+
+	// TrustedProxies are the IP addresses and CIDR ranges of the reverse proxies, whose X-Forwarded-For header
+	// is trusted to identify the client. If empty, clients are identified by the remote address of the connection.
+	TrustedProxies []string
+}
+
+// withDefaults returns the options with the zero settings replaced by the defaults.
+func (o Options) withDefaults() Options {
+	defaults := DefaultOptions()
+	o.Logger = cmp.Or(o.Logger, zap.NewNop())
+	o.Limits.Clients = cmp.Or(o.Limits.Clients, defaults.Limits.Clients)
+	o.Limits.Replay = cmp.Or(o.Limits.Replay, defaults.Limits.Replay)
+	o.Limits.Replays = cmp.Or(o.Limits.Replays, runtime.NumCPU())
+	o.Metrics.Buffer = cmp.Or(o.Metrics.Buffer, defaults.Metrics.Buffer)
+	o.Metrics.FlushInterval = cmp.Or(o.Metrics.FlushInterval, defaults.Metrics.FlushInterval)
+	o.Metrics.FlushSize = cmp.Or(o.Metrics.FlushSize, defaults.Metrics.FlushSize)
+	o.Retention.Interval = cmp.Or(o.Retention.Interval, defaults.Retention.Interval)
+	o.Retention.KeepScores = cmp.Or(o.Retention.KeepScores, defaults.Retention.KeepScores)
+	o.Retention.MaxSize = cmp.Or(o.Retention.MaxSize, defaults.Retention.MaxSize)
+	return o
+}
+
+// DefaultOptions returns the default settings of the game server, without a store and keys.
+func DefaultOptions() Options {
+	return Options{
+		Limits: LimitsConfig{
+			API:     RateLimit{Rate: 10, Burst: 20},
+			Admin:   RateLimit{Rate: 1, Burst: 10},
+			Assets:  RateLimit{Rate: 20, Burst: 100},
+			Scores:  RateLimit{Rate: 0.2, Burst: 5},
+			Clients: 10000,
+			TTL:     10 * time.Minute,
+			Replay:  20 * time.Minute,
+		},
+		Metrics: MetricsConfig{
+			Buffer:        1000,
+			FlushInterval: 10 * time.Second,
+			FlushSize:     500,
+		},
+		Retention: RetentionConfig{
+			Interval:         10 * time.Minute,
+			KeepScores:       1000,
+			MaxSize:          sizeThreshold,
+			MetricAge:        30 * 24 * time.Hour,
+			UnverifiedRunAge: 7 * 24 * time.Hour,
+		},
+	}
+}
+
+// Server is the game server: the handler of the static files of the game and of its API,
+// together with the background jobs saving the request counts and applying the retention policy.
+type Server struct {
+	handler     http.Handler
+	aggregator  *metricAggregator
+	broker      *EventBroker
+	instruments *instrumentation
+	retention   *RetentionScheduler
+
+	shutdown context.Context // shutdown is canceled once the server is drained.
+	drain    context.CancelCauseFunc
+}
+
+// Close drains the server and stops the background jobs, saving the last batch of request counts.
+// The store is not closed.
+func (s *Server) Close() error {
+	s.Drain()
+	return errors.Join(s.retention.Close(), s.aggregator.Close())
+}
+
+// Drain prepares the shutdown: the server reports not to be ready and the event streams are ended,
+// so that they do not hold up the in-flight requests, see http.Server.RegisterOnShutdown.
+func (s *Server) Drain() {
+	s.drain(errShutdown)
+	s.broker.Close()
+}
+
+// MetricsHandler returns the handler serving the Prometheus metrics without authentication.
+// It is meant to be served on the separate address of MetricsConfig.Address.
+func (s *Server) MetricsHandler() http.Handler {
+	return s.instruments.Handler()
+}
+
+// ServeHTTP serves the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// New returns the game server.
+// The store is instrumented, and the changes of the feature flags and the new top scores are pushed to the event streams.
+// The server must be closed to stop its background jobs.
+func New(options Options) (*Server, error) {
+	switch {
+	case options.Store == nil:
+		return nil, fmt.Errorf("missing store")
+	case options.Keys == nil:
+		return nil, fmt.Errorf("missing keys")
+	case options.Replayer == nil:
+		return nil, fmt.Errorf("missing replayer")
+	}
+
+	options = options.withDefaults()
+	logger := options.Logger
+
+	// Instrument the store and the router.
+	instruments := Instrumentation(logger)
+	store := instruments.Instrument(options.Store)
+
+	// Push the changes of the feature flags, the new top scores and the notices of the operators to the clients.
+	broker := newEventBroker(logger)
+	store = broker.Wrap(store)
+
+	// Apply the retention policy in the background.
+	retention, err := newRetentionScheduler(store, options.Retention, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention configuration: %w", err)
+	}
+
+	// Save the request counts in batches.
+	aggregator := MetricAggregator(store, options.Metrics.FlushInterval, options.Metrics.FlushSize, options.Metrics.Buffer, logger)
+	instruments.InstrumentAggregator(aggregator)
+
+	s := &Server{aggregator: aggregator, broker: broker, instruments: instruments, retention: retention}
+	s.shutdown, s.drain = context.WithCancelCause(context.Background())
+
+	// Define the skipper function.
+	skipper := func(c *gin.Context) bool {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			switch strings.TrimSuffix(c.Request.URL.Path, "/") {
+			case "/health", "/livez", "/readyz", "/.env":
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// Configure router.
+	router := gin.New(func(e *gin.Engine) {
+		e.Use(LoggerMiddleware(logger))
+		e.Use(instruments.Middleware())
+		e.Use(ginzap.GinzapWithConfig(logger, &ginzap.Config{
+			TimeFormat:   time.RFC3339,
+			UTC:          true,
+			DefaultLevel: zapcore.DebugLevel,
+			Skipper:      skipper,
+			Context:      func(ctx *gin.Context) []zapcore.Field { return []zapcore.Field{zap.Any("headers", ctx.Request.Header)} },
+		}))
+		e.Use(ginzap.CustomRecoveryWithZap(logger, true, func(c *gin.Context, err any) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%v", err)})
+		}))
+	})
+
+	if err := router.SetTrustedProxies(options.TrustedProxies); err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid trusted proxies: %w", err), s.Close())
+	}
+
+	keys := options.Keys
+	jwtSources := map[string]string{
+		"header": "Authorization",
+		"query":  "token",
+		"cookie": sessionCookie,
+	}
+	jwtAuthenticator := AuthenticatorMiddleware(keys, jwtSources)
+	jwtOptionalAuthenticator := OptionalAuthenticatorMiddleware(keys, jwtSources)
+
+	// Limit the requests per client and group of routes.
+	// Clients are identified by their IP address, since anyone obtains a new session by dropping the session cookie.
+	// Only the tokens granting more than the player scope are issued by the operators and identify the client by their subject.
+	limiter := RateLimiter(map[string]RateLimit{
+		"admin":  options.Limits.Admin,
+		"api":    options.Limits.API,
+		"assets": options.Limits.Assets,
+		"scores": options.Limits.Scores,
+	}, options.Limits.Clients, options.Limits.TTL)
+	instruments.InstrumentRateLimiter(limiter)
+
+	authenticate := newAuthenticator(keys, jwtSources)
+	limitClient := func(c *gin.Context, group string) string {
+		// The static assets are limited by IP address without verifying the token of every request.
+		if group == "assets" {
+			return "ip:" + c.ClientIP()
+		}
+
+		if claims, err := authenticate(c); err == nil && claims.Subject != "" &&
+			slices.ContainsFunc(claims.Scopes(), func(scope string) bool { return scope != ScopePlayer }) {
+
+			return "subject:" + claims.Subject
+		}
+
+		return "ip:" + c.ClientIP()
+	}
+
+	limitGroup := func(c *gin.Context) string {
+		method, path := c.Request.Method, strings.TrimSuffix(c.Request.URL.Path, "/")
+		switch {
+		case strings.HasPrefix(path, "/api/v1/admin/"), path == "/metrics",
+			method == http.MethodPut && path == "/scores.db",
+			method == http.MethodPost && path == "/.env":
+			return "admin"
+
+		case method == http.MethodPost && path == "/scores":
+			return "scores"
+
+		case strings.HasPrefix(path, "/api/"), slices.Contains([]string{"/health", "/.env", "/config.ini", "/scores.db"}, path):
+			return "api"
+
+		case method == http.MethodGet || method == http.MethodHead:
+			return "assets"
+
+		default:
+			return "api"
+
+		}
+	}
+
+	// The probes of the orchestrator are not limited.
+	limitSkipper := func(c *gin.Context) bool {
+		switch strings.TrimSuffix(c.Request.URL.Path, "/") {
+		case "/livez", "/readyz":
+			return true
+		}
+
+		return false
+	}
+
+	// The server is ready to serve requests if the database is reachable and migrated, the keys are loaded,
+	// and it is not shutting down.
+	readinessChecks := []probeCheck{
+		{"database", store.Ping},
+		{"schema", func(context.Context) error { return store.CheckSchema() }},
+		{"keys", func(context.Context) error {
+			if signers, ciphers := keys.IDs(); len(signers) == 0 || len(ciphers) == 0 {
+				return fmt.Errorf("no keys loaded")
+			}
+			return nil
+		}},
+		{"shutdown", func(context.Context) error { return context.Cause(s.shutdown) }},
+	}
+
+	// Register the routes.
+	router.Use(
+		RequestIDMiddleware(),
+		ApplySecurityHeadersMiddleware(options.Secure),
+		CrossOriginResourceSharingMiddleware(options.Secure),
+		SessionMiddleware(keys, sessionCookie, sessionDuration),
+		CompressionMiddleware(gzip.BestCompression, "/api/v1/events"),
+		MetricsMiddleware(aggregator, skipper),
+		HttpsRedirectMiddleware(options.Secure),
+		CacheControlMiddleware(),
+		LimitMiddleware(limiter, limitGroup, limitClient, limitSkipper),
+		AuditMiddleware(store),
+	)
+
+	router.POST("/.env", jwtAuthenticator, RequireScopesMiddleware(ScopeConfigWrite), HandleEnv(store, options.Environ))
+	router.POST("/scores", jwtAuthenticator, RequireScopesMiddleware(ScopePlayer), SubmitScore(store, options.Replayer, options.Limits.Replay, options.Limits.Replays))
+	router.POST("/api/v1/session/player", jwtAuthenticator, RequireScopesMiddleware(ScopePlayer), ClaimName(store))
+	router.POST("/api/v1/session/player/recovery-code", jwtAuthenticator, RequireScopesMiddleware(ScopePlayer), RenewRecoveryCode(store))
+	router.POST("/api/v1/session/recover", RecoverPlayer(store, keys, sessionCookie, sessionDuration))
+	router.POST("/api/v1/admin/players/:name/recovery-code", jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), IssueRecoveryCode(store))
+	router.POST("/api/v1/admin/retention", jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), RunRetention(retention))
+	router.POST("/api/v1/admin/import/scores", jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), ImportScores(store))
+	router.POST("/api/v1/admin/notices", jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), PostNotice(broker))
+	router.PUT("/scores.db", jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), SaveScores(store))
+	conflicting := map[*regexp.Regexp]gin.HandlersChain{
+		regexp.MustCompile(`^/?health/?$`):      {HandleHealth(store)},
+		regexp.MustCompile(`^/?livez/?$`):       {HandleProbe()},
+		regexp.MustCompile(`^/?readyz/?$`):      {HandleProbe(readinessChecks...)},
+		regexp.MustCompile(`^/?config\.ini/?$`): {jwtAuthenticator, RequireScopesMiddleware(ScopeConfigRead), GetConfig()},
+		regexp.MustCompile(`^/?\.env/?$`):       {jwtAuthenticator, RequireScopesMiddleware(ScopePlayer), HandleEnv(store, options.Environ)},
+		regexp.MustCompile(`^/?scores\.db/?$`):  {GetScores(store)},
+
+		regexp.MustCompile(`^/?api/v1/openapi\.yaml/?$`):                 {GetOpenAPI()},
+		regexp.MustCompile(`^/?api/v1/leaderboard/?$`):                   {jwtOptionalAuthenticator, GetLeaderboard(store)},
+		regexp.MustCompile(`^/?api/v1/players/(?P<name>[^/]+)/?$`):       {GetPlayer(store)},
+		regexp.MustCompile(`^/?api/v1/session/player/?$`):                {jwtAuthenticator, RequireScopesMiddleware(ScopePlayer), GetSessionPlayer(store)},
+		regexp.MustCompile(`^/?api/v1/events/?$`):                        {jwtAuthenticator, RequireScopesMiddleware(ScopePlayer), StreamEvents(broker, store)},
+		regexp.MustCompile(`^/?api/v1/flags/history/?$`):                 {jwtAuthenticator, RequireScopesMiddleware(ScopeConfigRead), GetFlagHistory(store)},
+		regexp.MustCompile(`^/?api/v1/admin/retention/?$`):               {jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), GetRetention(retention)},
+		regexp.MustCompile(`^/?api/v1/admin/audit/?$`):                   {jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), GetAuditEvents(store)},
+		regexp.MustCompile(`^/?api/v1/admin/export/(?P<table>[^/]+)/?$`): {jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), ExportTable(store)},
+	}
+
+	if options.Metrics.Address == "" {
+		conflicting[regexp.MustCompile(`^/?metrics/?$`)] = gin.HandlersChain{jwtAuthenticator, RequireScopesMiddleware(ScopeAdmin), gin.WrapH(instruments.Handler())}
+	}
+
+	router.Match([]string{http.MethodHead, http.MethodGet}, "/*filepath", BeHeadMiddleware(), ServeFileSystem(conflicting))
+
+	s.handler = router.Handler()
+	return s, nil
+}
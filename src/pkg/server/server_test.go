@@ -0,0 +1,134 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	keys := newTestKeySet(t, "test")
+
+	for _, tt := range []struct {
+		name    string
+		args    Options
+		wantErr bool
+	}{
+		{"test#1", Options{Store: newMemoryStore(), Keys: keys, Replayer: replayInProcess}, false},
+		{"test#2", Options{Store: newMemoryStore(), Keys: keys, Replayer: replayInProcess, Metrics: MetricsConfig{Address: ":0"}}, false},
+		{"test#3", Options{Keys: keys}, true},
+		{"test#4", Options{Store: newMemoryStore()}, true},
+		{"test#5", Options{Store: newMemoryStore(), Keys: keys, Replayer: replayInProcess, Retention: RetentionConfig{MetricAge: -1}}, true},
+		{"test#6", Options{Store: newMemoryStore(), Keys: keys, Replayer: replayInProcess, TrustedProxies: []string{"proxy"}}, true},
+		{"test#7", Options{Store: newMemoryStore(), Keys: keys}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := New(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() {
+				if err := server.Close(); err != nil {
+					t.Errorf("Close() failed: %v", err)
+				}
+			})
+
+			get := func(target string) int {
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+				return rec.Code
+			}
+
+			if code := get("/livez"); code != http.StatusOK {
+				t.Errorf("GET /livez = %d, want %d", code, http.StatusOK)
+			}
+
+			if code := get("/readyz"); code != http.StatusOK {
+				t.Errorf("GET /readyz = %d, want %d", code, http.StatusOK)
+			}
+
+			// The metrics are only served without authentication on the separate address.
+			wantMetrics := http.StatusUnauthorized
+			if tt.args.Metrics.Address != "" {
+				wantMetrics = http.StatusNotFound
+			}
+
+			if code := get("/metrics"); code != wantMetrics {
+				t.Errorf("GET /metrics = %d, want %d", code, wantMetrics)
+			}
+
+			rec := httptest.NewRecorder()
+			server.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("MetricsHandler() = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			server.Drain()
+			if code := get("/readyz"); code != http.StatusServiceUnavailable {
+				t.Errorf("GET /readyz after Drain() = %d, want %d", code, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+func TestNewLimitsClients(t *testing.T) {
+	keys := newTestKeySet(t, "test")
+	server, err := New(Options{
+		Store:          newMemoryStore(),
+		Keys:           keys,
+		Replayer:       replayInProcess,
+		Limits:         LimitsConfig{API: RateLimit{Rate: 0.001, Burst: 1}},
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	token := func(subject string, scopes ...string) string {
+		token, err := IssueToken(keys, subject, time.Hour, scopes...)
+		if err != nil {
+			t.Fatalf("IssueToken() failed: %v", err)
+		}
+		return "Bearer " + token
+	}
+
+	for _, tt := range []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		token      string
+		want       int
+	}{
+		{"test#1", "192.0.2.1:1234", "", token("p1", ScopePlayer), http.StatusOK},
+		{"test#2", "192.0.2.1:1234", "", token("p2", ScopePlayer), http.StatusTooManyRequests},
+		{"test#3", "192.0.2.1:1234", "198.51.100.1", "", http.StatusTooManyRequests},
+		{"test#4", "192.0.2.1:1234", "", token("admin", ScopeAdmin), http.StatusOK},
+		{"test#5", "10.0.0.1:1234", "198.51.100.2", "", http.StatusOK},
+		{"test#6", "10.0.0.1:1234", "198.51.100.2", "", http.StatusTooManyRequests},
+		{"test#7", "10.0.0.1:1234", "198.51.100.3", "", http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("GET /health = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
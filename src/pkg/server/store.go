@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	zap "go.uber.org/zap"
+	postgres "gorm.io/driver/postgres"
+	gorm "gorm.io/gorm"
+)
+
+// ErrConflict is returned by the store if the record to create conflicts with an existing one.
+var ErrConflict = errors.New("record already exists")
+
+// ErrNotFound is returned by the store if the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// Ensure that the implementations satisfy the Store interface.
+var (
+	_ Store = helper{}
+	_ Store = (*memoryStore)(nil)
+)
+
+// DatabaseConfig configures the connection to the database.
+type DatabaseConfig struct {
+	URL      string `yaml:"url"`      // URL is the address of the database, memory:// selects the in-memory store.
+	SSLMode  string `yaml:"sslmode"`  // SSLMode is the SSL mode of the connection, unless given by the URL.
+	Timezone string `yaml:"timezone"` // Timezone is the time zone of the session, unless given by the URL.
+	Migrate  bool   `yaml:"migrate"`  // Migrate applies the pending migrations on startup.
+}
+
+// Store is the persistence layer of the game server.
+// It is implemented by the Postgres database (see Helper) and by the in-memory store (see NewMemoryStore).
+type Store interface {
+	// CheckSchema checks that the schema of the store is up to date.
+	CheckSchema() error
+	// Close releases the resources of the store, e.g. the database connections.
+	Close() error
+	// ExportMetrics passes the metrics to yield in batches of the given size, ordered by endpoint and method.
+	ExportMetrics(batchSize int, yield func([]Metric) error) error
+	// ExportScores passes the scores to yield in batches of the given size, ordered by name.
+	ExportScores(batchSize int, yield func([]Score) error) error
+	// FindPlayer returns the player matching all non-empty fields of the query.
+	FindPlayer(query PlayerQuery) (Player, error)
+	// GetAuditEvents returns the audit events matching the query, the newest first.
+	GetAuditEvents(query AuditQuery) ([]AuditEvent, error)
+	// GetDatabaseSize returns the size of the store.
+	GetDatabaseSize() (Size, error)
+	// GetFeatureFlagHistory returns the most recent changes of the feature flags, optionally of a single flag.
+	GetFeatureFlagHistory(key string, limit int) ([]FeatureFlagChange, error)
+	// GetFeatureFlags returns the global feature flags and the flags overridden for the player and the session.
+	GetFeatureFlags(player, session string) ([]FeatureFlag, error)
+	// GetLeaderboard returns a page of the leaderboard.
+	GetLeaderboard(query LeaderboardQuery) (Leaderboard, error)
+	// GetMetrics returns the metrics.
+	GetMetrics() ([]Metric, error)
+	// GetPlayerProfile returns the profile of the player.
+	GetPlayerProfile(name string, recentRuns int) (PlayerProfile, error)
+	// GetRank returns the rank of the player's score.
+	GetRank(name string) (int64, error)
+	// GetScore returns the score of the player.
+	GetScore(name string) (Score, error)
+	// GetScores returns the scores sorted by score in descending order.
+	GetScores() ([]Score, error)
+	// GetTableSizes returns the table sizes as a map of table names to sizes.
+	GetTableSizes() (map[string]Size, error)
+	// ImportScores merges the scores with the existing ones according to the import mode, or reports the changes in dry-run mode.
+	ImportScores(scores []Score, mode ImportMode, dryRun bool) (ImportReport, error)
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+	// Prune deletes or, in dry-run mode, counts the rows exceeding the retention policy.
+	Prune(policy RetentionPolicy) (RetentionReport, error)
+	// SaveAuditEvent appends the event to the audit log, which is append-only.
+	SaveAuditEvent(event AuditEvent) error
+	// SaveFeatureFlags sets or unsets the feature flags and records the changes.
+	SaveFeatureFlags(changes []FeatureFlagChange) ([]FeatureFlagChange, error)
+	// SaveMetric saves the metric.
+	SaveMetric(metric Metric) error
+	// SaveMetrics saves the metrics.
+	SaveMetrics(metrics []Metric) error
+	// SavePlayer saves a new player, it returns ErrConflict if its identifier, name or recovery code is taken.
+	SavePlayer(player Player) error
+	// SaveRecoveryHash replaces the hash of the recovery code of the player.
+	SaveRecoveryHash(id, hash string) error
+	// SaveRun saves a single game run.
+	SaveRun(run ScoreRun) error
+	// SaveScores saves the scores, those lacking a player are saved for the player of their name.
+	// It returns ErrConflict if the name of a new score is the name of the score of another player.
+	SaveScores(scores []Score) error
+	// SaveUnverifiedRun saves a game run whose score could not be verified, apart from the scores.
+	SaveUnverifiedRun(run UnverifiedRun) error
+}
+
+// OpenStore opens the store for the given database configuration.
+// The scheme memory selects the in-memory store, any other URL is treated as a Postgres database.
+// Unless disabled by the configuration, the pending migrations of the Postgres database are applied after connecting,
+// a failed migration is logged by the logger and reported by CheckSchema.
+func OpenStore(config DatabaseConfig, logger *zap.Logger) (Store, error) {
+	address, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if address.Scheme == "memory" {
+		return NewMemoryStore(), nil
+	}
+
+	database, err := openDatabase(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed migration is reported by the readiness probe.
+	if config.Migrate && !database.DryRun {
+		if err := migrateDatabase(database, logger); err != nil {
+			logger.Error("Failed to migrate database", zap.Error(err))
+		}
+	}
+
+	return Helper(database), nil
+}
+
+// migrateDatabase applies the pending migrations of the database.
+func migrateDatabase(database *gorm.DB, logger *zap.Logger) error {
+	migrator, err := SchemaMigrator(database, logger)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(0)
+	return err
+}
+
+// openDatabase connects to the Postgres database given by the configuration.
+func openDatabase(config DatabaseConfig) (*gorm.DB, error) {
+	dsn, err := parsePostgresURL(config.URL, map[string]string{"sslmode": config.SSLMode, "timezone": config.Timezone})
+	if err != nil {
+		return nil, err
+	}
+
+	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return database, nil
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	exportBatchSize = 1000             // exportBatchSize is the number of rows read from the store at once by an export.
+	exportTimeout   = 10 * time.Minute // exportTimeout is the write deadline of an export, replacing the write timeout of the server.
+	importMaxBytes  = 64 << 20         // importMaxBytes is the maximum size of the body of an import.
+)
+
+// Formats of the exports and imports.
+const (
+	formatCSV    = "csv"    // formatCSV is a header row followed by a row per record.
+	formatJSON   = "json"   // formatJSON is an array of objects.
+	formatNDJSON = "ndjson" // formatNDJSON is an object per line.
+)
+
+// transferFormats maps the formats to their media types.
+var transferFormats = map[string]string{
+	formatCSV:    "text/csv; charset=utf-8",
+	formatJSON:   "application/json; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+}
+
+var (
+	metricColumns = []string{"endpoint", "method", "count", "created_at", "updated_at"} // metricColumns are the CSV columns of the metrics.
+	scoreColumns  = []string{"name", "score", "created_at", "updated_at"}               // scoreColumns are the CSV columns of the scores.
+)
+
+// recordEncoder writes records in one of the transfer formats as they are passed to it.
+type recordEncoder[T any] struct {
+	writer  io.Writer
+	format  string
+	columns []string         // columns is the header of the CSV format.
+	row     func(T) []string // row returns the fields of a record in the order of the columns.
+	csv     *csv.Writer
+	count   int // count is the number of records written.
+}
+
+// Close completes the output, e.g. the header of an empty CSV export or the end of the JSON array.
+func (e *recordEncoder[T]) Close() error {
+	switch e.format {
+	case formatCSV:
+		if e.count == 0 {
+			if err := e.csv.Write(e.columns); err != nil {
+				return err
+			}
+		}
+		e.csv.Flush()
+		return e.csv.Error()
+
+	case formatJSON:
+		end := "\n]\n"
+		if e.count == 0 {
+			end = "[]\n"
+		}
+
+		_, err := io.WriteString(e.writer, end)
+		return err
+
+	}
+
+	return nil
+}
+
+// Encode writes the records.
+func (e *recordEncoder[T]) Encode(records []T) error {
+	for _, record := range records {
+		switch e.format {
+		case formatCSV:
+			if e.count == 0 {
+				if err := e.csv.Write(e.columns); err != nil {
+					return err
+				}
+			}
+
+			if err := e.csv.Write(e.row(record)); err != nil {
+				return err
+			}
+
+		default:
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case e.format == formatNDJSON:
+				data = append(data, '\n')
+			case e.count == 0:
+				data = append([]byte("[\n"), data...)
+			default:
+				data = append([]byte(",\n"), data...)
+			}
+
+			if _, err := e.writer.Write(data); err != nil {
+				return err
+			}
+
+		}
+
+		e.count++
+	}
+
+	if e.format == formatCSV {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	return nil
+}
+
+// RecordEncoder returns an encoder writing records in the given format to the writer.
+// The columns and row only apply to the CSV format.
+func RecordEncoder[T any](writer io.Writer, format string, columns []string, row func(T) []string) (*recordEncoder[T], error) {
+	if _, ok := transferFormats[format]; !ok {
+		return nil, fmt.Errorf("unknown format: %q", format)
+	}
+
+	return &recordEncoder[T]{writer: writer, format: format, columns: columns, row: row, csv: csv.NewWriter(writer)}, nil
+}
+
+// decodeScores decodes the scores in the given format, see validateScores for their validation.
+// The CSV format requires a header row naming the columns name and score, the timestamps are optional.
+func decodeScores(reader io.Reader, format string) ([]Score, error) {
+	var scores []Score
+	switch format {
+	case formatCSV:
+		records := csv.NewReader(reader)
+		records.ReuseRecord = true
+		header, err := records.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+
+		index := make(map[string]int, len(header))
+		for i, column := range header {
+			index[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+
+		for _, column := range scoreColumns[:2] {
+			if _, ok := index[column]; !ok {
+				return nil, fmt.Errorf("missing column: %s", column)
+			}
+		}
+
+		for {
+			record, err := records.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			line, _ := records.FieldPos(0)
+			score, err := parseScoreRecord(index, record)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			scores = append(scores, score)
+		}
+
+	case formatJSON:
+		if err := json.NewDecoder(reader).Decode(&scores); err != nil {
+			return nil, err
+		}
+
+	case formatNDJSON:
+		decoder := json.NewDecoder(reader)
+		for {
+			var score Score
+			err := decoder.Decode(&score)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", len(scores)+1, err)
+			}
+
+			scores = append(scores, score)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown format: %q", format)
+
+	}
+
+	return scores, nil
+}
+
+// exportBatches passes the records to yield in batches of the given size.
+func exportBatches[T any](records []T, batchSize int, yield func([]T) error) error {
+	for start := 0; start < len(records); start += batchSize {
+		if err := yield(records[start:min(start+batchSize, len(records))]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatTimestamp formats the optional timestamp in RFC 3339, or returns an empty string.
+func formatTimestamp(timestamp *time.Time) string {
+	if timestamp == nil {
+		return ""
+	}
+
+	return timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+// metricRow returns the CSV fields of the metric.
+func metricRow(metric Metric) []string {
+	return []string{metric.Endpoint, metric.Method, strconv.FormatInt(metric.Count, 10), formatTimestamp(metric.CreatedAt), formatTimestamp(metric.UpdatedAt)}
+}
+
+// parseScoreRecord parses the CSV record of a score with the given column indices.
+func parseScoreRecord(index map[string]int, record []string) (score Score, err error) {
+	field := func(column string) string {
+		if i, ok := index[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	score.Name = field("name")
+	if score.Score, err = strconv.ParseInt(field("score"), 10, 64); err != nil {
+		return score, fmt.Errorf("invalid score: %w", err)
+	}
+
+	for column, target := range map[string]**time.Time{"created_at": &score.CreatedAt, "updated_at": &score.UpdatedAt} {
+		if value := field(column); value != "" {
+			timestamp, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return score, fmt.Errorf("invalid %s: %w", column, err)
+			}
+			*target = &timestamp
+		}
+	}
+
+	return score, nil
+}
+
+// scoreRow returns the CSV fields of the score.
+func scoreRow(score Score) []string {
+	return []string{score.Name, strconv.FormatInt(score.Score, 10), formatTimestamp(score.CreatedAt), formatTimestamp(score.UpdatedAt)}
+}
+
+// transferFormat returns the format given by the query parameter or else by the media type, JSON by default.
+func transferFormat(query, contentType string) (string, error) {
+	if query != "" {
+		if _, ok := transferFormats[query]; !ok {
+			return "", fmt.Errorf("unknown format: %q", query)
+		}
+		return query, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return formatCSV, nil
+	case "application/x-ndjson":
+		return formatNDJSON, nil
+	default:
+		return formatJSON, nil
+	}
+}
+
+// validateScores validates the imported scores, trimming the names.
+// The names must be unique, so that the outcome of the import does not depend on the order of the scores.
+func validateScores(scores []Score) error {
+	var errs []error
+	seen := make(map[string]int, len(scores))
+	for i := range scores {
+		score := &scores[i]
+		score.Name = strings.TrimSpace(score.Name)
+		switch length := utf8.RuneCountInString(score.Name); {
+		case length == 0:
+			errs = append(errs, fmt.Errorf("record %d: name must not be empty", i+1))
+		case length > maximumNameLength:
+			errs = append(errs, fmt.Errorf("record %d: name must not be longer than %d characters", i+1, maximumNameLength))
+		}
+
+		if score.Score < 0 {
+			errs = append(errs, fmt.Errorf("record %d: score must not be negative", i+1))
+		}
+
+		if first, ok := seen[score.Name]; ok && score.Name != "" {
+			errs = append(errs, fmt.Errorf("record %d: duplicate name %q of record %d", i+1, score.Name, first))
+		} else {
+			seen[score.Name] = i + 1
+		}
+
+		if len(errs) >= 10 {
+			errs = append(errs, fmt.Errorf("too many errors"))
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordEncoder(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	scores := []Score{{BaseModel: BaseModel{CreatedAt: &timestamp}, Name: "a", Score: 1}, {Name: "b,c", Score: 2}}
+
+	for _, tt := range []struct {
+		name    string
+		format  string
+		batches [][]Score
+		want    string
+	}{
+		{"test#1", formatCSV, [][]Score{scores[:1], scores[1:]}, "name,score,created_at,updated_at\na,1,2024-01-02T03:04:05Z,\n\"b,c\",2,,\n"},
+		{"test#2", formatCSV, nil, "name,score,created_at,updated_at\n"},
+		{"test#3", formatJSON, [][]Score{scores[:1], scores[1:]}, "[\n{\"created_at\":\"2024-01-02T03:04:05Z\",\"name\":\"a\",\"score\":1},\n{\"name\":\"b,c\",\"score\":2}\n]\n"},
+		{"test#4", formatJSON, nil, "[]\n"},
+		{"test#5", formatNDJSON, [][]Score{scores}, "{\"created_at\":\"2024-01-02T03:04:05Z\",\"name\":\"a\",\"score\":1}\n{\"name\":\"b,c\",\"score\":2}\n"},
+		{"test#6", formatNDJSON, nil, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer strings.Builder
+			encoder, err := RecordEncoder(&buffer, tt.format, scoreColumns, scoreRow)
+			if err != nil {
+				t.Fatalf("RecordEncoder() failed: %v", err)
+			}
+
+			for _, batch := range tt.batches {
+				if err := encoder.Encode(batch); err != nil {
+					t.Fatalf("Encode() failed: %v", err)
+				}
+			}
+
+			if err := encoder.Close(); err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+
+			if buffer.String() != tt.want {
+				t.Errorf("encoded %q, want %q", buffer.String(), tt.want)
+			}
+
+			// Every export can be imported again.
+			want := 0
+			for _, batch := range tt.batches {
+				want += len(batch)
+			}
+
+			decoded, err := decodeScores(strings.NewReader(buffer.String()), tt.format)
+			if err != nil || len(decoded) != want {
+				t.Errorf("decodeScores() = (%+v, %v), want %d scores", decoded, err, want)
+			}
+		})
+	}
+
+	if _, err := RecordEncoder(&bytes.Buffer{}, "xml", scoreColumns, scoreRow); err == nil {
+		t.Errorf("RecordEncoder() of an unknown format succeeded, want error")
+	}
+}
+
+func TestDecodeScores(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		format  string
+		input   string
+		want    []Score
+		wantErr bool
+	}{
+		{"test#1", formatCSV, "Score, Name\n3,a\n4,b\n", []Score{{Name: "a", Score: 3}, {Name: "b", Score: 4}}, false},
+		{"test#2", formatCSV, "name\na\n", nil, true},
+		{"test#3", formatCSV, "name,score\na,x\n", nil, true},
+		{"test#4", formatCSV, "name,score,created_at\na,1,yesterday\n", nil, true},
+		{"test#5", formatCSV, "", nil, true},
+		{"test#6", formatJSON, `[{"name":"a","score":3}]`, []Score{{Name: "a", Score: 3}}, false},
+		{"test#7", formatJSON, `{"name":"a"}`, nil, true},
+		{"test#8", formatNDJSON, "{\"name\":\"a\",\"score\":3}\n\n{\"name\":\"b\",\"score\":4}\n", []Score{{Name: "a", Score: 3}, {Name: "b", Score: 4}}, false},
+		{"test#9", formatNDJSON, "{\"name\":\"a\"}\n{\n", nil, true},
+		{"test#10", "xml", "", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeScores(strings.NewReader(tt.input), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeScores() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("decodeScores() = %+v, want %+v", got, tt.want)
+			}
+
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || got[i].Score != tt.want[i].Score {
+					t.Errorf("decodeScores()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransferFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		query       string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{"test#1", "", "", formatJSON, false},
+		{"test#2", "", "text/csv; charset=utf-8", formatCSV, false},
+		{"test#3", "", "application/x-ndjson", formatNDJSON, false},
+		{"test#4", "csv", "application/json", formatCSV, false},
+		{"test#5", "xml", "", "", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transferFormat(tt.query, tt.contentType)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("transferFormat(%q, %q) = (%q, %v), want %q", tt.query, tt.contentType, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScores(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    []Score
+		wantErr string
+	}{
+		{"test#1", []Score{{Name: " a ", Score: 1}, {Name: "b"}}, ""},
+		{"test#2", []Score{{Name: " "}}, "record 1: name must not be empty"},
+		{"test#3", []Score{{Name: strings.Repeat("x", maximumNameLength+1)}}, "record 1: name must not be longer"},
+		{"test#4", []Score{{Name: "a", Score: -1}}, "record 1: score must not be negative"},
+		{"test#5", []Score{{Name: "a"}, {Name: "b"}, {Name: "a "}}, `record 3: duplicate name "a" of record 1`},
+		{"test#6", make([]Score, 20), "too many errors"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateScores(tt.args)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateScores() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validateScores() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
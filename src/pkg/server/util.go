@@ -0,0 +1,333 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	client "github.com/sarumaj/edu-space-invaders/src/pkg/client"
+	zap "go.uber.org/zap"
+)
+
+// associatedData is the associated data for the AES GCM cipher.
+const associatedData = "aes256gcm"
+
+// decodeB64AndDecryptWithAES decodes the base64-encoded message and decrypts it using the AES key.
+func decodeB64AndDecryptWithAES(keyCipher cipher.AEAD, b64encoded string) (string, error) {
+	// Trim the padding characters from the encrypted message
+	encryptedMessage := strings.TrimSuffix(b64encoded, string(base64.StdPadding))
+
+	// Decode the encrypted message
+	ciphertext, err := base64.RawStdEncoding.DecodeString(encryptedMessage)
+	if err != nil {
+		return "", err
+	}
+
+	// Check if the ciphertext is too short
+	nonceSize := keyCipher.NonceSize()
+	if nonceSize == 0 || len(ciphertext) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short or invalid nonce size")
+	}
+
+	// Extract the nonce and decrypt the ciphertext
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	plaintext, err := keyCipher.Open(nil, nonce, ciphertext, []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// encryptAndEncodeB64WithAES encrypts the plaintext using the AES key and encodes it as a base64 string.
+func encryptAndEncodeB64WithAES(keyCipher cipher.AEAD, plaintext string) (string, error) {
+	// Generate a random nonce
+	nonce := make([]byte, keyCipher.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	// Encrypt the plaintext
+	ciphertext := keyCipher.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+	return base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// getLogger returns the logger of the server handling the request.
+// It returns a no-op logger if the request is not handled by a server, e.g. in tests.
+func getLogger(ctx *gin.Context) *zap.Logger {
+	if logger, ok := ctx.Value("logger").(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.NewNop()
+}
+
+// getPlayerName returns the name claimed by the player of the session.
+// It returns an empty string if the session has not claimed a name.
+func getPlayerName(ctx *gin.Context, store Store) (string, error) {
+	player, err := store.FindPlayer(PlayerQuery{ID: getSubject(ctx)})
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+
+	return player.Name, nil
+}
+
+// getRoute returns the route of the request used to label its metrics.
+// Paths served by the conflicting handlers of ServeFileSystem are labeled by their route template.
+// It returns "unmatched" if the request has not been routed.
+func getRoute(ctx *gin.Context) string {
+	if route := ctx.GetString("route"); route != "" {
+		return route
+	}
+
+	return selectValue(ctx.FullPath(), "unmatched")
+}
+
+// getSubject returns the subject of the JWT claims of the authenticated request.
+// It returns an empty string if the request has not been authenticated.
+func getSubject(ctx *gin.Context) string {
+	claims, ok := ctx.Get("claims")
+	if !ok {
+		return ""
+	}
+
+	if claims, ok := claims.(jwt.Claims); ok {
+		subject, _ := claims.GetSubject()
+		return subject
+	}
+
+	return ""
+}
+
+// hashRecoveryCode returns the hash of the recovery code, which is kept instead of the code.
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(client.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(hash[:])
+}
+
+// newPlayer returns a player of the given name with a new identifier and the hash of a recovery code,
+// which is discarded, see newRecoveryCode.
+func newPlayer(name string) (Player, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return Player{}, err
+	}
+
+	code, err := newRecoveryCode()
+	if err != nil {
+		return Player{}, err
+	}
+
+	return Player{ID: id, Name: name, RecoveryHash: hashRecoveryCode(code)}, nil
+}
+
+// newRecoveryCode returns a random recovery code of 160 bits, e.g. ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23-4567.
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", err
+	}
+
+	encoded := base32.StdEncoding.EncodeToString(raw)
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// newSessionID returns a random session identifier.
+// It is the identifier of the player of the session as well.
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// loadAESKey reads the AES key used to encrypt session cookies.
+func loadAESKey(path string) (cipher.AEAD, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AES key: %w", err)
+	}
+
+	cryptKey, err := parseAES2GCMKeyFromPem(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AES key: %w", err)
+	}
+
+	return cryptKey, nil
+}
+
+// loadRSAKey reads the RSA key used to sign and verify JWT tokens.
+func loadRSAKey(path string) (*rsa.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RSA key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA key: %w", err)
+	}
+
+	return key, nil
+}
+
+// parseAES2GCMKeyFromPem parses the AES key from the PEM-encoded data.
+// It returns the AES GCM cipher or an error if parsing fails.
+// The PEM-encoded data is expected to contain the AES key.
+func parseAES2GCMKeyFromPem(raw []byte) (cipher.AEAD, error) {
+	decoded, _ := pem.Decode(raw)
+	if decoded == nil || decoded.Type != "AES PRIVATE KEY" {
+		return nil, fmt.Errorf("failed to decode encryption key")
+	}
+
+	block, err := aes.NewCipher(decoded.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// parsePostgresURL parses the database URL and returns the DSN.
+// The parameters are used unless given by the URL, e.g. the SSL mode or the time zone.
+func parsePostgresURL(databaseUrl string, params map[string]string) (string, error) {
+	out := map[string]string{}
+	for key, value := range params {
+		if value != "" {
+			out[key] = value
+		}
+	}
+
+	// Parse the database URL
+	databaseAddress, err := url.Parse(databaseUrl)
+	if err != nil {
+		return "", err
+	}
+
+	// Helper function to update the `out` map
+	write := func(key string, value string, force bool) {
+		if value != "" || force {
+			out[key] = value
+		}
+	}
+
+	// Write the host, port, and dbname
+	write("host", databaseAddress.Hostname(), false)
+	write("port", databaseAddress.Port(), false)
+	write("dbname", strings.TrimPrefix(databaseAddress.Path, "/"), false)
+
+	// Handle user credentials
+	if databaseAddress.User != nil {
+		write("user", databaseAddress.User.Username(), false)
+		password, ok := databaseAddress.User.Password()
+		write("password", password, ok)
+	}
+
+	// Handle query parameters (e.g., sslmode)
+	for key, value := range databaseAddress.Query() {
+		switch key {
+		case "host", "port", "dbname", "user", "password":
+
+		default:
+			write(key, value[0], true)
+		}
+	}
+
+	var parts []string
+	for key, value := range out {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	slices.Sort(parts)
+	return strings.Join(parts, " "), nil
+}
+
+// renewRecoveryCode replaces the recovery code of the player and returns the new code.
+func renewRecoveryCode(store Store, player Player) (PlayerCredentials, error) {
+	code, err := newRecoveryCode()
+	if err != nil {
+		return PlayerCredentials{}, err
+	}
+
+	if err := store.SaveRecoveryHash(player.ID, hashRecoveryCode(code)); err != nil {
+		return PlayerCredentials{}, err
+	}
+
+	return PlayerCredentials{ID: player.ID, Name: player.Name, RecoveryCode: code}, nil
+}
+
+// routeTemplate returns a readable template of the path pattern.
+// Named subexpressions are replaced by their names prefixed with a colon,
+// e.g. ^/?api/v1/players/(?P<name>[^/]+)/?$ becomes /api/v1/players/:name.
+func routeTemplate(pattern *regexp.Regexp) string {
+	template := strings.TrimSuffix(strings.TrimPrefix(pattern.String(), "^/?"), "/?$")
+	template = regexp.MustCompile(`\(\?P<(\w+)>[^)]*\)`).ReplaceAllString(template, ":$1")
+	return "/" + strings.ReplaceAll(template, `\`, "")
+}
+
+// selectValue returns the first non-zero value from the given list.
+func selectValue[T comparable](values ...T) (zero T) {
+	for _, value := range values {
+		if value != zero {
+			return value
+		}
+	}
+
+	return
+}
+
+// setSessionCookie issues a session token for the subject, granting the player scope only,
+// and sets it encrypted as the session cookie.
+func setSessionCookie(ctx *gin.Context, keys *KeySet, sessionName, subject string, sessionDuration time.Duration) error {
+	jwtToken, err := IssueToken(keys, subject, sessionDuration, ScopePlayer)
+	if err != nil {
+		return fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	encrypted, err := keys.Encrypt(jwtToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     sessionName,
+		Value:    encrypted,
+		MaxAge:   int(sessionDuration.Seconds()),
+		Path:     "/",
+		Domain:   selectValue(ctx.GetHeader("X-Forwarded-Host"), ctx.Request.URL.Hostname()),
+		Secure:   selectValue(ctx.GetHeader("X-Forwarded-Proto"), ctx.Request.URL.Scheme) == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
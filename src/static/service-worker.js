@@ -37,15 +37,26 @@ self.addEventListener("fetch", (event) => {
   const isEnvRequest = event.request.url.includes("/.env");
   const isScoresRequest = event.request.url.includes("/scores.db");
   const isHealthRequest = event.request.url.includes("/health");
+  const isApiRequest = event.request.url.includes("/api/");
 
   if (
     event.request.method === "GET" &&
     !isEnvRequest &&
     !isScoresRequest &&
-    !isHealthRequest
+    !isHealthRequest &&
+    !isApiRequest
   ) {
+    // Content-addressed assets (/a/<sha256>/<path>) never change, so they need no revalidation
+    const isImmutableRequest = new URL(event.request.url).pathname.startsWith(
+      "/a/"
+    );
+
     event.respondWith(
       caches.match(event.request).then((cachedResponse) => {
+        if (cachedResponse && isImmutableRequest) {
+          return cachedResponse;
+        }
+
         if (cachedResponse) {
           const etag = cachedResponse.headers.get("ETag");
 